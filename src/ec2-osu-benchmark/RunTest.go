@@ -2,6 +2,7 @@ package main
 
 import (
     "fmt"
+    "os"
     "ec2-osu-benchmark/config"
     "ec2-osu-benchmark/logging"
     "ec2-osu-benchmark/testRunner"
@@ -20,21 +21,75 @@ func startLoggerService(configObj *config.AppConfig) {
 func Runtests(configObj *config.AppConfig,
               osu_mpi_tests *testRunner.OSU_MPI_cmds) error{
     
-    err := osu_mpi_tests.Init_OSU_MPI_Cmds(configObj.MPIcount,
-                                    configObj.HostFile)
+    err := osu_mpi_tests.Init_OSU_MPI_Cmds(configObj)
     if err != errors.OP_SUCCESS {
         fmt.Print(err)
         return err
     }
+    if !configObj.SkipPreflight {
+        err = Preflight(osu_mpi_tests)
+        if err == errors.PREFLIGHT_FAILED && configObj.Force {
+            fmt.Print("Preflight checks failed, running the benchmarks " +
+                      "as forced\n")
+        } else if err != errors.OP_SUCCESS {
+            // Nothing is run, the refused run leaves no result directory.
+            osu_mpi_tests.Remove_result_dir()
+            return err
+        }
+    }
     syncObj := sys.GetAppSyncObj()
     syncObj.AddRoutineInWaitGroup() 
     //Start the result writer thread
     go osu_mpi_tests.WriteCommandOutput()
 
-    // Run the OSU test cases
-    osu_mpi_tests.Run_OSU_MPI_Cmds()
+    // Run the OSU test cases, the results are in their files once the
+    // writer is done.
+    err = osu_mpi_tests.Run_OSU_MPI_Cmds()
+    osu_mpi_tests.ExitresultWriteRoutine()
+    syncObj.JoinAllRoutines()
+    return err
+}
+func ListBenchmarks(configObj *config.AppConfig) error {
+    registry, err := testRunner.Get_OSU_benchmark_registry(configObj)
+    if err != errors.OP_SUCCESS {
+        return err
+    }
+    registry.List(os.Stdout)
+    return errors.OP_SUCCESS
+}
+
+// Check all the hosts and print the pass/fail table, fails when any host
+// fails a check.
+func Preflight(osu_mpi_tests *testRunner.OSU_MPI_cmds) error {
+    preflight, err := osu_mpi_tests.Run_preflight()
+    if err != errors.OP_SUCCESS {
+        return err
+    }
+    preflight.Write(os.Stdout)
+    if !preflight.Passed {
+        return errors.PREFLIGHT_FAILED
+    }
+    return errors.OP_SUCCESS
+}
+
+// Print what the run would do, fails when the run would have problems.
+func DryRun(configObj *config.AppConfig) error {
+    osu_mpi_tests := new(testRunner.OSU_MPI_cmds)
+    err := osu_mpi_tests.Init_OSU_MPI_Cmds(configObj)
+    if err != errors.OP_SUCCESS {
+        return err
+    }
+    dryRun := osu_mpi_tests.Dry_run()
+    err = dryRun.Write(os.Stdout, configObj.DryRunFormat)
+    if err != errors.OP_SUCCESS {
+        return err
+    }
+    if len(dryRun.Problems) != 0 {
+        return errors.INVALID_INPUT
+    }
     return errors.OP_SUCCESS
 }
+
 func Write2Json(configObj *config.AppConfig,
                 path string) {
     jsonwrite := new(text2json.Text2Json)
@@ -52,14 +107,61 @@ func main() {
         panic ("Exiting the testrun due to invalid configuration")
     }
     startLoggerService(configObj)
-    syncObj := sys.GetAppSyncObj()
+    if configObj.ListBenchmarks {
+        err = ListBenchmarks(configObj)
+        if err != errors.OP_SUCCESS {
+            panic ("Exiting due to failed to list benchmarks")
+        }
+        return
+    }
+    if configObj.DryRun {
+        err = DryRun(configObj)
+        if err != errors.OP_SUCCESS {
+            fmt.Printf("Dry run failed, err : %s\n", err)
+            os.Exit(1)
+        }
+        return
+    }
     osu_mpi_tests := new(testRunner.OSU_MPI_cmds)
+    if configObj.Preflight {
+        err = osu_mpi_tests.Init_OSU_MPI_Cmds(configObj)
+        if err == errors.OP_SUCCESS {
+            err = Preflight(osu_mpi_tests)
+        }
+        if err != errors.OP_SUCCESS {
+            fmt.Printf("Preflight failed, err : %s\n", err)
+            os.Exit(1)
+        }
+        return
+    }
     err = Runtests(configObj, osu_mpi_tests)
-    if err != errors.OP_SUCCESS {
+    if err == errors.PREFLIGHT_FAILED {
+        fmt.Print("Not running the benchmarks as the preflight checks " +
+                  "failed, use -force to run anyway\n")
+        os.Exit(1)
+    }
+    resultDir := osu_mpi_tests.Get_OSU_MPI_test_result_path()
+    if err == errors.INVALID_OP {
+        // The report is written once the campaign is complete.
+        fmt.Printf("Benchmarks are interrupted, no report is written, " +
+                   "continue with -resume %s\n", resultDir)
+        os.Exit(1)
+    }
+    // Failed benchmarks are in the report, the campaign failed otherwise.
+    if err != errors.OP_SUCCESS && osu_mpi_tests.Get_campaign_status() !=
+                                   testRunner.CAMPAIGN_STATUS_COMPLETED {
+        fmt.Printf("Failed to run the benchmarks, err : %s\n", err)
         panic ("Exiting the testrun due to failed to run tests")
     }
-    osu_mpi_tests.ExitresultWriteRoutine()
-    syncObj.JoinAllRoutines()
+    writeErr := osu_mpi_tests.Get_result_write_error()
+    if writeErr != errors.OP_SUCCESS {
+        fmt.Printf("Failed to write some of the results, err : %s\n",
+                   writeErr)
+    }
     // Write to json only after all go-routines are done with its processing
-    Write2Json(configObj, osu_mpi_tests.Get_OSU_MPI_test_result_path())
+    Write2Json(configObj, resultDir)
+    if err != errors.OP_SUCCESS {
+        fmt.Printf("Some of the benchmarks failed, err : %s\n", err)
+        os.Exit(1)
+    }
 }
\ No newline at end of file
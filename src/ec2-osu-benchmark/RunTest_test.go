@@ -0,0 +1,199 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "ec2-osu-benchmark/config"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/simulator"
+    "ec2-osu-benchmark/sys"
+    "ec2-osu-benchmark/testRunner"
+    "ec2-osu-benchmark/text2json"
+)
+
+// Instance metadata service of a m5n.24xlarge with IMDSv2.
+func newMetadataServer(t *testing.T) *httptest.Server {
+    metadata := map[string]string{
+        "instance-id": "i-0123456789abcdef0",
+        "instance-type": "m5n.24xlarge",
+        "placement/availability-zone": "us-east-2a",
+        "placement/region": "us-east-2",
+        "placement/group-name": "osu-cluster",
+        "ami-id": "ami-0abcdef1234567890"}
+    server := httptest.NewServer(http.HandlerFunc(
+        func(writer http.ResponseWriter, request *http.Request) {
+            if request.URL.Path == "/latest/api/token" {
+                writer.Write([]byte("token"))
+                return
+            }
+            value, ok := metadata[strings.TrimPrefix(request.URL.Path,
+                                                     "/latest/meta-data/")]
+            if !ok || request.Header.Get("X-aws-ec2-metadata-token") !=
+                      "token" {
+                http.NotFound(writer, request)
+                return
+            }
+            writer.Write([]byte(value))
+        }))
+    t.Cleanup(server.Close)
+    return server
+}
+
+// Whole run on the simulator, from the commandline to the metric file.
+func TestPipeline(t *testing.T) {
+    savedEndpoint := testRunner.EC2_METADATA_ENDPOINT
+    testRunner.EC2_METADATA_ENDPOINT = newMetadataServer(t).URL
+    defer func() { testRunner.EC2_METADATA_ENDPOINT = savedEndpoint }()
+    savedHostName := config.GetPublicHostName
+    config.GetPublicHostName = func() (string, error) {
+        return "ec2-3-14-0-1.us-east-2.compute.amazonaws.com",
+               errors.OP_SUCCESS
+    }
+    defer func() { config.GetPublicHostName = savedHostName }()
+    tempDir := t.TempDir()
+    hostFile := filepath.Join(tempDir, "hostfile")
+    err := ioutil.WriteFile(hostFile, []byte("h1 slots=2\nh2 slots=2\n"), 0644)
+    if err != nil {
+        t.Fatal(err)
+    }
+    metricDir := filepath.Join(tempDir, "metrics")
+    savedArgs, savedFlags := os.Args, flag.CommandLine
+    defer func() {
+        os.Args, flag.CommandLine = savedArgs, savedFlags
+    }()
+    os.Args = []string{"ec2-osu-benchmark", "-c", "2", "-f", hostFile,
+        "-benchmarks", "osu_latency,osu_bw,osu_allreduce", "-repeat", "2",
+        "-launcher", "openmpi", "-metric-dir", metricDir,
+        "-results-root", filepath.Join(tempDir, "results")}
+    flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+    flag.CommandLine.SetOutput(ioutil.Discard)
+
+    configObj := new(config.AppConfig)
+    if err = configObj.InitConfig(); err != errors.OP_SUCCESS {
+        t.Fatalf("InitConfig failed, err : %s", err)
+    }
+    new(logging.Logging).LogInitSingleton(logging.Error,
+                                          filepath.Join(tempDir, "test.log"))
+    osu_mpi_tests := new(testRunner.OSU_MPI_cmds)
+    osu_mpi_tests.Set_command_executor(
+        simulator.New_executor(simulator.Default_config()))
+    if err = Runtests(configObj, osu_mpi_tests); err != errors.OP_SUCCESS {
+        t.Fatalf("Runtests failed, err : %s", err)
+    }
+    resultDir := osu_mpi_tests.Get_OSU_MPI_test_result_path()
+    osu_mpi_tests.ExitresultWriteRoutine()
+    sys.GetAppSyncObj().JoinAllRoutines()
+    if err = osu_mpi_tests.Get_result_write_error(); err != errors.OP_SUCCESS {
+        t.Fatalf("Failed to write results, err : %s", err)
+    }
+    Write2Json(configObj, resultDir)
+
+    jsonBytes, err := ioutil.ReadFile(resultDir + config.DEFAULT_REPORT_FILE)
+    if err != nil {
+        t.Fatal(err)
+    }
+    results := new(text2json.OSUResults)
+    if err = json.Unmarshal(jsonBytes, results); err != nil {
+        t.Fatal(err)
+    }
+    if len(results.OsuLatency) == 0 || len(results.OsuBW) == 0 {
+        t.Errorf("missing point to point results in the report")
+    }
+    if _, ok := results.OsuCollective["osu_allreduce"]; !ok {
+        t.Errorf("missing osu_allreduce in the report")
+    }
+    if len(results.Runs) != 6 {
+        t.Errorf("expected 6 runs in the report, got %d", len(results.Runs))
+    }
+    for _, stats := range results.Statistics {
+        if stats.Repetitions != 2 {
+            t.Errorf("%s %s has %d repetitions", stats.Benchmark,
+                     stats.Metric, stats.Repetitions)
+        }
+    }
+    if results.OSUVersion != simulator.OSU_VERSION {
+        t.Errorf("OSU version is %q, want %q", results.OSUVersion,
+                 simulator.OSU_VERSION)
+    }
+    manifest := results.Manifest
+    if manifest == nil {
+        t.Fatalf("missing manifest in the report")
+    }
+    if manifest.MPI.Version != "mpirun (Open MPI) 4.1.5" ||
+       manifest.OSU.Version != simulator.OSU_VERSION ||
+       manifest.Config == nil || manifest.Config.Repeat != 2 ||
+       len(manifest.Tool.GitCommit) == 0 {
+        t.Errorf("unexpected manifest %+v", manifest)
+    }
+    if manifest.EC2 == nil || manifest.EC2.InstanceType != "m5n.24xlarge" ||
+       manifest.EC2.PlacementGroup != "osu-cluster" {
+        t.Errorf("unexpected instance metadata %+v", manifest.EC2)
+    }
+    info, err := testRunner.Read_run_info(resultDir)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Read_run_info failed, err : %s", err)
+    }
+    if !results.Timestamp.Equal(info.StartTime) ||
+       results.RunID != info.RunID || manifest.RunID != info.RunID {
+        t.Errorf("report of run %s at %s, want run %s at %s", results.RunID,
+                 results.Timestamp, info.RunID, info.StartTime)
+    }
+    wantDir := config.ExpandResultLayout(filepath.Join(tempDir, "results"),
+        config.DEFAULT_RESULT_LAYOUT, info.RunID, info.StartTime)
+    if resultDir != wantDir {
+        t.Errorf("result directory is %s, want %s", resultDir, wantDir)
+    }
+
+    metricFiles, _ := filepath.Glob(filepath.Join(metricDir,
+        config.DEFAULT_MATRIC_OUTPUT_FILE_NAME+"*"))
+    if len(metricFiles) != 1 {
+        t.Fatalf("expected a metric file in %s, got %v", metricDir,
+                 metricFiles)
+    }
+    metrics, _ := ioutil.ReadFile(metricFiles[0])
+    for _, want := range []string{"UniDirBWinMB", "osu_allreduceAvgLatencyInUs"} {
+        if !strings.Contains(string(metrics), want) {
+            t.Errorf("metric file doesn't have %s", want)
+        }
+    }
+}
+
+// A campaign refused by the preflight leaves no result directory.
+func TestRefusedPreflight(t *testing.T) {
+    tempDir := t.TempDir()
+    new(logging.Logging).LogInitSingleton(logging.Error,
+                                          filepath.Join(tempDir, "test.log"))
+    configObj := new(config.AppConfig)
+    configObj.MPIcount = 2
+    configObj.HostFile = filepath.Join(tempDir, "hostfile")
+    err := ioutil.WriteFile(configObj.HostFile, []byte("h1\nh2\n"), 0644)
+    if err != nil {
+        t.Fatal(err)
+    }
+    configObj.Launcher = config.LAUNCHER_OPENMPI
+    configObj.Benchmarks = []string{"osu_latency"}
+    configObj.Repeat = 1
+    configObj.ResultsRoot = filepath.Join(tempDir, "results")
+    simConfig := simulator.Default_config()
+    simConfig.MissingOn = []string{"h2"}
+    osu_mpi_tests := new(testRunner.OSU_MPI_cmds)
+    osu_mpi_tests.Set_command_executor(simulator.New_executor(simConfig))
+    if err = Runtests(configObj, osu_mpi_tests);
+       err != errors.PREFLIGHT_FAILED {
+        t.Fatalf("Runtests returned %v, want %v", err,
+                 errors.PREFLIGHT_FAILED)
+    }
+    entries, _ := ioutil.ReadDir(configObj.ResultsRoot)
+    if len(entries) != 0 {
+        t.Errorf("refused campaign left %d entries in %s", len(entries),
+                 configObj.ResultsRoot)
+    }
+}
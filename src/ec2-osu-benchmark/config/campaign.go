@@ -0,0 +1,387 @@
+package config
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "path"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "ec2-osu-benchmark/errors"
+)
+
+// Environment the benchmarks are run with in addition to the global one,
+// ex: the libfabric provider.
+type EnvVariant struct {
+    // Name of the variant, part of the result file names
+    Name string `json:"name"`
+    // 'KEY=VALUE' entries
+    Env []string `json:"env"`
+}
+
+// Jobs of the campaign to skip, empty/zero fields match any job.
+type JobFilter struct {
+    // Benchmark name or pattern, ex: osu_*bw
+    Benchmark string `json:"benchmark,omitempty"`
+    NP uint `json:"np,omitempty"`
+    PPN uint `json:"ppn,omitempty"`
+    MapBy string `json:"mapby,omitempty"`
+    BindTo string `json:"bindto,omitempty"`
+    Variant string `json:"variant,omitempty"`
+}
+
+// OSU options of all the benchmarks in the campaign, the counts that are
+// set are passed even when they are 0.
+type CampaignOptions struct {
+    MessageSize string `json:"messagesize"`
+    Iterations *uint `json:"iterations"`
+    Warmup *uint `json:"warmup"`
+    FullStats bool `json:"fullstats"`
+    MemLimit *uint64 `json:"memlimit"`
+}
+
+// Declarative form of the commandline, the campaign is expanded to the
+// same arguments and run the same way. Missing fields and empty values
+// keep the defaults, the counts that are set are passed even when they
+// are 0.
+type Campaign struct {
+    HostFile string `json:"hostfile"`
+    Benchmarks []string `json:"benchmarks"`
+    ExcludeBenchmarks []string `json:"excludebenchmarks"`
+    BenchmarkFile string `json:"benchmarkfile"`
+    OSUPrefix string `json:"osuprefix"`
+    // OSU options of all the benchmarks and per benchmark, zero options
+    // of a benchmark take the ones of all the benchmarks
+    Options CampaignOptions `json:"options"`
+    BenchOptions map[string]OSUOptions `json:"benchoptions"`
+    RMAWindow string `json:"rmawindow"`
+    RMASync string `json:"rmasync"`
+    Pairs *uint `json:"pairs"`
+    WindowSize *uint `json:"windowsize"`
+    Launcher string `json:"launcher"`
+    LauncherArgs []string `json:"launcherargs"`
+    BenchLauncherArgs map[string][]string `json:"benchlauncherargs"`
+    Env []string `json:"env"`
+    BenchEnv map[string][]string `json:"benchenv"`
+    EnvVariants []EnvVariant `json:"envvariants"`
+    // Process counts, processes per node, mappings and bindings, more
+    // than one value is a sweep
+    NP []uint `json:"np"`
+    PPN []uint `json:"ppn"`
+    MapBy []string `json:"mapby"`
+    BindTo []string `json:"bindto"`
+    PairMatrix bool `json:"pairmatrix"`
+    // Number of jobs run at a time on disjoint hosts
+    Parallel *uint `json:"parallel"`
+    // Host telemetry interval, ex: 1s
+    Telemetry string `json:"telemetry"`
+    Repeat *uint `json:"repeat"`
+    // Deadlines, ex: 30m
+    Timeout string `json:"timeout"`
+    BenchTimeout map[string]string `json:"benchtimeout"`
+    // Jobs of the cartesian product to skip
+    Exclude []JobFilter `json:"exclude"`
+    // Output settings
+    Progress bool `json:"progress"`
+    HistoryFile string `json:"historyfile"`
+    MetricDir string `json:"metricdir"`
+    ResultsRoot string `json:"resultsroot"`
+    ResultLayout string `json:"resultlayout"`
+    Loglevel *int64 `json:"loglevel"`
+}
+
+var variantNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Variant name goes in the result file names, letters, digits, '_' and '-'.
+func IsValidVariantName(name string) bool {
+    return variantNameRegex.MatchString(name)
+}
+
+// Add the environment entry to the variant, the variant is created on its
+// first entry.
+func (config *AppConfig)addEnvVariant(name string, env string) {
+    for idx := range config.EnvVariants {
+        if config.EnvVariants[idx].Name == name {
+            config.EnvVariants[idx].Env = append(config.EnvVariants[idx].Env,
+                                                 env)
+            return
+        }
+    }
+    config.EnvVariants = append(config.EnvVariants,
+                                EnvVariant{name, []string{env}})
+}
+
+// Environment variants to run the benchmarks with, empty is the global
+// environment only.
+func (config *AppConfig) GetEnvVariantList() []string {
+    if len(config.EnvVariants) == 0 {
+        return []string{""}
+    }
+    names := make([]string, 0, len(config.EnvVariants))
+    for _, envVariant := range config.EnvVariants {
+        names = append(names, envVariant.Name)
+    }
+    return names
+}
+
+// Check if the job is excluded from the campaign.
+func (config *AppConfig) IsJobExcluded(benchName string, np uint, ppn uint,
+                                       mapBy string, bindTo string,
+                                       variant string) bool {
+    for _, filter := range config.ExcludeJobs {
+        if filter.Match(benchName, np, ppn, mapBy, bindTo, variant) {
+            return true
+        }
+    }
+    return false
+}
+
+// Parse a job filter 'key=value,...', keys are benchmark, np, ppn, mapby,
+// bindto and variant. ex: benchmark=osu_alltoall,np=2
+func ParseJobFilter(filter string) (JobFilter, error) {
+    var jobFilter JobFilter
+    entries := SplitList(filter)
+    if len(entries) == 0 {
+        return jobFilter, errors.INVALID_INPUT
+    }
+    for _, entry := range entries {
+        parts := strings.SplitN(entry, "=", 2)
+        if len(parts) != 2 || len(parts[1]) == 0 {
+            return jobFilter, errors.INVALID_INPUT
+        }
+        var num uint64
+        var err error
+        switch parts[0] {
+        case "benchmark":
+            if _, err = path.Match(parts[1], ""); err != nil {
+                return jobFilter, errors.INVALID_INPUT
+            }
+            jobFilter.Benchmark = parts[1]
+        case "np":
+            num, err = strconv.ParseUint(parts[1], 10, 32)
+            jobFilter.NP = uint(num)
+        case "ppn":
+            num, err = strconv.ParseUint(parts[1], 10, 32)
+            jobFilter.PPN = uint(num)
+        case "mapby":
+            jobFilter.MapBy = parts[1]
+        case "bindto":
+            jobFilter.BindTo = parts[1]
+        case "variant":
+            jobFilter.Variant = parts[1]
+        default:
+            return jobFilter, errors.INVALID_INPUT
+        }
+        if err != nil {
+            return jobFilter, errors.INVALID_INPUT
+        }
+    }
+    return jobFilter, errors.OP_SUCCESS
+}
+
+// Filter in the -exclude-job form.
+func (filter JobFilter) String() string {
+    entries := make([]string, 0)
+    add := func(key string, value string) {
+        if len(value) != 0 && value != "0" {
+            entries = append(entries, key + "=" + value)
+        }
+    }
+    add("benchmark", filter.Benchmark)
+    add("np", fmt.Sprint(filter.NP))
+    add("ppn", fmt.Sprint(filter.PPN))
+    add("mapby", filter.MapBy)
+    add("bindto", filter.BindTo)
+    add("variant", filter.Variant)
+    return strings.Join(entries, ",")
+}
+
+func (filter JobFilter) Match(benchName string, np uint, ppn uint,
+                              mapBy string, bindTo string,
+                              variant string) bool {
+    if len(filter.Benchmark) != 0 {
+        if matched, _ := path.Match(filter.Benchmark, benchName); !matched {
+            return false
+        }
+    }
+    return (filter.NP == 0 || filter.NP == np) &&
+           (filter.PPN == 0 || filter.PPN == ppn) &&
+           (len(filter.MapBy) == 0 || filter.MapBy == mapBy) &&
+           (len(filter.BindTo) == 0 || filter.BindTo == bindTo) &&
+           (len(filter.Variant) == 0 || filter.Variant == variant)
+}
+
+// OSU options as commandline arguments of the benchmark.
+func (opts OSUOptions) Args() []string {
+    args := make([]string, 0)
+    if len(opts.MessageSize) != 0 {
+        args = append(args, "-m", opts.MessageSize)
+    }
+    if opts.Iterations != 0 {
+        args = append(args, "-i", fmt.Sprint(opts.Iterations))
+    }
+    if opts.Warmup != 0 {
+        args = append(args, "-x", fmt.Sprint(opts.Warmup))
+    }
+    if opts.FullStats {
+        args = append(args, "-f")
+    }
+    if opts.MemLimit != 0 {
+        args = append(args, "-M", fmt.Sprint(opts.MemLimit))
+    }
+    return args
+}
+
+// Read the campaign file, unknown fields are rejected to catch the typos.
+func ReadCampaign(fileName string) (*Campaign, error) {
+    jsonBytes, err := ioutil.ReadFile(fileName)
+    if err != nil {
+        return nil, err
+    }
+    decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+    decoder.DisallowUnknownFields()
+    campaign := new(Campaign)
+    if err = decoder.Decode(campaign); err != nil {
+        return nil, err
+    }
+    return campaign, errors.OP_SUCCESS
+}
+
+func format_counts(counts []uint) []string {
+    entries := make([]string, 0, len(counts))
+    for _, count := range counts {
+        entries = append(entries, fmt.Sprint(count))
+    }
+    return entries
+}
+
+func sorted_keys(values interface{}) []string {
+    keys := make([]string, 0)
+    switch values := values.(type) {
+    case map[string]OSUOptions:
+        for key := range values {
+            keys = append(keys, key)
+        }
+    case map[string][]string:
+        for key := range values {
+            keys = append(keys, key)
+        }
+    case map[string]string:
+        for key := range values {
+            keys = append(keys, key)
+        }
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// Commandline arguments of the campaign, they are validated the same way
+// as the commandline.
+func (campaign *Campaign) Args() ([]string, error) {
+    args := make([]string, 0)
+    add := func(flagName string, value string) {
+        if len(value) != 0 {
+            args = append(args, "-" + flagName, value)
+        }
+    }
+    // Counts that are set, nil keeps the default.
+    addCount := func(flagName string, value interface{}) {
+        switch value := value.(type) {
+        case *uint:
+            if value != nil {
+                args = append(args, "-" + flagName, fmt.Sprint(*value))
+            }
+        case *uint64:
+            if value != nil {
+                args = append(args, "-" + flagName, fmt.Sprint(*value))
+            }
+        case *int64:
+            if value != nil {
+                args = append(args, "-" + flagName, fmt.Sprint(*value))
+            }
+        }
+    }
+    // A single value runs without a sweep, the file names stay short.
+    addSweep := func(flagName string, sweepFlag string, values []string) {
+        if len(values) == 1 {
+            add(flagName, values[0])
+        } else if len(values) > 1 {
+            add(sweepFlag, strings.Join(values, ","))
+        }
+    }
+    add("f", campaign.HostFile)
+    add("benchmarks", strings.Join(campaign.Benchmarks, ","))
+    add("exclude-benchmarks", strings.Join(campaign.ExcludeBenchmarks, ","))
+    add("benchmark-file", campaign.BenchmarkFile)
+    add("osu-prefix", campaign.OSUPrefix)
+    add("message-size", campaign.Options.MessageSize)
+    addCount("iterations", campaign.Options.Iterations)
+    addCount("warmup", campaign.Options.Warmup)
+    if campaign.Options.FullStats {
+        args = append(args, "-full-stats")
+    }
+    addCount("mem-limit", campaign.Options.MemLimit)
+    for _, name := range sorted_keys(campaign.BenchOptions) {
+        add("bench-opts", name + ":" +
+            strings.Join(campaign.BenchOptions[name].Args(), " "))
+    }
+    add("rma-window", campaign.RMAWindow)
+    add("rma-sync", campaign.RMASync)
+    addCount("pairs", campaign.Pairs)
+    addCount("window-size", campaign.WindowSize)
+    add("launcher", campaign.Launcher)
+    add("launcher-args", strings.Join(campaign.LauncherArgs, " "))
+    for _, name := range sorted_keys(campaign.BenchLauncherArgs) {
+        add("bench-launcher-args", name + ":" +
+            strings.Join(campaign.BenchLauncherArgs[name], " "))
+    }
+    for _, env := range campaign.Env {
+        add("env", env)
+    }
+    for _, name := range sorted_keys(campaign.BenchEnv) {
+        for _, env := range campaign.BenchEnv[name] {
+            add("bench-env", name + ":" + env)
+        }
+    }
+    for _, envVariant := range campaign.EnvVariants {
+        if len(envVariant.Env) == 0 {
+            return nil, errors.INVALID_INPUT
+        }
+        for _, env := range envVariant.Env {
+            add("env-variant", envVariant.Name + ":" + env)
+        }
+    }
+    addSweep("c", "np-sweep", format_counts(campaign.NP))
+    addSweep("ppn", "ppn-sweep", format_counts(campaign.PPN))
+    addSweep("map-by", "map-by-sweep", campaign.MapBy)
+    addSweep("bind-to", "bind-to-sweep", campaign.BindTo)
+    if campaign.PairMatrix {
+        args = append(args, "-pair-matrix")
+    }
+    addCount("parallel", campaign.Parallel)
+    add("telemetry", campaign.Telemetry)
+    addCount("repeat", campaign.Repeat)
+    add("timeout", campaign.Timeout)
+    for _, name := range sorted_keys(campaign.BenchTimeout) {
+        add("bench-timeout", name + ":" + campaign.BenchTimeout[name])
+    }
+    for _, filter := range campaign.Exclude {
+        if len(filter.String()) == 0 {
+            // Would exclude every job.
+            return nil, errors.INVALID_INPUT
+        }
+        add("exclude-job", filter.String())
+    }
+    if campaign.Progress {
+        args = append(args, "-progress")
+    }
+    add("history-file", campaign.HistoryFile)
+    add("metric-dir", campaign.MetricDir)
+    add("results-root", campaign.ResultsRoot)
+    add("result-layout", campaign.ResultLayout)
+    addCount("l", campaign.Loglevel)
+    return args, errors.OP_SUCCESS
+}
@@ -4,12 +4,46 @@ import (
     "os"
     "fmt"
     "flag"
+    "encoding/json"
+    "io/ioutil"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
     "os/exec"
     "ec2-osu-benchmark/logging"
     "ec2-osu-benchmark/errors"
 )
 
 
+// Options passed to the OSU benchmark binaries. Zero value of a field
+// leaves the OSU default in place.
+type OSUOptions struct {
+    // Message size range(-m), ex: "1:65536"
+    MessageSize string `json:"messagesize,omitempty"`
+    // Number of iterations(-i)
+    Iterations uint `json:"iterations,omitempty"`
+    // Number of warmup iterations(-x)
+    Warmup uint `json:"warmup,omitempty"`
+    // Report full statistics(-f), only for collective benchmarks
+    FullStats bool `json:"fullstats,omitempty"`
+    // Per process maximum memory consumption(-M) in bytes
+    MemLimit uint64 `json:"memlimit,omitempty"`
+}
+
+// Flag value that can be given multiple times in the commandline.
+type StringList []string
+
+func (list *StringList) String() string {
+    return strings.Join(*list, ",")
+}
+
+func (list *StringList) Set(value string) error {
+    *list = append(*list, value)
+    return nil
+}
+
 type AppConfig struct {
     HostName string
     // Number of cores/processes to run the benchmark testing
@@ -19,6 +53,101 @@ type AppConfig struct {
     Region string // Region at which the instance belongs to
     LogFile string 
     Loglevel int64
+    // Benchmark names/patterns to run, empty to run the default set
+    Benchmarks []string
+    // Benchmark names/patterns to skip
+    ExcludeBenchmarks []string
+    // File with extra benchmarks for the registry
+    BenchmarkFile string
+    // OSU installation to run the benchmarks from, empty to discover it
+    // from OSU_HOME and the well-known prefixes
+    OSUPrefix string
+    // List the benchmarks in the registry and exit
+    ListBenchmarks bool
+    // Window creation(-w) for one-sided benchmarks, empty for OSU default
+    RMAWindow string
+    // Synchronization(-s) for one-sided benchmarks, empty for OSU default
+    RMASync string
+    // Number of pairs(-p) for osu_mbw_mr, 0 for OSU default(np/2)
+    Pairs uint
+    // Window size(-W) for osu_mbw_mr, 0 for OSU default
+    WindowSize uint
+    // OSU options for all the benchmarks
+    OSUOpts OSUOptions
+    // OSU options per benchmark, overrides the global options
+    BenchOSUOpts map[string]OSUOptions
+    // MPI launcher to run the benchmarks, one of LauncherTypes
+    Launcher string
+    // Processes per node, 0 for the launcher default
+    PPN uint
+    // Process binding, one of BindTypes, empty for the launcher default
+    BindTo string
+    // Process mapping, one of MapTypes or a launcher specific policy with
+    // ':'(ppr:1:socket), empty for the launcher default
+    MapBy string
+    // Mapping and binding policies to sweep, empty when the benchmarks run
+    // only with MapBy and BindTo
+    MapBySweep []string
+    BindToSweep []string
+    // Extra arguments to the launcher, ex: --mca btl_tcp_if_include eth0
+    LauncherArgs []string
+    // Extra launcher arguments per benchmark, added after the global ones
+    BenchLauncherArgs map[string][]string
+    // Environment for all the ranks, 'KEY=VALUE' entries
+    Env []string
+    // Environment per benchmark, overrides the global environment
+    BenchEnv map[string][]string
+    // Deadline of a benchmark run, 0 for no deadline
+    Timeout time.Duration
+    // Deadline per benchmark, overrides the global deadline
+    BenchTimeout map[string]time.Duration
+    // Number of times every benchmark is run
+    Repeat uint
+    // Process counts and processes per node to sweep, empty when the
+    // benchmarks run only with MPIcount and PPN
+    NPSweep []uint
+    PPNSweep []uint
+    // Run osu_latency/osu_bw between every pair of hosts in the hostfile
+    PairMatrix bool
+    // Number of jobs run at a time on disjoint hosts of the hostfile
+    Parallel uint
+    // Interval of the host telemetry samples taken while the benchmarks
+    // run, 0 when the telemetry is not sampled
+    Telemetry time.Duration
+    // Print the progress of the benchmarks to stderr
+    Progress bool
+    // Durations of the previous runs, used for the estimated time left
+    HistoryFile string
+    // Print the commands and files of the run without running anything
+    DryRun bool
+    // Format of the dry run output, one of DryRunFormats
+    DryRunFormat string
+    // Directory of the matric files
+    MetricDir string
+    // Directory the result directories are created in, and the path of a
+    // result directory in it, see ResultLayoutKeys
+    ResultsRoot string
+    ResultLayout string
+    // Run only the preflight checks of the hosts and exit
+    Preflight bool
+    // Start the benchmarks without the preflight checks
+    SkipPreflight bool
+    // Start the benchmarks even when the preflight checks fail
+    Force bool
+    // Environment variants, every benchmark is run with each of them
+    EnvVariants []EnvVariant
+    // Jobs of the campaign to skip
+    ExcludeJobs []JobFilter
+    // Campaign file the configuration is read from
+    CampaignFile string
+    // Plan of an earlier run to reproduce, the jobs must match the plan
+    PlanFile string
+    // Result directory of the interrupted campaign to continue, empty for
+    // a new campaign
+    Resume string
+    // Commandline of the campaign, kept in the campaign state so that a
+    // resumed campaign runs with the same configuration
+    Args []string
 }
 
 const (
@@ -27,13 +156,76 @@ const (
     DEFAULT_LOG_FILE = DEFAULT_PATH + "osu-test.log"
     DEFAULT_MPI_COUNT = 2
     DEFAULT_MPI_HOSTFILE = DEFAULT_PATH + "hostfile"
-    DEFAULT_TIME_LAYOUT = "2006-01-02T15:04:05.999999-07:00"
+    DEFAULT_HISTORY_FILE = DEFAULT_PATH + "osu-benchmark-history.json"
+    DEFAULT_REPORT_FILE = "osu-report.json"
+    // State of the campaign in the result directory
+    CAMPAIGN_STATE_FILE = "campaign-state.json"
+    // Expanded plan of the campaign in the result directory
+    PLAN_FILE = "plan.json"
+    // Environment of the run in the result directory
+    MANIFEST_FILE = "manifest.json"
+    // Run ID and start time in the result directory
+    RUN_FILE = "run.json"
+    // Result directory in the results root, ex: 2026-10-18/01JA2B...
+    DEFAULT_RESULT_LAYOUT = "{date}/{runid}"
     DEFAULT_REGION = "CMH52-CELL02340001"
     DEFAULT_APOLLO_ENV_DIR = "/apollo/env/OSU-MPI/monitoring/metricagent/"
+    DEFAULT_MATRIC_OUTPUT_FILE_NAME = "service_log."
+    // Environment with the OSU installation prefix
+    OSU_HOME_ENV = "OSU_HOME"
     DEFAULT_MATRIC_OUTPUT_FILE_PREFIX = DEFAULT_APOLLO_ENV_DIR +
-                                        "service_log."
+                                        DEFAULT_MATRIC_OUTPUT_FILE_NAME
+)
+
+// MPI launchers
+const (
+    LAUNCHER_AUTO = "auto"
+    LAUNCHER_OPENMPI = "openmpi"
+    LAUNCHER_MPICH = "mpich"
+    LAUNCHER_INTELMPI = "intel"
+    LAUNCHER_SLURM = "slurm"
 )
 
+var LauncherTypes = []string {LAUNCHER_AUTO, LAUNCHER_OPENMPI, LAUNCHER_MPICH,
+                              LAUNCHER_INTELMPI, LAUNCHER_SLURM}
+var DryRunFormats = []string {"text", "json"}
+var BindTypes = []string {"core", "socket", "numa", "hwthread", "none"}
+var MapTypes = []string {"slot", "hwthread", "core", "socket", "numa", "node"}
+
+// Flags that can be given with -campaign, -plan and -resume, they don't
+// change the jobs of the campaign.
+var RunFlags = []string {"l", "loglevel", "progress", "history-file",
+                         "metric-dir", "dry-run", "dry-run-format",
+                         "skip-preflight", "force", "results-root",
+                         "result-layout"}
+
+// Keys of the result layout, replaced with the run ID, the start date of
+// the run(UTC), the host name and the process ID. The layout must have
+// the run ID so that every run gets its own directory.
+var ResultLayoutKeys = []string {"{runid}", "{date}", "{host}", "{pid}"}
+
+// Window creation and synchronization options of OSU one-sided benchmarks.
+var RMAWindowTypes = []string {"create", "allocate", "dynamic"}
+var RMASyncTypes = []string {"lock", "flush", "flush_local", "lock_all",
+                             "pscw", "fence"}
+
+// Version and git commit of the tool, set at build time with
+// -ldflags "-X ec2-osu-benchmark/config.GitCommit=<commit>", see Makefile
+var ToolVersion = "dev"
+var GitCommit = "unknown"
+
+// Public host name of the EC2 instance from the instance metadata, tests
+// replace it to keep off the network.
+var GetPublicHostName = func() (string, error) {
+    awsFindDNSCmd := "curl -s --connect-timeout 2 " +
+                    "http://169.254.169.254/latest/meta-data/public-hostname"
+    res, err := exec.Command("sh","-c", awsFindDNSCmd).Output()
+    if err != nil {
+        return "", err
+    }
+    return string(res), errors.OP_SUCCESS
+}
+
 func (config *AppConfig)printHelp() {
     helpstr := "\n\t OSU benchmark test running on EC2 instances" +
            "\n\t Running OSU MPI benchmark tests on EC2 instances " +
@@ -48,6 +240,83 @@ func (config *AppConfig)printHelp() {
            "\n\t                                              2. Info" +
            "\n\t                                              3. Warning" +
            "\n\t                                              4. Error" +
+           "\n\t    -benchmarks <list>                      :- Comma separated benchmark names/patterns to run" +
+           "\n\t                                              ex: osu_latency,osu_*bw,/path/to/osu_binary" +
+           "\n\t    -exclude-benchmarks <list>              :- Comma separated benchmark names/patterns to skip" +
+           "\n\t    -benchmark-file <file>                  :- File with extra benchmarks," +
+           "\n\t                                              one '<name> <path> [category]' per line" +
+           "\n\t    -osu-prefix <dir>                       :- OSU installation to run the benchmarks from" +
+           "\n\t                                              (Default :$" + OSU_HOME_ENV + " or the well-known prefixes)" +
+           "\n\t    -list-benchmarks                        :- List the available benchmarks and exit" +
+           "\n\t    -launcher <launcher>                    :- MPI launcher to run the benchmarks(Default :auto)" +
+           "\n\t                                              auto/openmpi/mpich/intel/slurm" +
+           "\n\t    -ppn <count>                            :- MPI processes per node" +
+           "\n\t    -bind-to <policy>                       :- Process binding core/socket/numa/hwthread/none" +
+           "\n\t    -map-by <policy>                        :- Process mapping slot/hwthread/core/socket/numa/node" +
+           "\n\t                                              or a launcher specific policy, ex: ppr:1:socket" +
+           "\n\t    -bind-to-sweep <list>                   :- Process bindings to sweep, overrides -bind-to" +
+           "\n\t    -map-by-sweep <list>                    :- Process mappings to sweep, overrides -map-by" +
+           "\n\t                                              ex: -map-by-sweep core,socket,node" +
+           "\n\t                                              The binding report of the launcher is kept in the" +
+           "\n\t                                              run records when a binding/mapping is given" +
+           "\n\t    -launcher-args <args>                   :- Extra arguments to the MPI launcher" +
+           "\n\t                                              ex: -launcher-args '--mca btl_tcp_if_include eth0'" +
+           "\n\t    -bench-launcher-args <benchmark>:<args> :- Extra launcher arguments for a benchmark, can be repeated" +
+           "\n\t    -env <KEY=VALUE>                        :- Environment for all the ranks, can be repeated" +
+           "\n\t                                              ex: -env FI_PROVIDER=efa" +
+           "\n\t    -bench-env <benchmark>:<KEY=VALUE>      :- Environment for a benchmark, can be repeated" +
+           "\n\t    -np-sweep <list>                        :- Process counts to sweep, overrides -mpicount" +
+           "\n\t                                              ex: 2,4,8 or 2-32(doubling) or 2-16:2(step)" +
+           "\n\t    -ppn-sweep <list>                       :- Processes per node to sweep, overrides -ppn" +
+           "\n\t    -pair-matrix                            :- Run osu_latency and osu_bw between every pair of hosts" +
+           "\n\t                                              in the hostfile and report the host matrix" +
+           "\n\t    -parallel <count>                       :- Number of jobs run at a time on disjoint hosts of the" +
+           "\n\t                                              hostfile(Default :1), ex: -pair-matrix -parallel 8" +
+           "\n\t    -telemetry <interval>                   :- Sample load, CPU time, interrupts, network and CPU" +
+           "\n\t                                              frequency of this host while each benchmark runs, ex: 1s" +
+           "\n\t    -progress                               :- Print benchmark progress and estimated time left" +
+           "\n\t    -history-file <file>                    :- Durations of previous runs for the estimate" +
+           "\n\t                                              (Default :" + DEFAULT_HISTORY_FILE + ")" +
+           "\n\t    -dry-run                                :- Print the commands and files of the run and exit" +
+           "\n\t    -dry-run-format <format>                :- Format of the dry run output text/json(Default :text)" +
+           "\n\t    -metric-dir <dir>                       :- Directory of the matric files" +
+           "\n\t                                              (Default :" + DEFAULT_APOLLO_ENV_DIR + ")" +
+           "\n\t    -results-root <dir>                     :- Directory of the result directories(Default :" + DEFAULT_PATH + ")" +
+           "\n\t    -result-layout <template>               :- Result directory in the results root, with the keys" +
+           "\n\t                                              " + strings.Join(ResultLayoutKeys, " ") +
+           "\n\t                                              (Default :" + DEFAULT_RESULT_LAYOUT + ")" +
+           "\n\t    -preflight                              :- Check the binaries, versions, slots and clocks of all" +
+           "\n\t                                              the hosts through the launcher and exit" +
+           "\n\t    -skip-preflight                         :- Start the benchmarks without the preflight checks" +
+           "\n\t    -force                                  :- Start the benchmarks even when the preflight checks fail" +
+           "\n\t    -resume <dir>                           :- Continue the interrupted campaign of a result directory" +
+           "\n\t                                              with its original arguments, only the successful runs are kept" +
+           "\n\t    -env-variant <name>:<KEY=VALUE>         :- Environment of a variant, can be repeated, every" +
+           "\n\t                                              benchmark is run with each variant" +
+           "\n\t                                              ex: -env-variant efa:FI_PROVIDER=efa -env-variant tcp:FI_PROVIDER=tcp" +
+           "\n\t    -exclude-job <key=value,...>            :- Jobs to skip, can be repeated, keys are" +
+           "\n\t                                              benchmark(pattern)/np/ppn/mapby/bindto/variant" +
+           "\n\t                                              ex: -exclude-job benchmark=osu_alltoall,np=2" +
+           "\n\t    -campaign <file>                        :- JSON campaign file with the benchmarks, options, sweeps," +
+           "\n\t                                              variants and exclusions of the run" +
+           "\n\t    -plan <file>                            :- Reproduce the run of a " + PLAN_FILE + " from its result directory" +
+           "\n\t    -repeat <count>                         :- Number of times every benchmark is run(Default :1)" +
+           "\n\t    -timeout <duration>                     :- Deadline of a benchmark run, ex: 30m(Default :no deadline)" +
+           "\n\t    -bench-timeout <benchmark>:<duration>   :- Deadline for a benchmark, can be repeated" +
+           "\n\t                                              ex: -bench-timeout osu_alltoall:2h" +
+           "\n\t    -rma-window <type>                      :- Window creation of one-sided benchmarks" +
+           "\n\t                                              create/allocate/dynamic" +
+           "\n\t    -rma-sync <type>                        :- Synchronization of one-sided benchmarks" +
+           "\n\t                                              lock/flush/flush_local/lock_all/pscw/fence" +
+           "\n\t    -pairs <count>                          :- Number of pairs for osu_mbw_mr(Default :np/2)" +
+           "\n\t    -window-size <count>                    :- Window size for osu_mbw_mr(Default :64)" +
+           "\n\t    -message-size <min:max>                 :- Message size range of the benchmarks(-m)" +
+           "\n\t    -iterations <count>                     :- Number of iterations of the benchmarks(-i)" +
+           "\n\t    -warmup <count>                         :- Number of warmup iterations of the benchmarks(-x)" +
+           "\n\t    -full-stats                             :- Report min/max latency and iterations(-f)" +
+           "\n\t    -mem-limit <bytes>                      :- Per process memory limit of the benchmarks(-M)" +
+           "\n\t    -bench-opts <benchmark>:<options>       :- OSU options for a benchmark, can be repeated" +
+           "\n\t                                              ex: -bench-opts 'osu_bw:-m 1:4096 -i 100'" +
            "\n\n"
     fmt.Print(helpstr)
 }
@@ -73,7 +342,90 @@ func (config *AppConfig)InitConfig() error{
                                 "loglevel for the application")
     loglevellong := flag.Int64("loglevel", DEFAULT_LOG_LEVEL,
                                "loglevel for the application")
+    benchmarks := flag.String("benchmarks", "",
+                              "Comma separated benchmark names/patterns to run")
+    excludeBenchmarks := flag.String("exclude-benchmarks", "",
+                              "Comma separated benchmark names/patterns to skip")
+    benchmarkFile := flag.String("benchmark-file", "",
+                                 "File with extra benchmarks")
+    osuPrefix := flag.String("osu-prefix", "", "OSU installation")
+    listBenchmarks := flag.Bool("list-benchmarks", false,
+                                "List the available benchmarks and exit")
+    rmaWindow := flag.String("rma-window", "",
+                             "Window creation of one-sided benchmarks")
+    rmaSync := flag.String("rma-sync", "",
+                           "Synchronization of one-sided benchmarks")
+    pairs := flag.Uint("pairs", 0, "Number of pairs for osu_mbw_mr")
+    windowSize := flag.Uint("window-size", 0, "Window size for osu_mbw_mr")
+    messageSize := flag.String("message-size", "",
+                               "Message size range of the benchmarks")
+    iterations := flag.Uint("iterations", 0,
+                            "Number of iterations of the benchmarks")
+    warmup := flag.Uint("warmup", 0,
+                        "Number of warmup iterations of the benchmarks")
+    fullStats := flag.Bool("full-stats", false,
+                           "Report min/max latency and iterations")
+    memLimit := flag.Uint64("mem-limit", 0,
+                            "Per process memory limit of the benchmarks")
+    var benchOpts StringList
+    flag.Var(&benchOpts, "bench-opts", "OSU options for a benchmark")
+    launcher := flag.String("launcher", LAUNCHER_AUTO,
+                            "MPI launcher to run the benchmarks")
+    ppn := flag.Uint("ppn", 0, "MPI processes per node")
+    bindTo := flag.String("bind-to", "", "Process binding")
+    mapBy := flag.String("map-by", "", "Process mapping")
+    bindToSweep := flag.String("bind-to-sweep", "", "Process bindings to sweep")
+    mapBySweep := flag.String("map-by-sweep", "", "Process mappings to sweep")
+    launcherArgs := flag.String("launcher-args", "",
+                                "Extra arguments to the MPI launcher")
+    var benchLauncherArgs StringList
+    flag.Var(&benchLauncherArgs, "bench-launcher-args",
+             "Extra launcher arguments for a benchmark")
+    var envs StringList
+    flag.Var(&envs, "env", "Environment for all the ranks")
+    var benchEnvs StringList
+    flag.Var(&benchEnvs, "bench-env", "Environment for a benchmark")
+    timeout := flag.Duration("timeout", 0, "Deadline of a benchmark run")
+    var benchTimeouts StringList
+    flag.Var(&benchTimeouts, "bench-timeout", "Deadline for a benchmark")
+    repeat := flag.Uint("repeat", 1, "Number of times every benchmark is run")
+    npSweep := flag.String("np-sweep", "", "Process counts to sweep")
+    ppnSweep := flag.String("ppn-sweep", "", "Processes per node to sweep")
+    pairMatrix := flag.Bool("pair-matrix", false, "Run the host pair matrix")
+    parallel := flag.Uint("parallel", 1, "Number of jobs run at a time")
+    telemetry := flag.Duration("telemetry", 0, "Host telemetry interval")
+    progress := flag.Bool("progress", false, "Print benchmark progress")
+    resultsRoot := flag.String("results-root", DEFAULT_PATH,
+                               "Directory of the result directories")
+    resultLayout := flag.String("result-layout", DEFAULT_RESULT_LAYOUT,
+                                "Result directory in the results root")
+    metricDir := flag.String("metric-dir", DEFAULT_APOLLO_ENV_DIR,
+                             "Directory of the matric files")
+    dryRun := flag.Bool("dry-run", false, "Print the commands of the run")
+    dryRunFormat := flag.String("dry-run-format", "text",
+                                "Format of the dry run output")
+    historyFile := flag.String("history-file", DEFAULT_HISTORY_FILE,
+                               "Durations of previous runs")
+    preflight := flag.Bool("preflight", false, "Run the preflight checks")
+    skipPreflight := flag.Bool("skip-preflight", false,
+                               "Skip the preflight checks")
+    force := flag.Bool("force", false, "Run even if preflight checks fail")
+    resume := flag.String("resume", "", "Result directory of the campaign")
+    var envVariants StringList
+    flag.Var(&envVariants, "env-variant", "Environment of a variant")
+    var excludeJobs StringList
+    flag.Var(&excludeJobs, "exclude-job", "Jobs to skip")
+    campaign := flag.String("campaign", "", "Campaign file")
+    plan := flag.String("plan", "", "Plan of the run to reproduce")
     flag.Parse()
+    config.Args = os.Args[1:]
+    config.Resume = *resume
+    config.CampaignFile = *campaign
+    config.PlanFile = *plan
+    err = config.parseSourceArgs()
+    if err != errors.OP_SUCCESS {
+        return err
+    }
     config.MPIcount = *mpicountShort
     if config.MPIcount == DEFAULT_MPI_COUNT {
         config.MPIcount = *mpicountLong
@@ -87,8 +439,223 @@ func (config *AppConfig)InitConfig() error{
     if config.Loglevel == DEFAULT_LOG_LEVEL {
         config.Loglevel = *loglevellong
     }
+    if config.Loglevel < logging.Trace || config.Loglevel > logging.Error {
+        fmt.Printf("Invalid log level %d\n", config.Loglevel)
+        return errors.INVALID_INPUT
+    }
 
     config.LogFile = DEFAULT_LOG_FILE
+    config.Benchmarks = SplitList(*benchmarks)
+    config.ExcludeBenchmarks = SplitList(*excludeBenchmarks)
+    config.BenchmarkFile = *benchmarkFile
+    config.OSUPrefix = *osuPrefix
+    config.ListBenchmarks = *listBenchmarks
+    config.Launcher = *launcher
+    if !IsListMember(LauncherTypes, config.Launcher) {
+        fmt.Printf("Invalid MPI launcher %s\n", config.Launcher)
+        return errors.INVALID_INPUT
+    }
+    config.NPSweep, err = ParseSweep(*npSweep)
+    if err != errors.OP_SUCCESS {
+        fmt.Printf("Invalid process count sweep %s\n", *npSweep)
+        return err
+    }
+    config.PPNSweep, err = ParseSweep(*ppnSweep)
+    if err != errors.OP_SUCCESS {
+        fmt.Printf("Invalid processes per node sweep %s\n", *ppnSweep)
+        return err
+    }
+    config.PairMatrix = *pairMatrix
+    config.Progress = *progress
+    config.DryRun = *dryRun
+    config.MetricDir = *metricDir
+    if !strings.HasSuffix(config.MetricDir, "/") {
+        config.MetricDir += "/"
+    }
+    config.ResultsRoot = *resultsRoot
+    config.ResultLayout = *resultLayout
+    if err = ValidateResultLayout(config.ResultLayout);
+       err != errors.OP_SUCCESS {
+        fmt.Printf("Invalid result layout '%s', it must be a relative " +
+                   "path with {runid}\n", config.ResultLayout)
+        return err
+    }
+    config.DryRunFormat = *dryRunFormat
+    if !IsListMember(DryRunFormats, config.DryRunFormat) {
+        fmt.Printf("Invalid dry run format %s\n", config.DryRunFormat)
+        return errors.INVALID_INPUT
+    }
+    config.HistoryFile = *historyFile
+    config.Preflight = *preflight
+    config.SkipPreflight = *skipPreflight
+    config.Force = *force
+    if config.Preflight && config.SkipPreflight {
+        fmt.Print("-preflight cannot be combined with -skip-preflight\n")
+        return errors.INVALID_INPUT
+    }
+    if config.PairMatrix && config.IsSweep() {
+        fmt.Print("Host pair matrix cannot be combined with sweeps\n")
+        return errors.INVALID_INPUT
+    }
+    config.PPN = *ppn
+    if len(config.NPSweep) == 0 && len(config.PPNSweep) == 0 &&
+       config.PPN > config.MPIcount {
+        fmt.Printf("Cannot run %d processes per node with %d processes\n",
+                   config.PPN, config.MPIcount)
+        return errors.INVALID_INPUT
+    }
+    config.BindTo = *bindTo
+    config.BindToSweep = SplitList(*bindToSweep)
+    for _, bind := range config.GetBindToList() {
+        if len(bind) != 0 && !IsListMember(BindTypes, bind) {
+            fmt.Printf("Invalid process binding %s\n", bind)
+            return errors.INVALID_INPUT
+        }
+    }
+    config.MapBy = *mapBy
+    config.MapBySweep = SplitList(*mapBySweep)
+    for _, mapping := range config.GetMapByList() {
+        if len(mapping) != 0 && !IsValidMapBy(mapping) {
+            fmt.Printf("Invalid process mapping %s\n", mapping)
+            return errors.INVALID_INPUT
+        }
+    }
+    // A detected launcher is checked once it is detected.
+    if err := config.ValidateMapBy(config.Launcher); err != errors.OP_SUCCESS {
+        return err
+    }
+    if config.PairMatrix && config.IsPolicySweep() {
+        fmt.Print("Host pair matrix cannot be combined with policy sweeps\n")
+        return errors.INVALID_INPUT
+    }
+    config.EnvVariants = make([]EnvVariant, 0)
+    for _, envVariant := range envVariants {
+        name, env, err := SplitBenchValue(envVariant)
+        if err != errors.OP_SUCCESS || !IsValidVariantName(name) ||
+           !IsValidEnv(env) {
+            fmt.Printf("Invalid environment variant '%s'\n", envVariant)
+            return errors.INVALID_INPUT
+        }
+        config.addEnvVariant(name, env)
+    }
+    config.ExcludeJobs = make([]JobFilter, 0)
+    for _, excludeJob := range excludeJobs {
+        filter, err := ParseJobFilter(excludeJob)
+        if err != errors.OP_SUCCESS {
+            fmt.Printf("Invalid job exclusion '%s'\n", excludeJob)
+            return err
+        }
+        config.ExcludeJobs = append(config.ExcludeJobs, filter)
+    }
+    if config.PairMatrix && (len(config.EnvVariants) != 0 ||
+                             len(config.ExcludeJobs) != 0) {
+        fmt.Print("Host pair matrix cannot be combined with environment " +
+                  "variants or job exclusions\n")
+        return errors.INVALID_INPUT
+    }
+    config.LauncherArgs = strings.Fields(*launcherArgs)
+    config.BenchLauncherArgs = make(map[string][]string)
+    for _, benchArgs := range benchLauncherArgs {
+        name, args, err := SplitBenchValue(benchArgs)
+        if err != errors.OP_SUCCESS {
+            fmt.Printf("Invalid benchmark launcher arguments '%s'\n", benchArgs)
+            return err
+        }
+        config.BenchLauncherArgs[name] = append(config.BenchLauncherArgs[name],
+                                                strings.Fields(args)...)
+    }
+    for _, env := range envs {
+        if !IsValidEnv(env) {
+            fmt.Printf("Invalid environment '%s', expected KEY=VALUE\n", env)
+            return errors.INVALID_INPUT
+        }
+    }
+    config.Env = envs
+    config.BenchEnv = make(map[string][]string)
+    for _, benchEnv := range benchEnvs {
+        name, env, err := SplitBenchValue(benchEnv)
+        if err != errors.OP_SUCCESS || !IsValidEnv(env) {
+            fmt.Printf("Invalid benchmark environment '%s'\n", benchEnv)
+            return errors.INVALID_INPUT
+        }
+        config.BenchEnv[name] = append(config.BenchEnv[name], env)
+    }
+    config.Repeat = *repeat
+    if config.Repeat == 0 {
+        fmt.Print("Repeat count must be at least 1\n")
+        return errors.INVALID_INPUT
+    }
+    config.Parallel = *parallel
+    if config.Parallel == 0 {
+        fmt.Print("Parallel job count must be at least 1\n")
+        return errors.INVALID_INPUT
+    }
+    config.Telemetry = *telemetry
+    if config.Telemetry < 0 {
+        fmt.Print("Telemetry interval must be positive\n")
+        return errors.INVALID_INPUT
+    }
+    config.Timeout = *timeout
+    if config.Timeout < 0 {
+        fmt.Print("Benchmark timeout must be positive\n")
+        return errors.INVALID_INPUT
+    }
+    config.BenchTimeout = make(map[string]time.Duration)
+    for _, benchTimeout := range benchTimeouts {
+        name, value, err := SplitBenchValue(benchTimeout)
+        var duration time.Duration
+        if err == errors.OP_SUCCESS {
+            duration, err = time.ParseDuration(value)
+        }
+        if err != nil || duration <= 0 {
+            fmt.Printf("Invalid benchmark timeout '%s'\n", benchTimeout)
+            return errors.INVALID_INPUT
+        }
+        config.BenchTimeout[name] = duration
+    }
+    config.OSUOpts.MessageSize = *messageSize
+    config.OSUOpts.Iterations = *iterations
+    config.OSUOpts.Warmup = *warmup
+    config.OSUOpts.FullStats = *fullStats
+    config.OSUOpts.MemLimit = *memLimit
+    if !IsValidMessageSize(config.OSUOpts.MessageSize) {
+        fmt.Printf("Invalid message size range %s\n", config.OSUOpts.MessageSize)
+        return errors.INVALID_INPUT
+    }
+    config.BenchOSUOpts = make(map[string]OSUOptions)
+    for _, benchOpt := range benchOpts {
+        name, opts, err := ParseBenchOSUOptions(benchOpt)
+        if err != errors.OP_SUCCESS {
+            fmt.Printf("Invalid benchmark options '%s'\n", benchOpt)
+            return err
+        }
+        config.BenchOSUOpts[name] = opts
+    }
+    config.RMAWindow = *rmaWindow
+    if len(config.RMAWindow) != 0 &&
+       !IsListMember(RMAWindowTypes, config.RMAWindow) {
+        fmt.Printf("Invalid one-sided window creation %s\n", config.RMAWindow)
+        return errors.INVALID_INPUT
+    }
+    config.Pairs = *pairs
+    for _, np := range config.GetNPList() {
+        if config.Pairs * 2 > np {
+            fmt.Printf("Cannot run %d pairs with %d processes\n",
+                       config.Pairs, np)
+            return errors.INVALID_INPUT
+        }
+    }
+    config.WindowSize = *windowSize
+    config.RMASync = *rmaSync
+    if len(config.RMASync) != 0 &&
+       !IsListMember(RMASyncTypes, config.RMASync) {
+        fmt.Printf("Invalid one-sided synchronization %s\n", config.RMASync)
+        return errors.INVALID_INPUT
+    }
+    if config.ListBenchmarks {
+        // Nothing to run, no need to validate the MPI settings.
+        return errors.OP_SUCCESS
+    }
     // Check if hostfile exists in the filesystem
     if _, err := os.Stat(config.HostFile); os.IsNotExist(err) {
         fmt.Print("Hostfile is not present, cannot run tests \n")
@@ -100,22 +667,448 @@ func (config *AppConfig)InitConfig() error{
         config.Region = *regionLong
     }
 
+    // Dry run output goes to stdout, keep it clean for -dry-run-format json
+    banner := os.Stdout
+    if config.DryRun {
+        banner = os.Stderr
+    }
     // Populate the external host name of the instance
-    var res []byte
     config.HostName="localhost"
-    awsFindDNSCmd := "curl -s http://169.254.169.254/latest/meta-data/public-hostname"
-    res, err = exec.Command("sh","-c", awsFindDNSCmd).Output()
-    if err == nil {
-        config.HostName = string(res)
+    hostName, err := GetPublicHostName()
+    if err == errors.OP_SUCCESS {
+        config.HostName = hostName
     } else {
-        fmt.Printf("Failed to collect hostname of ec-2 instance err : %s", err)
+        fmt.Fprintf(banner,
+                    "Failed to collect hostname of ec-2 instance err : %s", err)
     }
-    fmt.Printf("\n*** Running test on %s with cores/processes : %d , hostfile : %s, " +
-               " Region %s, "+
+    fmt.Fprintf(banner, "\n*** Running test on %s with cores/processes : %d , hostfile : %s, " +
+               " Launcher %s, Region %s, "+
                "LogFile : %s, LogLevel %s ***\n",
                config.HostName,
                config.MPIcount, config.HostFile,
-               config.Region,
+               config.Launcher, config.Region,
                config.LogFile, logging.LogLevelStr[config.Loglevel - 1])
     return errors.OP_SUCCESS
-}
\ No newline at end of file
+}
+
+// Commandline of a campaign in the json file, the campaign state or the
+// plan of a result directory.
+func ReadArgs(fileName string) ([]string, error) {
+    var state struct {
+        Args []string `json:"args"`
+    }
+    jsonBytes, err := ioutil.ReadFile(fileName)
+    if err != nil {
+        return nil, err
+    }
+    if err = json.Unmarshal(jsonBytes, &state); err != nil {
+        return nil, err
+    }
+    if state.Args == nil {
+        return nil, errors.DATA_NOT_FOUND
+    }
+    return state.Args, errors.OP_SUCCESS
+}
+
+// Parse the commandline of the campaign file, the plan or the campaign to
+// resume, the flags given with them(RunFlags) override the ones of the
+// source.
+func (config *AppConfig)parseSourceArgs() error {
+    var source, fileName string
+    var args []string
+    var err error
+    sources := 0
+    if len(config.CampaignFile) != 0 {
+        source, fileName = "-campaign", config.CampaignFile
+        sources++
+    }
+    if len(config.PlanFile) != 0 {
+        source, fileName = "-plan", config.PlanFile
+        sources++
+    }
+    if len(config.Resume) != 0 {
+        source = "-resume"
+        fileName = filepath.Join(config.Resume, CAMPAIGN_STATE_FILE)
+        sources++
+    }
+    if sources == 0 {
+        return errors.OP_SUCCESS
+    }
+    if sources > 1 {
+        fmt.Print("Only one of -campaign, -plan and -resume can be given\n")
+        return errors.INVALID_INPUT
+    }
+    var invalid []string
+    flag.Visit(func(f *flag.Flag) {
+        if "-" + f.Name != source && !IsListMember(RunFlags, f.Name) {
+            invalid = append(invalid, "-" + f.Name)
+        }
+    })
+    if len(invalid) != 0 {
+        fmt.Printf("%s cannot be combined with %s, the jobs are given by " +
+                   "%s\n", strings.Join(invalid, ", "), source, fileName)
+        return errors.INVALID_INPUT
+    }
+    if source == "-campaign" {
+        var campaign *Campaign
+        campaign, err = ReadCampaign(fileName)
+        if err == errors.OP_SUCCESS {
+            args, err = campaign.Args()
+        }
+    } else {
+        args, err = ReadArgs(fileName)
+    }
+    if err != errors.OP_SUCCESS {
+        fmt.Printf("Failed to read the arguments of %s %s, err : %s\n",
+                   source, fileName, err)
+        return errors.INVALID_INPUT
+    }
+    if flag.CommandLine.Parse(args) != nil ||
+       flag.CommandLine.Parse(config.Args) != nil {
+        fmt.Printf("Invalid arguments in %s\n", fileName)
+        return errors.INVALID_INPUT
+    }
+    config.Args = args
+    return errors.OP_SUCCESS
+}
+
+// Split a comma separated list, empty entries are dropped.
+func SplitList(list string) []string {
+    entries := make([]string, 0)
+    for _, entry := range strings.Split(list, ",") {
+        entry = strings.TrimSpace(entry)
+        if len(entry) != 0 {
+            entries = append(entries, entry)
+        }
+    }
+    return entries
+}
+
+func IsListMember(list []string, entry string) bool {
+    for _, member := range list {
+        if member == entry {
+            return true
+        }
+    }
+    return false
+}
+
+var resultLayoutKeyRegex = regexp.MustCompile(`\{[^}]*\}`)
+
+// Result layout is a relative path with the run ID, ex: {date}/{runid}
+func ValidateResultLayout(layout string) error {
+    if !strings.Contains(layout, "{runid}") || filepath.IsAbs(layout) {
+        return errors.INVALID_INPUT
+    }
+    for _, key := range resultLayoutKeyRegex.FindAllString(layout, -1) {
+        if !IsListMember(ResultLayoutKeys, key) {
+            return errors.INVALID_INPUT
+        }
+    }
+    for _, elem := range strings.Split(layout, "/") {
+        if len(elem) == 0 || elem == "." || elem == ".." {
+            return errors.INVALID_INPUT
+        }
+    }
+    return errors.OP_SUCCESS
+}
+
+// Result directory of the run in the results root, with a trailing '/'.
+func ExpandResultLayout(root string, layout string, runID string,
+                        start time.Time) string {
+    host, err := os.Hostname()
+    if err != nil {
+        host = "localhost"
+    }
+    replacer := strings.NewReplacer("{runid}", runID,
+                                    "{date}", start.UTC().Format("2006-01-02"),
+                                    "{host}", host,
+                                    "{pid}", strconv.Itoa(os.Getpid()))
+    return filepath.Join(root, replacer.Replace(layout)) + "/"
+}
+
+// Check the process mappings can be run with the launcher. Open MPI takes
+// the processes per node as a mapping as well, the pair matrix runs a
+// process per node.
+func (config *AppConfig) ValidateMapBy(launcher string) error {
+    if launcher != LAUNCHER_OPENMPI {
+        return errors.OP_SUCCESS
+    }
+    ppnList := config.GetPPNList()
+    if config.PairMatrix {
+        ppnList = []uint{1}
+    }
+    for _, mapping := range config.GetMapByList() {
+        if len(mapping) == 0 {
+            continue
+        }
+        for _, ppn := range ppnList {
+            if ppn != 0 {
+                fmt.Print("Open MPI cannot take both -map-by and -ppn\n")
+                return errors.INVALID_INPUT
+            }
+        }
+    }
+    return errors.OP_SUCCESS
+}
+
+// Message size range is '<max>' or '<min>:<max>', empty for OSU default.
+func IsValidMessageSize(msgSize string) bool {
+    if len(msgSize) == 0 {
+        return true
+    }
+    for _, size := range strings.SplitN(msgSize, ":", 2) {
+        if len(size) == 0 {
+            continue
+        }
+        if _, err := strconv.ParseUint(size, 10, 64); err != nil {
+            return false
+        }
+    }
+    return true
+}
+
+// Split a per benchmark value '<benchmark>:<value>'.
+func SplitBenchValue(benchValue string) (string, string, error) {
+    parts := strings.SplitN(benchValue, ":", 2)
+    if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+        return "", "", errors.INVALID_INPUT
+    }
+    return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]),
+           errors.OP_SUCCESS
+}
+
+// Environment must be 'KEY=VALUE', VALUE can be empty.
+func IsValidEnv(env string) bool {
+    idx := strings.Index(env, "=")
+    return idx > 0 && !strings.ContainsAny(env[:idx], " \t")
+}
+
+// Parse the per benchmark OSU options '<benchmark>:<options>'.
+// ex: 'osu_allreduce:-m 4:1024 -i 100 -x 10 -f -M 1073741824'
+func ParseBenchOSUOptions(benchOpt string) (string, OSUOptions, error) {
+    var opts OSUOptions
+    name, value, err := SplitBenchValue(benchOpt)
+    if err != errors.OP_SUCCESS {
+        return "", opts, err
+    }
+    args := strings.Fields(value)
+    for idx := 0; idx < len(args); idx++ {
+        if args[idx] == "-f" {
+            opts.FullStats = true
+            continue
+        }
+        if idx + 1 >= len(args) {
+            return "", opts, errors.INVALID_INPUT
+        }
+        value := args[idx + 1]
+        idx++
+        var num uint64
+        var parseErr error
+        switch args[idx - 1] {
+        case "-m":
+            if !IsValidMessageSize(value) {
+                return "", opts, errors.INVALID_INPUT
+            }
+            opts.MessageSize = value
+        case "-i":
+            num, parseErr = strconv.ParseUint(value, 10, 32)
+            opts.Iterations = uint(num)
+        case "-x":
+            num, parseErr = strconv.ParseUint(value, 10, 32)
+            opts.Warmup = uint(num)
+        case "-M":
+            opts.MemLimit, parseErr = strconv.ParseUint(value, 10, 64)
+        default:
+            return "", opts, errors.INVALID_INPUT
+        }
+        if parseErr != nil {
+            return "", opts, errors.INVALID_INPUT
+        }
+    }
+    return name, opts, errors.OP_SUCCESS
+}
+
+// OSU options of a benchmark, per benchmark options override the
+// global ones.
+func (config *AppConfig) GetOSUOptions(benchName string) OSUOptions {
+    opts := config.OSUOpts
+    benchOpts, ok := config.BenchOSUOpts[benchName]
+    if !ok {
+        return opts
+    }
+    if len(benchOpts.MessageSize) != 0 {
+        opts.MessageSize = benchOpts.MessageSize
+    }
+    if benchOpts.Iterations != 0 {
+        opts.Iterations = benchOpts.Iterations
+    }
+    if benchOpts.Warmup != 0 {
+        opts.Warmup = benchOpts.Warmup
+    }
+    if benchOpts.FullStats {
+        opts.FullStats = true
+    }
+    if benchOpts.MemLimit != 0 {
+        opts.MemLimit = benchOpts.MemLimit
+    }
+    return opts
+}
+
+// Launcher arguments of a benchmark, global arguments followed by the
+// benchmark ones.
+func (config *AppConfig) GetLauncherArgs(benchName string) []string {
+    args := make([]string, 0, len(config.LauncherArgs))
+    args = append(args, config.LauncherArgs...)
+    return append(args, config.BenchLauncherArgs[benchName]...)
+}
+
+// Environment of a benchmark, benchmark entries override the global
+// entries with the same key.
+func (config *AppConfig) GetEnv(benchName string) []string {
+    return MergeEnv(config.Env, config.BenchEnv[benchName])
+}
+
+// Environment of a benchmark in the variant, the variant entries override
+// the global ones and the benchmark entries override both.
+func (config *AppConfig) GetVariantEnv(benchName string,
+                                       variant string) []string {
+    for _, envVariant := range config.EnvVariants {
+        if envVariant.Name == variant {
+            return MergeEnv(config.Env, envVariant.Env,
+                            config.BenchEnv[benchName])
+        }
+    }
+    return config.GetEnv(benchName)
+}
+
+// Merge the environments, later entries override the earlier entries with
+// the same key in place.
+func MergeEnv(envLists ...[]string) []string {
+    envs := make([]string, 0)
+    keyIdx := make(map[string]int)
+    for _, envList := range envLists {
+        for _, env := range envList {
+            key := strings.SplitN(env, "=", 2)[0]
+            if idx, ok := keyIdx[key]; ok {
+                envs[idx] = env
+                continue
+            }
+            keyIdx[key] = len(envs)
+            envs = append(envs, env)
+        }
+    }
+    return envs
+}
+
+// Deadline of a benchmark run, 0 for no deadline.
+func (config *AppConfig) GetTimeout(benchName string) time.Duration {
+    if timeout, ok := config.BenchTimeout[benchName]; ok {
+        return timeout
+    }
+    return config.Timeout
+}
+
+// Parse a sweep list, comma separated entries of
+//   <count>             ex: 4
+//   <min>-<max>         doubling from min to max, ex: 2-32
+//   <min>-<max>:<step>  min to max with the step, ex: 2-16:2
+// Duplicate counts are dropped, empty list gives an empty sweep.
+func ParseSweep(sweep string) ([]uint, error) {
+    counts := make([]uint, 0)
+    seen := make(map[uint]bool)
+    add := func(count uint64) {
+        if !seen[uint(count)] {
+            seen[uint(count)] = true
+            counts = append(counts, uint(count))
+        }
+    }
+    for _, entry := range SplitList(sweep) {
+        step := uint64(0)
+        if idx := strings.Index(entry, ":"); idx >= 0 {
+            var err error
+            step, err = strconv.ParseUint(entry[idx + 1:], 10, 32)
+            if err != nil || step == 0 {
+                return nil, errors.INVALID_INPUT
+            }
+            entry = entry[:idx]
+        }
+        bounds := strings.SplitN(entry, "-", 2)
+        low, err := strconv.ParseUint(bounds[0], 10, 32)
+        if err != nil || low == 0 {
+            return nil, errors.INVALID_INPUT
+        }
+        high := low
+        if len(bounds) == 2 {
+            high, err = strconv.ParseUint(bounds[1], 10, 32)
+            if err != nil || high < low {
+                return nil, errors.INVALID_INPUT
+            }
+        } else if step != 0 {
+            return nil, errors.INVALID_INPUT
+        }
+        for count := low; count <= high; {
+            add(count)
+            if step != 0 {
+                count += step
+            } else {
+                count *= 2
+            }
+        }
+    }
+    return counts, errors.OP_SUCCESS
+}
+
+// Process counts to run the benchmarks with.
+func (config *AppConfig) GetNPList() []uint {
+    if len(config.NPSweep) != 0 {
+        return config.NPSweep
+    }
+    return []uint{config.MPIcount}
+}
+
+// Processes per node to run the benchmarks with, 0 is the launcher default.
+func (config *AppConfig) GetPPNList() []uint {
+    if len(config.PPNSweep) != 0 {
+        return config.PPNSweep
+    }
+    return []uint{config.PPN}
+}
+
+// Bindings to run the benchmarks with, empty is the launcher default.
+func (config *AppConfig) GetBindToList() []string {
+    if len(config.BindToSweep) != 0 {
+        return config.BindToSweep
+    }
+    return []string{config.BindTo}
+}
+
+// Mappings to run the benchmarks with, empty is the launcher default.
+func (config *AppConfig) GetMapByList() []string {
+    if len(config.MapBySweep) != 0 {
+        return config.MapBySweep
+    }
+    return []string{config.MapBy}
+}
+
+// Check if the benchmarks are run with more than one mapping/binding
+// policy.
+func (config *AppConfig) IsPolicySweep() bool {
+    return len(config.MapBySweep) != 0 || len(config.BindToSweep) != 0
+}
+
+// Check if the mapping is one of MapTypes or a launcher specific policy,
+// ex: ppr:1:socket, ppr:2:node:pe=2
+func IsValidMapBy(mapping string) bool {
+    if IsListMember(MapTypes, mapping) {
+        return true
+    }
+    return strings.Contains(mapping, ":") && !strings.ContainsAny(mapping,
+                                                                  " /\t")
+}
+
+// Check if the benchmarks are run with more than one process
+// count/processes per node configuration.
+func (config *AppConfig) IsSweep() bool {
+    return len(config.NPSweep) != 0 || len(config.PPNSweep) != 0
+}
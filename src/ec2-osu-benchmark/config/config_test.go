@@ -0,0 +1,280 @@
+package config
+
+import (
+    "encoding/json"
+    "flag"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+    "ec2-osu-benchmark/errors"
+)
+
+func TestMain(m *testing.M) {
+    // Keep the tests off the instance metadata service.
+    GetPublicHostName = func() (string, error) {
+        return "localhost", errors.OP_SUCCESS
+    }
+    os.Exit(m.Run())
+}
+
+// Run InitConfig with the commandline arguments, on a fresh flag set.
+func initConfig(t *testing.T, args ...string) (*AppConfig, error) {
+    t.Helper()
+    savedArgs, savedFlags := os.Args, flag.CommandLine
+    defer func() {
+        os.Args, flag.CommandLine = savedArgs, savedFlags
+    }()
+    os.Args = append([]string{"ec2-osu-benchmark"}, args...)
+    flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+    flag.CommandLine.SetOutput(ioutil.Discard)
+    config := new(AppConfig)
+    return config, config.InitConfig()
+}
+
+func writeHostfile(t *testing.T) string {
+    t.Helper()
+    hostFile := filepath.Join(t.TempDir(), "hostfile")
+    err := ioutil.WriteFile(hostFile, []byte("host1 slots=4\nhost2 slots=4\n"),
+                            0644)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return hostFile
+}
+
+func TestParseSweep(t *testing.T) {
+    tests := []struct {
+        sweep string
+        want []uint
+    }{
+        {"", []uint{}},
+        {"2,4,8", []uint{2, 4, 8}},
+        {"2-32", []uint{2, 4, 8, 16, 32}},
+        {"2-10:4", []uint{2, 6, 10}},
+        {"4,2-8", []uint{4, 2, 8}},
+    }
+    for _, test := range tests {
+        got, err := ParseSweep(test.sweep)
+        if err != errors.OP_SUCCESS {
+            t.Errorf("ParseSweep(%q) failed, err : %s", test.sweep, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, test.want) {
+            t.Errorf("ParseSweep(%q) = %v, want %v", test.sweep, got,
+                     test.want)
+        }
+    }
+    for _, sweep := range []string{"0", "8-2", "x", "4:2", "2-8:0"} {
+        if _, err := ParseSweep(sweep); err == errors.OP_SUCCESS {
+            t.Errorf("ParseSweep(%q) is expected to fail", sweep)
+        }
+    }
+}
+
+func TestGetEnvOverridesByKey(t *testing.T) {
+    config := new(AppConfig)
+    config.Env = []string{"A=1", "B=2"}
+    config.BenchEnv = map[string][]string{"osu_bw": {"B=3", "C=4"}}
+    want := []string{"A=1", "B=3", "C=4"}
+    if got := config.GetEnv("osu_bw"); !reflect.DeepEqual(got, want) {
+        t.Errorf("GetEnv(osu_bw) = %v, want %v", got, want)
+    }
+    want = []string{"A=1", "B=2"}
+    if got := config.GetEnv("osu_latency"); !reflect.DeepEqual(got, want) {
+        t.Errorf("GetEnv(osu_latency) = %v, want %v", got, want)
+    }
+}
+
+func TestInitConfig(t *testing.T) {
+    hostFile := writeHostfile(t)
+    config, err := initConfig(t, "-f", hostFile, "-c", "8",
+                              "-np-sweep", "2-8", "-repeat", "3",
+                              "-bench-opts", "osu_bw:-m 1:1024 -i 10",
+                              "-metric-dir", "/tmp/metrics",
+                              "-telemetry", "500ms",
+                              "-results-root", "/tmp/results",
+                              "-result-layout", "{host}/{runid}")
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("InitConfig failed, err : %s", err)
+    }
+    if !reflect.DeepEqual(config.GetNPList(), []uint{2, 4, 8}) {
+        t.Errorf("np list = %v", config.GetNPList())
+    }
+    if config.Repeat != 3 || config.MetricDir != "/tmp/metrics/" {
+        t.Errorf("repeat = %d, metric dir = %s", config.Repeat,
+                 config.MetricDir)
+    }
+    if config.Telemetry != 500 * time.Millisecond {
+        t.Errorf("telemetry interval = %s", config.Telemetry)
+    }
+    if config.ResultsRoot != "/tmp/results" ||
+       config.ResultLayout != "{host}/{runid}" {
+        t.Errorf("results root = %s, layout = %s", config.ResultsRoot,
+                 config.ResultLayout)
+    }
+    opts := config.GetOSUOptions("osu_bw")
+    if opts.MessageSize != "1:1024" || opts.Iterations != 10 {
+        t.Errorf("osu_bw options = %+v", opts)
+    }
+}
+
+func TestInitConfigValidation(t *testing.T) {
+    hostFile := writeHostfile(t)
+    invalid := [][]string{
+        {"-launcher", "lam"},
+        {"-bind-to", "board"},
+        {"-repeat", "0"},
+        {"-c", "2", "-ppn", "4"},
+        {"-c", "4", "-pairs", "3"},
+        {"-np-sweep", "2,4", "-pair-matrix"},
+        {"-dry-run-format", "yaml"},
+        {"-env", "NOVALUE"},
+        {"-preflight", "-skip-preflight"},
+        {"-map-by", "board"},
+        {"-bind-to-sweep", "core,board"},
+        {"-launcher", "openmpi", "-c", "4", "-ppn", "2", "-map-by", "socket"},
+        {"-map-by-sweep", "core,node", "-pair-matrix"},
+        {"-telemetry", "-1s"},
+        {"-timeout", "-30m"},
+        {"-l", "0"},
+        {"-result-layout", "{date}"},
+        {"-result-layout", "/results/{runid}"},
+        {"-result-layout", "../{runid}"},
+        {"-result-layout", "{date}/{user}/{runid}"},
+    }
+    for _, args := range invalid {
+        args = append([]string{"-f", hostFile}, args...)
+        if _, err := initConfig(t, args...); err == errors.OP_SUCCESS {
+            t.Errorf("InitConfig(%v) is expected to fail", args)
+        }
+    }
+    // Auto detected Open MPI is checked once the launcher is known.
+    auto, err := initConfig(t, "-f", hostFile, "-launcher", "auto",
+                            "-c", "4", "-ppn", "2", "-map-by", "socket")
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("InitConfig failed, err : %s", err)
+    }
+    if auto.ValidateMapBy(LAUNCHER_OPENMPI) == errors.OP_SUCCESS {
+        t.Errorf("-map-by with -ppn is expected to fail for Open MPI")
+    }
+    if auto.ValidateMapBy(LAUNCHER_INTELMPI) != errors.OP_SUCCESS {
+        t.Errorf("-map-by with -ppn is expected to pass for Intel MPI")
+    }
+    _, err = initConfig(t, "-f", filepath.Join(t.TempDir(), "missing"))
+    if err == errors.OP_SUCCESS {
+        t.Errorf("InitConfig with a missing hostfile is expected to fail")
+    }
+}
+
+func TestInitConfigResume(t *testing.T) {
+    resultDir := t.TempDir()
+    args := []string{"-f", writeHostfile(t), "-repeat", "3",
+                     "-benchmarks", "osu_bw"}
+    state := `{"args": ["` + strings.Join(args, `", "`) + `"]}`
+    err := ioutil.WriteFile(filepath.Join(resultDir, CAMPAIGN_STATE_FILE),
+                            []byte(state), 0644)
+    if err != nil {
+        t.Fatal(err)
+    }
+    config, err := initConfig(t, "-resume", resultDir, "-progress")
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("InitConfig failed, err : %s", err)
+    }
+    if config.Repeat != 3 || !config.Progress ||
+       !reflect.DeepEqual(config.Benchmarks, []string{"osu_bw"}) ||
+       !reflect.DeepEqual(config.Args, args) {
+        t.Errorf("unexpected resumed config %+v", config)
+    }
+    if _, err = initConfig(t, "-resume", resultDir, "-c", "4");
+       err == errors.OP_SUCCESS {
+        t.Errorf("-resume with -c is expected to fail")
+    }
+    if _, err = initConfig(t, "-resume", t.TempDir());
+       err == errors.OP_SUCCESS {
+        t.Errorf("-resume without a campaign state is expected to fail")
+    }
+}
+
+func TestInitConfigCampaign(t *testing.T) {
+    campaignFile := filepath.Join(t.TempDir(), "campaign.json")
+    campaign := `{
+        "hostfile": "` + writeHostfile(t) + `",
+        "benchmarks": ["osu_latency", "osu_bw"],
+        "benchoptions": {"osu_bw": {"messagesize": "1:4096", "iterations": 10}},
+        "envvariants": [{"name": "efa", "env": ["FI_PROVIDER=efa"]},
+                        {"name": "tcp", "env": ["FI_PROVIDER=tcp"]}],
+        "np": [2, 4],
+        "ppn": [1],
+        "repeat": 2,
+        "exclude": [{"benchmark": "osu_bw", "np": 4}]
+    }`
+    if err := ioutil.WriteFile(campaignFile, []byte(campaign), 0644);
+       err != nil {
+        t.Fatal(err)
+    }
+    config, err := initConfig(t, "-campaign", campaignFile)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("InitConfig failed, err : %s", err)
+    }
+    if !reflect.DeepEqual(config.NPSweep, []uint{2, 4}) || config.PPN != 1 ||
+       config.Repeat != 2 || config.BenchOSUOpts["osu_bw"].Iterations != 10 {
+        t.Errorf("unexpected campaign config %+v", config)
+    }
+    want := []string{"FI_PROVIDER=tcp"}
+    if got := config.GetVariantEnv("osu_bw", "tcp"); !reflect.DeepEqual(got,
+                                                                        want) {
+        t.Errorf("GetVariantEnv(osu_bw, tcp) = %v, want %v", got, want)
+    }
+    if len(config.ExcludeJobs) != 1 ||
+       !config.IsJobExcluded("osu_bw", 4, 1, "", "", "efa") ||
+       config.IsJobExcluded("osu_bw", 2, 1, "", "", "efa") {
+        t.Errorf("unexpected job exclusions %+v", config.ExcludeJobs)
+    }
+    // Explicit zero counts are passed, not taken for the defaults
+    var explicit Campaign
+    err = json.Unmarshal([]byte(`{"options": {"iterations": 0},
+                                  "parallel": 1, "loglevel": 0}`), &explicit)
+    if err != nil {
+        t.Fatal(err)
+    }
+    args, _ := explicit.Args()
+    want = []string{"-iterations", "0", "-parallel", "1", "-l", "0"}
+    if !reflect.DeepEqual(args, want) {
+        t.Errorf("campaign args = %v, want %v", args, want)
+    }
+    for _, zero := range []string{`"repeat": 0`, `"loglevel": 0`} {
+        ioutil.WriteFile(campaignFile, []byte(`{"hostfile": "` +
+                         writeHostfile(t) + `", ` + zero + `}`), 0644)
+        if _, err = initConfig(t, "-campaign", campaignFile);
+           err == errors.OP_SUCCESS {
+            t.Errorf("campaign with %s is expected to fail", zero)
+        }
+    }
+    // Unknown fields are typos
+    ioutil.WriteFile(campaignFile, []byte(`{"benchmark": ["osu_bw"]}`), 0644)
+    if _, err = initConfig(t, "-campaign", campaignFile);
+       err == errors.OP_SUCCESS {
+        t.Errorf("campaign with an unknown field is expected to fail")
+    }
+}
+
+func TestParseJobFilter(t *testing.T) {
+    filter, err := ParseJobFilter("benchmark=osu_*bw,np=4,variant=tcp")
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("ParseJobFilter failed, err : %s", err)
+    }
+    if filter.String() != "benchmark=osu_*bw,np=4,variant=tcp" ||
+       !filter.Match("osu_bibw", 4, 0, "", "", "tcp") ||
+       filter.Match("osu_bibw", 4, 0, "", "", "efa") {
+        t.Errorf("unexpected filter %+v", filter)
+    }
+    for _, invalid := range []string{"", "np=x", "hosts=2", "np", "np="} {
+        if _, err := ParseJobFilter(invalid); err == errors.OP_SUCCESS {
+            t.Errorf("ParseJobFilter(%q) is expected to fail", invalid)
+        }
+    }
+}
@@ -11,4 +11,5 @@ var (
     DATA_NOT_UNIQUE_ERROR = fmt.Errorf("The entry is not unique in the App")
     DATA_PRESENT_IN_SYSTEM = fmt.Errorf(`The entry already present in App`)
     DATA_NOT_FOUND = fmt.Errorf("The entry not found in the Application")
+    PREFLIGHT_FAILED = fmt.Errorf("Preflight checks of the hosts failed")
 )
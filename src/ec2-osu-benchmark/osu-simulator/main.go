@@ -0,0 +1,122 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "sort"
+    "syscall"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/simulator"
+)
+
+// Simulator of the MPI launchers and OSU benchmarks as a command, works
+// like busybox. Installed as mpirun/mpiexec/srun it simulates the launcher
+// with the OSU binary of its commandline, installed as osu_* it simulates
+// the benchmark. The OSU_SIM_* environment configures the simulation.
+//
+//   osu-simulator install <dir>
+//       <dir>/bin/{mpirun,mpiexec,srun} and <dir>/mpi/<category>/osu_*,
+//       the layout of a OSU installation, with <dir>/benchmarks.txt
+//   PATH=<dir>/bin:$PATH ec2-osu-benchmark -osu-prefix <dir> ...
+//       or -benchmark-file <dir>/benchmarks.txt
+
+// Categories of the simulated benchmarks in the installation.
+var categories = map[string]string{
+    "osu_latency": "pt2pt", "osu_bw": "pt2pt", "osu_bibw": "pt2pt",
+    "osu_mbw_mr": "pt2pt", "osu_multi_lat": "pt2pt",
+    "osu_put_latency": "one-sided", "osu_get_latency": "one-sided",
+    "osu_put_bw": "one-sided", "osu_get_bw": "one-sided",
+    "osu_put_bibw": "one-sided", "osu_acc_latency": "one-sided",
+    "osu_fop_latency": "one-sided", "osu_cas_latency": "one-sided"}
+
+func usage() {
+    fmt.Fprintf(os.Stderr, "USAGE: osu-simulator install <dir>\n" +
+                "       mpirun/mpiexec/srun/osu_* linked to osu-simulator\n")
+    os.Exit(2)
+}
+
+// Link the launchers and the benchmarks to the simulator binary.
+func install(dir string) error {
+    self, err := os.Executable()
+    if err != nil {
+        return err
+    }
+    links := make(map[string]string)
+    for _, launcher := range simulator.LAUNCHER_COMMANDS {
+        links[launcher] = filepath.Join(dir, "bin", launcher)
+    }
+    for _, bench := range simulator.Benchmark_names() {
+        category, ok := categories[bench]
+        if !ok {
+            category = "collective"
+        }
+        links[bench] = filepath.Join(dir, "mpi", category, bench)
+    }
+    names := make([]string, 0, len(links))
+    for name := range links {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    // Benchmark file of the application with the simulated benchmarks.
+    benchmarkFile := ""
+    for _, bench := range simulator.Benchmark_names() {
+        benchmarkFile += fmt.Sprintf("%s %s %s\n", bench, links[bench],
+                                     filepath.Base(filepath.Dir(links[bench])))
+    }
+    for _, name := range names {
+        link := links[name]
+        if err = os.MkdirAll(filepath.Dir(link), os.ModePerm); err != nil {
+            return err
+        }
+        os.Remove(link)
+        if err = os.Symlink(self, link); err != nil {
+            return err
+        }
+        fmt.Println(link)
+    }
+    err = ioutil.WriteFile(filepath.Join(dir, "benchmarks.txt"),
+                           []byte(benchmarkFile), 0644)
+    if err != nil {
+        return err
+    }
+    return errors.OP_SUCCESS
+}
+
+func main() {
+    name := filepath.Base(os.Args[0])
+    if !simulator.Is_simulated(name) {
+        if len(os.Args) != 3 || os.Args[1] != "install" {
+            usage()
+        }
+        if err := install(os.Args[2]); err != errors.OP_SUCCESS {
+            fmt.Fprintf(os.Stderr, "Failed to install, err : %s\n", err)
+            os.Exit(1)
+        }
+        return
+    }
+    simConfig, err := simulator.Config_from_env()
+    if err != errors.OP_SUCCESS {
+        fmt.Fprintf(os.Stderr, "Invalid %s environment\n", name)
+        os.Exit(2)
+    }
+    argv := append([]string{name}, os.Args[1:]...)
+    if len(argv) == 2 && argv[1] == "--version" {
+        res, err := simConfig.Output(argv)
+        if err == errors.OP_SUCCESS {
+            os.Stdout.Write(res)
+            return
+        }
+    }
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt,
+                                      syscall.SIGTERM)
+    defer stop()
+    exitCode, _ := simConfig.Run(ctx, argv, os.Stdout, os.Stderr)
+    if exitCode < 0 {
+        exitCode = 1
+    }
+    os.Exit(exitCode)
+}
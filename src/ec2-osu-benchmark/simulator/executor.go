@@ -0,0 +1,50 @@
+package simulator
+
+import (
+    "context"
+    "io"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/testRunner"
+)
+
+// testRunner.CommandExecutor that runs the launchers and the benchmarks
+// in the simulator, in process. Commands the simulator doesn't know are
+// not present.
+type Executor struct {
+    Config *Config
+}
+
+func New_executor(simConfig *Config) *Executor {
+    executor := new(Executor)
+    executor.Config = simConfig
+    return executor
+}
+
+func (executor *Executor)LookPath(cmd string) bool {
+    return Is_simulated(cmd)
+}
+
+func (executor *Executor)Output(argv []string) ([]byte, error) {
+    return executor.Config.Output(argv)
+}
+
+func (executor *Executor)Run(ctx context.Context, argv []string,
+                             stdout io.Writer,
+                             stderr io.Writer) (*testRunner.OSU_exec_status,
+                                                error) {
+    status := new(testRunner.OSU_exec_status)
+    exitCode, err := executor.Config.Run(ctx, argv, stdout, stderr)
+    status.ExitCode = exitCode
+    switch ctx.Err() {
+    case context.DeadlineExceeded:
+        status.TimedOut = true
+        status.Signal = "killed"
+    case context.Canceled:
+        status.Interrupted = true
+        status.Signal = "killed"
+    }
+    if err != errors.OP_SUCCESS {
+        return status, err
+    }
+    return status, errors.OP_SUCCESS
+}
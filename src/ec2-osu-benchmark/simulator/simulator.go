@@ -0,0 +1,646 @@
+package simulator
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "math"
+    "math/rand"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+    "ec2-osu-benchmark/errors"
+)
+
+// Simulator of the MPI launchers and OSU benchmark binaries. It prints the
+// output a real OSU run prints, with latencies/bandwidths from a simple
+// network model and some noise, so the whole application can be run and
+// tested without a MPI installation.
+
+// Launchers the simulator can stand in for.
+var LAUNCHER_COMMANDS = []string{"mpirun", "mpiexec", "srun"}
+
+// Version string of the simulated MPI implementations, the same as the
+// real launchers print for --version.
+var LAUNCHER_VERSIONS = map[string]string{
+    "openmpi": "mpirun (Open MPI) 4.1.5\n",
+    "mpich": "HYDRA build details:\n    Version: 4.1.2\n",
+    "intel": "Intel(R) MPI Library for Linux* OS, Version 2021.9\n",
+    "slurm": "slurm 23.02.6\n"}
+
+const OSU_VERSION = "v5.6.2"
+
+// Environment of the simulator command, the same knobs as the Config.
+const (
+    ENV_LAUNCHER = "OSU_SIM_LAUNCHER"
+    ENV_MIN_SIZE = "OSU_SIM_MIN_SIZE"
+    ENV_MAX_SIZE = "OSU_SIM_MAX_SIZE"
+    ENV_NOISE = "OSU_SIM_NOISE"
+    ENV_SEED = "OSU_SIM_SEED"
+    ENV_FAIL = "OSU_SIM_FAIL"
+    ENV_HANG = "OSU_SIM_HANG"
+    ENV_ROW_DELAY = "OSU_SIM_ROW_DELAY"
+    // Hosts without the OSU binaries, ex: h3,h4
+    ENV_MISSING_ON = "OSU_SIM_MISSING_ON"
+    // Clock offset of hosts, ex: h2=5s,h3=-2s
+    ENV_CLOCK_SKEW = "OSU_SIM_CLOCK_SKEW"
+)
+
+// Number of CPUs of every simulated host.
+const SIMULATED_CPUS = 64
+
+type Config struct {
+    // MPI implementation behind the launchers, key of LAUNCHER_VERSIONS
+    Launcher string
+    // Message size range, 0 for the OSU defaults of the benchmark.
+    // -m of the benchmark arguments overrides it.
+    MinSize uint64
+    MaxSize uint64
+    // Relative standard deviation of the values, ex: 0.05
+    Noise float64
+    Seed int64
+    // Benchmarks that fail with an error/hang till they are killed
+    Fail []string
+    Hang []string
+    // Time taken by every result row
+    RowDelay time.Duration
+    // Hosts of the preflight probe without the OSU binaries, with their
+    // clock off and with a different MPI version string
+    MissingOn []string
+    ClockSkew map[string]time.Duration
+    MPIVersionOn map[string]string
+}
+
+// Default configuration, Open MPI without noise.
+func Default_config() *Config {
+    simConfig := new(Config)
+    simConfig.Launcher = "openmpi"
+    simConfig.Seed = 1
+    simConfig.ClockSkew = make(map[string]time.Duration)
+    simConfig.MPIVersionOn = make(map[string]string)
+    return simConfig
+}
+
+// Configuration from the OSU_SIM_* environment, unset variables keep the
+// defaults.
+func Config_from_env() (*Config, error) {
+    var err error
+    simConfig := Default_config()
+    if value := os.Getenv(ENV_LAUNCHER); len(value) != 0 {
+        if _, ok := LAUNCHER_VERSIONS[value]; !ok {
+            return nil, errors.INVALID_INPUT
+        }
+        simConfig.Launcher = value
+    }
+    if value := os.Getenv(ENV_MIN_SIZE); len(value) != 0 {
+        if simConfig.MinSize, err = strconv.ParseUint(value, 10, 64); err != nil {
+            return nil, errors.INVALID_INPUT
+        }
+    }
+    if value := os.Getenv(ENV_MAX_SIZE); len(value) != 0 {
+        if simConfig.MaxSize, err = strconv.ParseUint(value, 10, 64); err != nil {
+            return nil, errors.INVALID_INPUT
+        }
+    }
+    if value := os.Getenv(ENV_NOISE); len(value) != 0 {
+        if simConfig.Noise, err = strconv.ParseFloat(value, 64); err != nil {
+            return nil, errors.INVALID_INPUT
+        }
+    }
+    if value := os.Getenv(ENV_SEED); len(value) != 0 {
+        if simConfig.Seed, err = strconv.ParseInt(value, 10, 64); err != nil {
+            return nil, errors.INVALID_INPUT
+        }
+    }
+    if value := os.Getenv(ENV_ROW_DELAY); len(value) != 0 {
+        if simConfig.RowDelay, err = time.ParseDuration(value); err != nil {
+            return nil, errors.INVALID_INPUT
+        }
+    }
+    simConfig.Fail = split_names(os.Getenv(ENV_FAIL))
+    simConfig.Hang = split_names(os.Getenv(ENV_HANG))
+    simConfig.MissingOn = split_names(os.Getenv(ENV_MISSING_ON))
+    for _, entry := range split_names(os.Getenv(ENV_CLOCK_SKEW)) {
+        hostSkew := strings.SplitN(entry, "=", 2)
+        if len(hostSkew) != 2 {
+            return nil, errors.INVALID_INPUT
+        }
+        skew, err := time.ParseDuration(hostSkew[1])
+        if err != nil {
+            return nil, errors.INVALID_INPUT
+        }
+        simConfig.ClockSkew[hostSkew[0]] = skew
+    }
+    return simConfig, errors.OP_SUCCESS
+}
+
+func split_names(names string) []string {
+    list := make([]string, 0)
+    for _, name := range strings.Split(names, ",") {
+        if name = strings.TrimSpace(name); len(name) != 0 {
+            list = append(list, name)
+        }
+    }
+    return list
+}
+
+func is_member(list []string, name string) bool {
+    for _, entry := range list {
+        if entry == name {
+            return true
+        }
+    }
+    return false
+}
+
+// Check if the command is a launcher or an OSU benchmark the simulator
+// knows.
+func Is_simulated(cmd string) bool {
+    name := filepath.Base(cmd)
+    if is_member(LAUNCHER_COMMANDS, name) {
+        return true
+    }
+    _, ok := osu_benchmarks[name]
+    return ok
+}
+
+// Names of the simulated OSU benchmarks, sorted.
+func Benchmark_names() []string {
+    names := make([]string, 0, len(osu_benchmarks))
+    for name := range osu_benchmarks {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Kind of the output of a benchmark.
+const (
+    output_latency = iota
+    output_bw
+    output_multi_bw
+    output_collective
+    output_barrier
+    output_rma_latency
+    output_rma_bw
+    output_rma_atomic
+)
+
+type osu_benchmark struct {
+    title string
+    output int
+    min_size uint64
+    max_size uint64
+    // Base latency in us and peak bandwidth in MB/s of the model
+    latency float64
+    bandwidth float64
+}
+
+var osu_benchmarks = map[string]osu_benchmark{
+    "osu_latency": {"Latency Test", output_latency, 0, 4194304, 2.1, 12000},
+    "osu_bw": {"Bandwidth Test", output_bw, 1, 4194304, 2.1, 12000},
+    "osu_bibw": {"Bi-Directional Bandwidth Test", output_bw, 1, 4194304,
+                 2.1, 23000},
+    "osu_mbw_mr": {"Multiple Bandwidth / Message Rate Test", output_multi_bw,
+                   1, 4194304, 2.1, 12000},
+    "osu_multi_lat": {"Multi Latency Test", output_latency, 0, 4194304,
+                      2.3, 12000},
+    "osu_allgather": {"Allgather Latency Test", output_collective, 1, 1048576,
+                      4.0, 6000},
+    "osu_allgatherv": {"Allgatherv Latency Test", output_collective, 1,
+                       1048576, 4.2, 6000},
+    "osu_allreduce": {"Allreduce Latency Test", output_collective, 4, 1048576,
+                      5.1, 5000},
+    "osu_alltoall": {"All-to-All Personalized Exchange Latency Test",
+                     output_collective, 1, 1048576, 6.3, 4000},
+    "osu_alltoallv": {"All-to-Allv Personalized Exchange Latency Test",
+                      output_collective, 1, 1048576, 6.5, 4000},
+    "osu_barrier": {"Barrier Latency Test", output_barrier, 0, 0, 3.2, 0},
+    "osu_bcast": {"Broadcast Latency Test", output_collective, 1, 1048576,
+                  3.0, 8000},
+    "osu_gather": {"Gather Latency Test", output_collective, 1, 1048576,
+                   3.1, 7000},
+    "osu_gatherv": {"Gatherv Latency Test", output_collective, 1, 1048576,
+                    3.3, 7000},
+    "osu_reduce": {"Reduce Latency Test", output_collective, 4, 1048576,
+                   3.9, 6000},
+    "osu_reduce_scatter": {"Reduce_scatter Latency Test", output_collective,
+                           4, 1048576, 5.0, 5000},
+    "osu_scatter": {"Scatter Latency Test", output_collective, 1, 1048576,
+                    3.0, 7000},
+    "osu_scatterv": {"Scatterv Latency Test", output_collective, 1, 1048576,
+                     3.2, 7000},
+    "osu_put_latency": {"_Put Latency Test", output_rma_latency, 1, 4194304,
+                        2.5, 11000},
+    "osu_get_latency": {"_Get Latency Test", output_rma_latency, 1, 4194304,
+                        2.8, 11000},
+    "osu_put_bw": {"_Put Bandwidth Test", output_rma_bw, 1, 4194304,
+                   2.5, 11000},
+    "osu_get_bw": {"_Get Bandwidth Test", output_rma_bw, 1, 4194304,
+                   2.8, 11000},
+    "osu_put_bibw": {"_Put Bi-directional Bandwidth Test", output_rma_bw, 1,
+                     4194304, 2.5, 21000},
+    "osu_acc_latency": {"_Accumulate Latency Test", output_rma_latency, 1,
+                        4194304, 3.0, 9000},
+    "osu_fop_latency": {"_Fetch_and_op latency Test", output_rma_atomic, 8, 8,
+                        3.1, 0},
+    "osu_cas_latency": {"_Compare_and_swap latency Test", output_rma_atomic,
+                        8, 8, 3.2, 0}}
+
+// Window creation/synchronization names of the one-sided -w/-s options.
+var rma_windows = map[string]string{"create": "MPI_Win_create",
+    "allocate": "MPI_Win_allocate", "dynamic": "MPI_Win_create_dynamic"}
+var rma_syncs = map[string]string{"lock": "MPI_Win_lock/unlock",
+    "flush": "MPI_Win_flush", "flush_local": "MPI_Win_flush_local",
+    "lock_all": "MPI_Win_lock_all/unlock_all", "pscw": "Post/Start/Complete/Wait",
+    "fence": "MPI_Win_fence"}
+
+// Run of a benchmark, parsed from the launcher/benchmark commandline.
+type run struct {
+    bench string
+    np uint64
+    min_size uint64
+    max_size uint64
+    iterations uint64
+    full_stats bool
+    pairs uint64
+    window_size uint64
+    window string
+    sync string
+}
+
+// Parse the commandline of a launcher or a benchmark. The benchmark is the
+// first argument that is an OSU binary, the launcher arguments before it
+// give the number of processes.
+func parse_run(argv []string) (*run, error) {
+    simRun := new(run)
+    simRun.np = 2
+    benchIdx := -1
+    for idx, arg := range argv {
+        if _, ok := osu_benchmarks[filepath.Base(arg)]; ok {
+            benchIdx = idx
+            break
+        }
+    }
+    if benchIdx < 0 {
+        return nil, errors.CMD_NOT_FOUND
+    }
+    simRun.bench = filepath.Base(argv[benchIdx])
+    for idx := 0; idx + 1 < benchIdx; idx++ {
+        switch argv[idx] {
+        case "--np", "-np", "-n":
+            simRun.np, _ = strconv.ParseUint(argv[idx + 1], 10, 64)
+        }
+    }
+    args := argv[benchIdx + 1:]
+    value := func(idx int) string {
+        if idx + 1 < len(args) {
+            return args[idx + 1]
+        }
+        return ""
+    }
+    for idx := 0; idx < len(args); idx++ {
+        var err error
+        switch args[idx] {
+        case "-m":
+            sizes := strings.SplitN(value(idx), ":", 2)
+            if len(sizes) == 1 {
+                simRun.max_size, err = strconv.ParseUint(sizes[0], 10, 64)
+            } else {
+                simRun.min_size, err = strconv.ParseUint(sizes[0], 10, 64)
+                if err == nil {
+                    simRun.max_size, err = strconv.ParseUint(sizes[1], 10, 64)
+                }
+            }
+            idx++
+        case "-i":
+            simRun.iterations, err = strconv.ParseUint(value(idx), 10, 64)
+            idx++
+        case "-x", "-M":
+            idx++
+        case "-f":
+            simRun.full_stats = true
+        case "-p":
+            simRun.pairs, err = strconv.ParseUint(value(idx), 10, 64)
+            idx++
+        case "-W":
+            simRun.window_size, err = strconv.ParseUint(value(idx), 10, 64)
+            idx++
+        case "-w":
+            simRun.window = value(idx)
+            idx++
+        case "-s":
+            simRun.sync = value(idx)
+            idx++
+        }
+        if err != nil {
+            return nil, errors.INVALID_INPUT
+        }
+    }
+    return simRun, errors.OP_SUCCESS
+}
+
+// Message sizes of the run, powers of two in the range.
+func (simConfig *Config)sizes(bench osu_benchmark, simRun *run) []uint64 {
+    minSize, maxSize := bench.min_size, bench.max_size
+    if simConfig.MinSize != 0 {
+        minSize = simConfig.MinSize
+    }
+    if simConfig.MaxSize != 0 {
+        maxSize = simConfig.MaxSize
+    }
+    if simRun.max_size != 0 {
+        minSize, maxSize = simRun.min_size, simRun.max_size
+    }
+    if bench.output == output_rma_atomic {
+        return []uint64{8}
+    }
+    sizes := make([]uint64, 0)
+    if minSize == 0 {
+        sizes = append(sizes, 0)
+        minSize = 1
+    }
+    for size := uint64(1); size <= maxSize; size *= 2 {
+        if size >= minSize {
+            sizes = append(sizes, size)
+        }
+    }
+    return sizes
+}
+
+// Latency(us) and bandwidth(MB/s) of the model, latency is the base
+// latency plus the transfer time and the bandwidth saturates towards the
+// peak with the message size.
+func model_latency(bench osu_benchmark, size uint64, np uint64) float64 {
+    latency := bench.latency + float64(size) / bench.bandwidth
+    if bench.output == output_collective || bench.output == output_barrier {
+        latency *= math.Max(1, math.Log2(float64(np)))
+    }
+    return latency
+}
+
+func model_bandwidth(bench osu_benchmark, size uint64) float64 {
+    return bench.bandwidth * float64(size) / (float64(size) +
+                                              bench.latency * bench.bandwidth)
+}
+
+// Run the simulated launcher/benchmark commandline, the OSU output goes to
+// stdout. Failing benchmarks print an error to stderr, hanging benchmarks
+// block till the context is done.
+func (simConfig *Config)Run(ctx context.Context, argv []string,
+                            stdout io.Writer, stderr io.Writer) (int, error) {
+    if probeIdx := find_probe(argv); probeIdx >= 0 {
+        return simConfig.run_probe(argv, probeIdx, stdout)
+    }
+    simRun, err := parse_run(argv)
+    if err != errors.OP_SUCCESS {
+        fmt.Fprintf(stderr, "%s: no OSU benchmark in the command line\n",
+                    filepath.Base(argv[0]))
+        return 1, err
+    }
+    if is_member(simConfig.Fail, simRun.bench) {
+        fmt.Fprintf(stderr, "%s: simulated failure of rank 1\n", simRun.bench)
+        return 1, fmt.Errorf("simulated failure of %s", simRun.bench)
+    }
+    bench := osu_benchmarks[simRun.bench]
+    random := rand.New(rand.NewSource(simConfig.Seed))
+    noise := func(value float64) float64 {
+        return value * math.Max(0.01, 1 + simConfig.Noise * random.NormFloat64())
+    }
+    row := func(format string, args ...interface{}) error {
+        if simConfig.RowDelay > 0 {
+            select {
+            case <-ctx.Done():
+                return ctx.Err()
+            case <-time.After(simConfig.RowDelay):
+            }
+        }
+        fmt.Fprintf(stdout, format, args...)
+        return nil
+    }
+    simConfig.report_bindings(argv, simRun.np, stdout, stderr)
+    title := bench.title
+    if strings.HasPrefix(title, "_") {
+        title = "MPI" + title
+    }
+    fmt.Fprintf(stdout, "# OSU MPI %s %s\n", title, OSU_VERSION)
+    if is_member(simConfig.Hang, simRun.bench) {
+        <-ctx.Done()
+        return -1, ctx.Err()
+    }
+    iterations := simRun.iterations
+    if iterations == 0 {
+        iterations = 1000
+    }
+    switch bench.output {
+    case output_latency:
+        fmt.Fprintf(stdout, "# Size          Latency (us)\n")
+    case output_bw:
+        fmt.Fprintf(stdout, "# Size      Bandwidth (MB/s)\n")
+    case output_multi_bw:
+        pairs, windowSize := simRun.pairs, simRun.window_size
+        if pairs == 0 {
+            pairs = simRun.np / 2
+        }
+        if windowSize == 0 {
+            windowSize = 64
+        }
+        fmt.Fprintf(stdout, "# [ pairs: %d ] [ window size: %d ]\n", pairs,
+                    windowSize)
+        fmt.Fprintf(stdout, "# Size                  MB/s        Messages/s\n")
+    case output_collective:
+        fmt.Fprintf(stdout, "\n")
+        if simRun.full_stats {
+            fmt.Fprintf(stdout, "# Size       Avg Latency(us)   Min Latency(us)" +
+                        "   Max Latency(us)  Iterations\n")
+        } else {
+            fmt.Fprintf(stdout, "# Size       Avg Latency(us)\n")
+        }
+    case output_barrier:
+        fmt.Fprintf(stdout, "\n")
+        if simRun.full_stats {
+            fmt.Fprintf(stdout, "# Avg Latency(us)   Min Latency(us)" +
+                        "   Max Latency(us)  Iterations\n")
+        } else {
+            fmt.Fprintf(stdout, "# Avg Latency(us)\n")
+        }
+    case output_rma_latency, output_rma_bw, output_rma_atomic:
+        window, sync := rma_windows["allocate"], rma_syncs["flush"]
+        if name, ok := rma_windows[simRun.window]; ok {
+            window = name
+        }
+        if name, ok := rma_syncs[simRun.sync]; ok {
+            sync = name
+        }
+        fmt.Fprintf(stdout, "# Window creation: %s\n", window)
+        fmt.Fprintf(stdout, "# Synchronization: %s\n", sync)
+        if bench.output == output_rma_bw {
+            fmt.Fprintf(stdout, "# Size      Bandwidth (MB/s)\n")
+        } else {
+            fmt.Fprintf(stdout, "# Size          Latency (us)\n")
+        }
+    }
+    if bench.output == output_barrier {
+        latency := noise(model_latency(bench, 0, simRun.np))
+        if simRun.full_stats {
+            err = row("%18.2f%18.2f%18.2f%12d\n", latency, latency * 0.9,
+                      latency * 1.1, iterations)
+        } else {
+            err = row("%18.2f\n", latency)
+        }
+        if err != nil {
+            return -1, err
+        }
+        return 0, errors.OP_SUCCESS
+    }
+    for _, size := range simConfig.sizes(bench, simRun) {
+        latency := noise(model_latency(bench, size, simRun.np))
+        bandwidth := noise(model_bandwidth(bench, size))
+        switch bench.output {
+        case output_latency, output_rma_latency, output_rma_atomic:
+            err = row("%-10d%18.2f\n", size, latency)
+        case output_bw, output_rma_bw:
+            err = row("%-10d%18.2f\n", size, bandwidth)
+        case output_multi_bw:
+            err = row("%-10d%18.2f%18.2f\n", size, bandwidth,
+                      bandwidth * 1e6 / float64(size))
+        case output_collective:
+            if simRun.full_stats {
+                err = row("%-10d%18.2f%18.2f%18.2f%12d\n", size, latency,
+                          latency * 0.9, latency * 1.1, iterations)
+            } else {
+                err = row("%-10d%18.2f\n", size, latency)
+            }
+        }
+        if err != nil {
+            return -1, err
+        }
+    }
+    return 0, errors.OP_SUCCESS
+}
+
+// Value of an option in the launcher arguments, empty when not given.
+func launcher_option(argv []string, option string) string {
+    for idx, arg := range argv {
+        if arg == option && idx + 1 < len(argv) {
+            return argv[idx + 1]
+        }
+        if strings.HasPrefix(arg, option + "=") {
+            return strings.TrimPrefix(arg, option + "=")
+        }
+    }
+    return ""
+}
+
+// Binding report of the launcher when it is asked for one, every rank is
+// bound to a core of its own.
+func (simConfig *Config)report_bindings(argv []string, np uint64,
+                                        stdout io.Writer, stderr io.Writer) {
+    host := probe_host(argv)
+    for rank := uint64(0); rank < np; rank++ {
+        switch {
+        case is_member(argv, "--report-bindings"):
+            fmt.Fprintf(stderr, "[%s:%d] MCW rank %d bound to socket 0" +
+                        "[core %d[hwt 0]]: [%s]\n", host, 4242 + rank, rank,
+                        rank, strings.Repeat("./", int(rank)) + "B")
+        case launcher_option(argv, "HYDRA_TOPO_DEBUG") == "1":
+            fmt.Fprintf(stdout, "process %d binding: %x\n", rank,
+                        uint64(1) << rank)
+        case launcher_option(argv, "I_MPI_DEBUG") != "":
+            if rank == 0 {
+                fmt.Fprintf(stdout, "[0] MPI startup(): Rank    Pid      " +
+                            "Node name  Pin cpu\n")
+            }
+            fmt.Fprintf(stdout, "[0] MPI startup(): %-7d %-8d %-10s {%d}\n",
+                        rank, 4242 + rank, host, rank)
+        case strings.Contains(launcher_option(argv, "--cpu-bind"), "verbose"):
+            fmt.Fprintf(stderr, "cpu-bind=MASK - %s, task %2d %2d [%d]: " +
+                        "mask 0x%x set\n", host, rank, rank, 4242 + rank,
+                        uint64(1) << rank)
+        default:
+            return
+        }
+    }
+}
+
+// Position of the '/bin/sh -c' of a preflight probe in the commandline,
+// -1 when it is not a probe.
+func find_probe(argv []string) int {
+    for idx := 0; idx + 1 < len(argv); idx++ {
+        if filepath.Base(argv[idx]) == "sh" && argv[idx + 1] == "-c" {
+            return idx
+        }
+    }
+    return -1
+}
+
+// First host of the hostfile/nodelist in the launcher arguments.
+func probe_host(argv []string) string {
+    for idx, arg := range argv {
+        if strings.HasPrefix(arg, "--nodelist=") {
+            return strings.Split(strings.TrimPrefix(arg, "--nodelist="),
+                                 ",")[0]
+        }
+        switch arg {
+        case "--hostfile", "-hostfile", "-f", "-machinefile":
+        default:
+            continue
+        }
+        if idx + 1 >= len(argv) {
+            break
+        }
+        content, err := ioutil.ReadFile(argv[idx + 1])
+        if err != nil {
+            break
+        }
+        for _, line := range strings.Split(string(content), "\n") {
+            if fields := strings.Fields(line); len(fields) != 0 &&
+               !strings.HasPrefix(fields[0], "#") {
+                return strings.SplitN(fields[0], ":", 2)[0]
+            }
+        }
+    }
+    return "localhost"
+}
+
+// Answer a preflight probe('sh -c <probe> <name> <launcher> <binaries>')
+// the way the probe script does on a host.
+func (simConfig *Config)run_probe(argv []string, probeIdx int,
+                                  stdout io.Writer) (int, error) {
+    host := probe_host(argv[:probeIdx])
+    args := argv[probeIdx + 2:]
+    // script and $0
+    if len(args) < 3 {
+        return 1, errors.INVALID_INPUT
+    }
+    now := time.Now().Add(simConfig.ClockSkew[host])
+    mpi, ok := simConfig.MPIVersionOn[host]
+    if !ok {
+        mpi = strings.SplitN(LAUNCHER_VERSIONS[simConfig.Launcher], "\n", 2)[0]
+    }
+    fmt.Fprintf(stdout, "hostname %s\n", host)
+    fmt.Fprintf(stdout, "time %d.%09d\n", now.Unix(), now.Nanosecond())
+    fmt.Fprintf(stdout, "cpus %d\n", SIMULATED_CPUS)
+    fmt.Fprintf(stdout, "mpi %s\n", mpi)
+    for _, binary := range args[3:] {
+        if Is_simulated(binary) && !is_member(simConfig.MissingOn, host) {
+            fmt.Fprintf(stdout, "binary %s ok %s\n", binary, OSU_VERSION)
+        } else {
+            fmt.Fprintf(stdout, "binary %s missing\n", binary)
+        }
+    }
+    return 0, errors.OP_SUCCESS
+}
+
+// Output of a short lived command, the launcher versions.
+func (simConfig *Config)Output(argv []string) ([]byte, error) {
+    if len(argv) == 2 && argv[1] == "--version" &&
+       is_member(LAUNCHER_COMMANDS, filepath.Base(argv[0])) {
+        return []byte(LAUNCHER_VERSIONS[simConfig.Launcher]), errors.OP_SUCCESS
+    }
+    return nil, errors.CMD_NOT_FOUND
+}
@@ -0,0 +1,124 @@
+package simulator
+
+import (
+    "bytes"
+    "context"
+    "strings"
+    "testing"
+    "time"
+    "ec2-osu-benchmark/errors"
+)
+
+func runSimulator(t *testing.T, simConfig *Config,
+                  argv ...string) (string, string, int, error) {
+    t.Helper()
+    var stdout, stderr bytes.Buffer
+    exitCode, err := simConfig.Run(context.Background(), argv, &stdout,
+                                   &stderr)
+    return stdout.String(), stderr.String(), exitCode, err
+}
+
+// Data rows of an OSU output.
+func dataRows(output string) [][]string {
+    rows := make([][]string, 0)
+    for _, line := range strings.Split(output, "\n") {
+        fields := strings.Fields(line)
+        if len(fields) != 0 && !strings.HasPrefix(fields[0], "#") {
+            rows = append(rows, fields)
+        }
+    }
+    return rows
+}
+
+func TestLatencyOutput(t *testing.T) {
+    stdout, _, exitCode, err := runSimulator(t, Default_config(),
+        "mpirun", "--np", "2", "--hostfile", "hosts",
+        "/opt/osu/mpi/pt2pt/osu_latency", "-m", "1:64")
+    if err != errors.OP_SUCCESS || exitCode != 0 {
+        t.Fatalf("run failed, exit code %d, err : %s", exitCode, err)
+    }
+    if !strings.Contains(stdout, "# OSU MPI Latency Test v5.6.2") ||
+       !strings.Contains(stdout, "# Size          Latency (us)") {
+        t.Errorf("unexpected header\n%s", stdout)
+    }
+    rows := dataRows(stdout)
+    if len(rows) != 7 || rows[0][0] != "1" || rows[6][0] != "64" {
+        t.Errorf("unexpected rows %v", rows)
+    }
+}
+
+func TestCollectiveFullStats(t *testing.T) {
+    simConfig := Default_config()
+    simConfig.MaxSize = 16
+    stdout, _, _, _ := runSimulator(t, simConfig, "osu_allreduce", "-f")
+    rows := dataRows(stdout)
+    if len(rows) != 3 || len(rows[0]) != 5 {
+        t.Errorf("unexpected rows %v", rows)
+    }
+    stdout, _, _, _ = runSimulator(t, simConfig, "osu_barrier")
+    if rows = dataRows(stdout); len(rows) != 1 || len(rows[0]) != 1 {
+        t.Errorf("unexpected barrier rows %v", rows)
+    }
+}
+
+func TestOneSidedHeader(t *testing.T) {
+    stdout, _, _, _ := runSimulator(t, Default_config(), "osu_put_bw",
+                                    "-m", "8", "-w", "create", "-s", "lock")
+    if !strings.Contains(stdout, "# Window creation: MPI_Win_create") ||
+       !strings.Contains(stdout, "# Synchronization: MPI_Win_lock/unlock") ||
+       !strings.Contains(stdout, "Bandwidth (MB/s)") {
+        t.Errorf("unexpected header\n%s", stdout)
+    }
+}
+
+func TestNoiseIsSeeded(t *testing.T) {
+    simConfig := Default_config()
+    simConfig.Noise = 0.1
+    first, _, _, _ := runSimulator(t, simConfig, "osu_bw", "-m", "1024")
+    second, _, _, _ := runSimulator(t, simConfig, "osu_bw", "-m", "1024")
+    if first != second {
+        t.Errorf("same seed gives different output")
+    }
+    simConfig.Seed = 2
+    third, _, _, _ := runSimulator(t, simConfig, "osu_bw", "-m", "1024")
+    if first == third {
+        t.Errorf("different seeds give the same output")
+    }
+}
+
+func TestFailure(t *testing.T) {
+    simConfig := Default_config()
+    simConfig.Fail = []string{"osu_bw"}
+    _, stderr, exitCode, err := runSimulator(t, simConfig, "mpirun",
+                                             "osu_bw")
+    if err == errors.OP_SUCCESS || exitCode != 1 || len(stderr) == 0 {
+        t.Errorf("expected failure, exit code %d, stderr %q", exitCode,
+                 stderr)
+    }
+}
+
+func TestHangTillDeadline(t *testing.T) {
+    simConfig := Default_config()
+    simConfig.Hang = []string{"osu_alltoall"}
+    ctx, cancel := context.WithTimeout(context.Background(),
+                                       100 * time.Millisecond)
+    defer cancel()
+    var stdout, stderr bytes.Buffer
+    start := time.Now()
+    _, err := simConfig.Run(ctx, []string{"osu_alltoall"}, &stdout, &stderr)
+    if err != context.DeadlineExceeded {
+        t.Errorf("expected deadline, err : %v", err)
+    }
+    if time.Since(start) > 5 * time.Second {
+        t.Errorf("hang is not stopped by the deadline")
+    }
+}
+
+func TestLauncherVersion(t *testing.T) {
+    simConfig := Default_config()
+    simConfig.Launcher = "mpich"
+    res, err := simConfig.Output([]string{"mpiexec", "--version"})
+    if err != errors.OP_SUCCESS || !strings.Contains(string(res), "HYDRA") {
+        t.Errorf("unexpected version %q, err : %v", res, err)
+    }
+}
@@ -0,0 +1,46 @@
+package sys
+
+import (
+    "fmt"
+    "sync"
+    "ec2-osu-benchmark/errors"
+)
+
+// Collects the errors reported by concurrently running go-routines, safe
+// for use from any number of them.
+type ErrorCollector struct {
+    mutex sync.Mutex
+    errs []error
+}
+
+// Record an error, nil and errors.OP_SUCCESS are ignored.
+func (collector *ErrorCollector)Add(err error) {
+    if err == nil || err == errors.OP_SUCCESS {
+        return
+    }
+    collector.mutex.Lock()
+    defer collector.mutex.Unlock()
+    collector.errs = append(collector.errs, err)
+}
+
+// All the recorded errors.
+func (collector *ErrorCollector)Errors() []error {
+    collector.mutex.Lock()
+    defer collector.mutex.Unlock()
+    errs := make([]error, len(collector.errs))
+    copy(errs, collector.errs)
+    return errs
+}
+
+// errors.OP_SUCCESS when nothing is recorded, otherwise an error with the
+// count and the first recorded error.
+func (collector *ErrorCollector)Err() error {
+    errs := collector.Errors()
+    switch len(errs) {
+    case 0:
+        return errors.OP_SUCCESS
+    case 1:
+        return errs[0]
+    }
+    return fmt.Errorf("%d errors, first : %w", len(errs), errs[0])
+}
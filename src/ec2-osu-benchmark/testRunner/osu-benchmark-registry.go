@@ -0,0 +1,303 @@
+package testRunner
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+//*****************************************************************************
+// ************ NOTE ::: MAKE SURE COMMANDS ARE IN RIGHT PATH *****************
+//*****************************************************************************
+const OSU_INSTALL_DIR = "/usr/local/libexec/osu-micro-benchmarks/mpi/"
+
+// Benchmark categories, same as the sub-directories of an OSU installation.
+const (
+    OSU_CATEGORY_PT2PT = "pt2pt"
+    OSU_CATEGORY_COLLECTIVE = "collective"
+    OSU_CATEGORY_ONE_SIDED = "one-sided"
+    OSU_CATEGORY_STARTUP = "startup"
+)
+
+type OSU_benchmark struct {
+    // Name of the benchmark, same as the binary name. ex: osu_latency
+    Name string
+    // Category/sub-directory of the benchmark. ex: pt2pt
+    Category string
+    // Absolute path of the benchmark binary
+    Path string
+    // Run the benchmark when no explicit selection is given
+    Default bool
+}
+
+// Check if the benchmark accepts an OSU option(-m/-i/-x/-f/-M).
+// Only collective benchmarks report full statistics and osu_barrier
+// doesn't have message sizes.
+func (bench *OSU_benchmark)IsOptionSupported(option string) bool {
+    switch option {
+    case "-f":
+        return bench.Category == OSU_CATEGORY_COLLECTIVE
+    case "-m":
+        return bench.Name != "osu_barrier"
+    }
+    return true
+}
+
+// Benchmarks known to the application out of the box.
+var osu_builtin_benchmarks = []OSU_benchmark {
+    {Name: "osu_latency", Category: OSU_CATEGORY_PT2PT, Default: true},
+    {Name: "osu_bw", Category: OSU_CATEGORY_PT2PT, Default: true},
+    {Name: "osu_bibw", Category: OSU_CATEGORY_PT2PT, Default: true},
+    {Name: "osu_mbw_mr", Category: OSU_CATEGORY_PT2PT},
+    {Name: "osu_multi_lat", Category: OSU_CATEGORY_PT2PT},
+    {Name: "osu_allgather", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_allgatherv", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_allreduce", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_alltoall", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_alltoallv", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_barrier", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_bcast", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_gather", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_gatherv", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_reduce", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_reduce_scatter", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_scatter", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_scatterv", Category: OSU_CATEGORY_COLLECTIVE},
+    {Name: "osu_put_latency", Category: OSU_CATEGORY_ONE_SIDED},
+    {Name: "osu_get_latency", Category: OSU_CATEGORY_ONE_SIDED},
+    {Name: "osu_put_bw", Category: OSU_CATEGORY_ONE_SIDED},
+    {Name: "osu_get_bw", Category: OSU_CATEGORY_ONE_SIDED},
+    {Name: "osu_put_bibw", Category: OSU_CATEGORY_ONE_SIDED},
+    {Name: "osu_acc_latency", Category: OSU_CATEGORY_ONE_SIDED},
+    {Name: "osu_fop_latency", Category: OSU_CATEGORY_ONE_SIDED},
+    {Name: "osu_cas_latency", Category: OSU_CATEGORY_ONE_SIDED}}
+
+type OSU_benchmark_registry struct {
+    benchmarks map[string]*OSU_benchmark
+    // Registration order of benchmarks, keeps the run order stable.
+    order []string
+    // OSU installation of the benchmarks, nil for the default paths
+    install *OSU_install
+}
+
+// Must be called as constructor before using the registry.
+func (registry *OSU_benchmark_registry)Init() {
+    registry.benchmarks = make(map[string]*OSU_benchmark)
+    registry.order = make([]string, 0)
+}
+
+// Add the OSU benchmarks that are known to the application.
+func (registry *OSU_benchmark_registry)RegisterBuiltins() {
+    for _, bench := range osu_builtin_benchmarks {
+        bench.Path = OSU_INSTALL_DIR + bench.Category + "/" + bench.Name
+        registry.Register(bench)
+    }
+}
+
+func (registry *OSU_benchmark_registry)Register(bench OSU_benchmark) error {
+    if len(bench.Name) == 0 || len(bench.Path) == 0 {
+        return errors.INVALID_INPUT
+    }
+    if _, ok := registry.benchmarks[bench.Name]; ok {
+        return errors.DATA_PRESENT_IN_SYSTEM
+    }
+    registry.benchmarks[bench.Name] = &bench
+    registry.order = append(registry.order, bench.Name)
+    return errors.OP_SUCCESS
+}
+
+func (registry *OSU_benchmark_registry)Get(name string) (*OSU_benchmark,
+                                                         error) {
+    bench, ok := registry.benchmarks[name]
+    if !ok {
+        return nil, errors.DATA_NOT_FOUND
+    }
+    return bench, errors.OP_SUCCESS
+}
+
+// Load extra benchmarks from a registry file. Each line of the file is
+//   <name> <path> [category]
+// Lines starting with '#' are comments. A benchmark that is already present
+// in the registry gets its path/category overridden by the file entry.
+func (registry *OSU_benchmark_registry)LoadFile(fileName string) error {
+    logger := logging.GetLoggerInstance()
+    fp, err := os.Open(fileName)
+    if err != nil {
+        logger.Error("Failed to open benchmark file %s, err : %s",
+                     fileName, err)
+        return err
+    }
+    defer fp.Close()
+    scanner := bufio.NewScanner(fp)
+    lineNum := 0
+    for scanner.Scan() {
+        lineNum++
+        line := strings.TrimSpace(scanner.Text())
+        if len(line) == 0 || strings.HasPrefix(line, "#") {
+            continue
+        }
+        fields := strings.Fields(line)
+        if len(fields) < 2 {
+            logger.Error("Invalid entry at %s:%d, expected " +
+                         "'<name> <path> [category]'", fileName, lineNum)
+            return errors.INVALID_INPUT
+        }
+        var bench OSU_benchmark
+        bench.Name = fields[0]
+        bench.Path = fields[1]
+        bench.Category = filepath.Base(filepath.Dir(bench.Path))
+        if len(fields) > 2 {
+            bench.Category = fields[2]
+        }
+        if existing, ok := registry.benchmarks[bench.Name]; ok {
+            existing.Path = bench.Path
+            existing.Category = bench.Category
+            continue
+        }
+        registry.Register(bench)
+    }
+    if err = scanner.Err(); err != nil {
+        logger.Error("Failed to read benchmark file %s, err : %s",
+                     fileName, err)
+        return err
+    }
+    return errors.OP_SUCCESS
+}
+
+func is_pattern_match(patterns []string, name string) bool {
+    for _, pattern := range patterns {
+        if matched, _ := path.Match(pattern, name); matched {
+            return true
+        }
+    }
+    return false
+}
+
+// Select the benchmarks to run. 'include' can have benchmark names, glob
+// patterns(osu_*bw) or absolute paths to benchmark binaries. A path
+// overrides the registered path and category of a known benchmark and
+// registers an unknown one. Empty 'include' selects the default benchmarks.
+// Benchmarks matching any of the 'exclude' patterns are dropped.
+func (registry *OSU_benchmark_registry)Select(include []string,
+                                              exclude []string) (
+                                              []*OSU_benchmark, error) {
+    logger := logging.GetLoggerInstance()
+    selected := make(map[string]bool)
+    for _, pattern := range include {
+        if strings.Contains(pattern, "/") {
+            // Binary outside the registry, register on the fly.
+            var bench OSU_benchmark
+            bench.Name = filepath.Base(pattern)
+            bench.Path = pattern
+            bench.Category = filepath.Base(filepath.Dir(pattern))
+            if existing, ok := registry.benchmarks[bench.Name]; ok {
+                // Known benchmark at a different path, same as a
+                // benchmark file entry.
+                existing.Path = bench.Path
+                existing.Category = bench.Category
+            } else if registry.Register(bench) != errors.OP_SUCCESS {
+                logger.Error("Invalid benchmark path %s", pattern)
+                return nil, errors.INVALID_INPUT
+            }
+            selected[bench.Name] = true
+            continue
+        }
+        if _, err := path.Match(pattern, ""); err != nil {
+            logger.Error("Invalid benchmark pattern %s", pattern)
+            return nil, errors.INVALID_INPUT
+        }
+        found := false
+        for _, name := range registry.order {
+            if is_pattern_match([]string{pattern}, name) {
+                selected[name] = true
+                found = true
+            }
+        }
+        if !found {
+            logger.Error("No benchmark matches %s", pattern)
+            return nil, errors.DATA_NOT_FOUND
+        }
+    }
+    benchmarks := make([]*OSU_benchmark, 0)
+    for _, name := range registry.order {
+        bench := registry.benchmarks[name]
+        if len(include) == 0 {
+            if !bench.Default {
+                continue
+            }
+        } else if !selected[name] {
+            continue
+        }
+        if is_pattern_match(exclude, name) {
+            continue
+        }
+        benchmarks = append(benchmarks, bench)
+    }
+    if len(benchmarks) == 0 {
+        logger.Error("No benchmarks are selected to run")
+        return nil, errors.INVALID_INPUT
+    }
+    return benchmarks, errors.OP_SUCCESS
+}
+
+// Print the registered benchmarks, sorted by category and name.
+func (registry *OSU_benchmark_registry)List(out io.Writer) {
+    names := make([]string, len(registry.order))
+    copy(names, registry.order)
+    sort.SliceStable(names, func(i, j int) bool {
+        bi := registry.benchmarks[names[i]]
+        bj := registry.benchmarks[names[j]]
+        if bi.Category != bj.Category {
+            return bi.Category < bj.Category
+        }
+        return bi.Name < bj.Name
+    })
+    if registry.install != nil {
+        fmt.Fprintf(out, "OSU installation : %s(%s)\n", registry.install.Dir,
+                    registry.install.Source)
+    }
+    fmt.Fprintf(out, "%-24s %-12s %-8s %s\n",
+                "BENCHMARK", "CATEGORY", "DEFAULT", "PATH")
+    for _, name := range names {
+        bench := registry.benchmarks[name]
+        fmt.Fprintf(out, "%-24s %-12s %-8t %s\n",
+                    bench.Name, bench.Category, bench.Default, bench.Path)
+    }
+}
+
+// Build the benchmark registry for the application configuration. The
+// benchmarks of the discovered OSU installation override the default
+// paths and the benchmark file overrides both.
+func Get_OSU_benchmark_registry(configObj *config.AppConfig) (
+                                *OSU_benchmark_registry, error) {
+    registry := new(OSU_benchmark_registry)
+    registry.Init()
+    registry.RegisterBuiltins()
+    install, err := Discover_OSU_install(configObj)
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    if install != nil {
+        err = registry.RegisterInstall(install)
+        // A stale well-known prefix leaves the default paths in place.
+        if err != errors.OP_SUCCESS &&
+           install.Source != OSU_INSTALL_SOURCE_WELL_KNOWN {
+            return nil, err
+        }
+    }
+    if len(configObj.BenchmarkFile) != 0 {
+        err := registry.LoadFile(configObj.BenchmarkFile)
+        if err != errors.OP_SUCCESS {
+            return nil, err
+        }
+    }
+    return registry, errors.OP_SUCCESS
+}
@@ -0,0 +1,44 @@
+package testRunner
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "testing"
+    "ec2-osu-benchmark/errors"
+)
+
+func newBuiltinRegistry() *OSU_benchmark_registry {
+    registry := new(OSU_benchmark_registry)
+    registry.Init()
+    registry.RegisterBuiltins()
+    return registry
+}
+
+// A path of a known benchmark overrides its path and category, the same
+// for -benchmarks and a benchmark file.
+func TestRegistryPathOverride(t *testing.T) {
+    benchPath := "/opt/osu/one-sided/osu_bw"
+    selectRegistry := newBuiltinRegistry()
+    selected, err := selectRegistry.Select([]string{benchPath}, nil)
+    if err != errors.OP_SUCCESS || len(selected) != 1 {
+        t.Fatalf("Select returned %v, err : %v", selected, err)
+    }
+    fileName := filepath.Join(t.TempDir(), "benchmarks")
+    err = ioutil.WriteFile(fileName, []byte("osu_bw " + benchPath + "\n"),
+                           0644)
+    if err != nil {
+        t.Fatal(err)
+    }
+    fileRegistry := newBuiltinRegistry()
+    if err = fileRegistry.LoadFile(fileName); err != errors.OP_SUCCESS {
+        t.Fatalf("LoadFile failed, err : %s", err)
+    }
+    for _, registry := range []*OSU_benchmark_registry{selectRegistry,
+                                                       fileRegistry} {
+        bench, err := registry.Get("osu_bw")
+        if err != errors.OP_SUCCESS || bench.Path != benchPath ||
+           bench.Category != "one-sided" {
+            t.Errorf("osu_bw is %+v, err : %v", bench, err)
+        }
+    }
+}
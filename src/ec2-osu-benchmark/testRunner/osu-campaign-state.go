@@ -0,0 +1,218 @@
+package testRunner
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+// Status of a campaign
+const (
+    CAMPAIGN_STATUS_RUNNING = "running"
+    CAMPAIGN_STATUS_INTERRUPTED = "interrupted"
+    CAMPAIGN_STATUS_COMPLETED = "completed"
+)
+
+// A job of the campaign, the (benchmark, parameter set, repetition) tuple
+// and the status of its last run.
+type OSU_campaign_job struct {
+    // Name of the job, the stem of its result files
+    Name string `json:"name"`
+    Benchmark string `json:"benchmark"`
+    NP uint `json:"np"`
+    PPN uint `json:"ppn"`
+    MapBy string `json:"mapby,omitempty"`
+    BindTo string `json:"bindto,omitempty"`
+    Variant string `json:"variant,omitempty"`
+    Hosts []string `json:"hosts,omitempty"`
+    Repetition uint `json:"repetition"`
+    // One of RUN_STATUS_*, empty when the job is not run yet
+    Status string `json:"status,omitempty"`
+}
+
+// State of the campaign, persisted in the result directory after every
+// job so that an interrupted campaign can be resumed with -resume.
+type OSU_campaign_state struct {
+    // Commandline of the campaign, the resumed campaign runs with it
+    Args []string `json:"args"`
+    // One of CAMPAIGN_STATUS_*
+    Status string `json:"status"`
+    StartTime time.Time `json:"starttime"`
+    // Start of every run resuming the campaign
+    ResumeTimes []time.Time `json:"resumetimes,omitempty"`
+    UpdateTime time.Time `json:"updatetime"`
+    Jobs []*OSU_campaign_job `json:"jobs"`
+    // Jobs by name
+    jobs map[string]*OSU_campaign_job
+    fileName string
+    // Parallel jobs end at the same time
+    mutex sync.Mutex
+}
+
+// Only the successful jobs are done, the rest are run again on resume. An
+// interrupted job is killed midway, a failed or timed out job may be down
+// to the cluster(ex: a host dropping its ssh connection).
+func is_job_done(status string) bool {
+    return status == RUN_STATUS_SUCCESS
+}
+
+func new_campaign_job(job *OSU_job) *OSU_campaign_job {
+    campaignJob := new(OSU_campaign_job)
+    campaignJob.Name = job.Name()
+    campaignJob.Benchmark = job.Bench.Name
+    campaignJob.NP = job.NP
+    campaignJob.PPN = job.PPN
+    campaignJob.MapBy = job.MapBy
+    campaignJob.BindTo = job.BindTo
+    campaignJob.Variant = job.Variant
+    if job.Pair != nil {
+        campaignJob.Hosts = job.Pair.Hosts
+    }
+    campaignJob.Repetition = job.Repetition
+    return campaignJob
+}
+
+func Read_campaign_state(resultDir string) (*OSU_campaign_state, error) {
+    logger := logging.GetLoggerInstance()
+    fileName := filepath.Join(resultDir, config.CAMPAIGN_STATE_FILE)
+    jsonBytes, err := ioutil.ReadFile(fileName)
+    if err != nil {
+        logger.Error("Failed to read campaign state %s, err : %s",
+                     fileName, err)
+        return nil, err
+    }
+    state := new(OSU_campaign_state)
+    if err = json.Unmarshal(jsonBytes, state); err != nil {
+        logger.Error("Invalid campaign state %s, err : %s", fileName, err)
+        return nil, err
+    }
+    state.fileName = fileName
+    state.jobs = make(map[string]*OSU_campaign_job)
+    for _, campaignJob := range state.Jobs {
+        state.jobs[campaignJob.Name] = campaignJob
+    }
+    return state, errors.OP_SUCCESS
+}
+
+// Write the state to a temporary file and rename it, the state file is
+// never left half written when the instance goes down.
+func (state *OSU_campaign_state)Write() error {
+    state.mutex.Lock()
+    defer state.mutex.Unlock()
+    logger := logging.GetLoggerInstance()
+    state.UpdateTime = time.Now()
+    jsonBytes, err := json.MarshalIndent(state, "", "  ")
+    if err != nil {
+        logger.Error("Failed to marshal campaign state, err : %s", err)
+        return err
+    }
+    tmpFileName := state.fileName + ".tmp"
+    err = ioutil.WriteFile(tmpFileName, jsonBytes, 0644)
+    if err == nil {
+        err = os.Rename(tmpFileName, state.fileName)
+    }
+    if err != nil {
+        logger.Error("Failed to write campaign state %s, err : %s",
+                     state.fileName, err)
+        return err
+    }
+    return errors.OP_SUCCESS
+}
+
+func (state *OSU_campaign_state)Is_done(job *OSU_job) bool {
+    campaignJob, ok := state.jobs[job.Name()]
+    return ok && is_job_done(campaignJob.Status)
+}
+
+// Record the status of the job's run.
+func (state *OSU_campaign_state)End_job(job *OSU_job, status string) error {
+    campaignJob, ok := state.jobs[job.Name()]
+    if !ok {
+        return errors.DATA_NOT_FOUND
+    }
+    state.mutex.Lock()
+    campaignJob.Status = status
+    state.mutex.Unlock()
+    return state.Write()
+}
+
+// Record the end of the campaign, interrupted or completed.
+func (state *OSU_campaign_state)End(status string) error {
+    state.Status = status
+    return state.Write()
+}
+
+// Create the state of a new campaign, or add the jobs and the resume time
+// to the state of the resumed campaign.
+func (mpi_cmd_obj *OSU_MPI_cmds)start_campaign(jobs []*OSU_job) error {
+    logger := logging.GetLoggerInstance()
+    state := mpi_cmd_obj.campaign
+    resumed := state != nil
+    if !resumed {
+        state = new(OSU_campaign_state)
+        state.Args = mpi_cmd_obj.configObj.Args
+        state.StartTime = time.Now()
+        state.fileName = filepath.Join(mpi_cmd_obj.result_dir,
+                                       config.CAMPAIGN_STATE_FILE)
+        state.jobs = make(map[string]*OSU_campaign_job)
+        mpi_cmd_obj.campaign = state
+    } else {
+        state.ResumeTimes = append(state.ResumeTimes, time.Now())
+    }
+    for _, job := range jobs {
+        if _, ok := state.jobs[job.Name()]; ok {
+            continue
+        }
+        if resumed {
+            // Benchmarks of the installation changed since the start.
+            logger.Warning("Job %s is not in the campaign, adding it",
+                           job.Name())
+        }
+        campaignJob := new_campaign_job(job)
+        state.Jobs = append(state.Jobs, campaignJob)
+        state.jobs[campaignJob.Name] = campaignJob
+    }
+    state.Status = CAMPAIGN_STATUS_RUNNING
+    return state.Write()
+}
+
+// Status of the campaign, one of CAMPAIGN_STATUS_*, empty when it is not
+// started.
+func (mpi_cmd_obj *OSU_MPI_cmds)Get_campaign_status() string {
+    if mpi_cmd_obj.campaign == nil {
+        return ""
+    }
+    return mpi_cmd_obj.campaign.Status
+}
+
+// Jobs of the campaign that are not done yet, all the jobs of a new
+// campaign.
+func (mpi_cmd_obj *OSU_MPI_cmds)get_pending_jobs(
+                                    jobs []*OSU_job) []*OSU_job {
+    if mpi_cmd_obj.campaign == nil {
+        return jobs
+    }
+    pending := make([]*OSU_job, 0, len(jobs))
+    for _, job := range jobs {
+        if !mpi_cmd_obj.campaign.Is_done(job) {
+            pending = append(pending, job)
+        }
+    }
+    return pending
+}
+
+// Remove the files of an earlier run of the job, the results of the job
+// are streamed by appending to its result files.
+func (mpi_cmd_obj *OSU_MPI_cmds)remove_job_files(job *OSU_job) {
+    for _, suffix := range []string{".txt", ".stderr",
+                                    RUN_RECORD_FILE_SUFFIX,
+                                    TELEMETRY_FILE_SUFFIX} {
+        os.Remove(mpi_cmd_obj.get_job_fileName(job, suffix))
+    }
+}
@@ -0,0 +1,149 @@
+package testRunner
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "path/filepath"
+    "strings"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+// A job of the dry run, how it would be run and the files it would
+// produce.
+type OSU_dry_run_job struct {
+    Name string `json:"name"`
+    Record *OSU_run_record `json:"record"`
+    Argv []string `json:"argv"`
+    Files []string `json:"files"`
+}
+
+// Everything a run would do, resolved from the configuration without
+// running any benchmark or creating any file.
+type OSU_dry_run struct {
+    Launcher string `json:"launcher"`
+    RunID string `json:"runid"`
+    ResultDir string `json:"resultdir"`
+    // Jobs run at a time, their hosts are picked when they start
+    Parallel uint `json:"parallel"`
+    Jobs []*OSU_dry_run_job `json:"jobs"`
+    // Files of the run that don't belong to a single job
+    Files []string `json:"files"`
+    // Problems that would make the jobs fail
+    Problems []string `json:"problems"`
+}
+
+// Resolve the jobs of the campaign to their commands and files. The
+// benchmark binaries are checked the same way a real run does.
+func (mpi_cmd_obj *OSU_MPI_cmds)Dry_run() *OSU_dry_run {
+    dryRun := new(OSU_dry_run)
+    dryRun.Launcher = mpi_cmd_obj.launcher.Name()
+    dryRun.RunID = mpi_cmd_obj.run_info.RunID
+    dryRun.ResultDir = mpi_cmd_obj.result_dir
+    dryRun.Parallel = mpi_cmd_obj.configObj.Parallel
+    dryRun.Jobs = make([]*OSU_dry_run_job, 0)
+    dryRun.Problems = make([]string, 0)
+    checked := make(map[string]bool)
+    // A resumed campaign runs only the jobs that are not done.
+    jobs := mpi_cmd_obj.get_pending_jobs(mpi_cmd_obj.build_jobs())
+    for _, job := range jobs {
+        bench := job.Bench
+        if !checked[bench.Path] {
+            checked[bench.Path] = true
+            if mpi_cmd_obj.IsCmdExists(bench.Path) == false {
+                dryRun.Problems = append(dryRun.Problems,
+                    fmt.Sprintf("%s : %s is not found", bench.Name,
+                                bench.Path))
+            }
+        }
+        argv, record, err := mpi_cmd_obj.prepare_job(job)
+        if err != errors.OP_SUCCESS {
+            dryRun.Problems = append(dryRun.Problems,
+                fmt.Sprintf("%s : failed to build the %s command, err : %s",
+                            job.Name(), dryRun.Launcher, err))
+            continue
+        }
+        dryJob := new(OSU_dry_run_job)
+        dryJob.Name = job.Name()
+        dryJob.Record = record
+        dryJob.Argv = argv
+        dryJob.Files = []string{record.StdoutFile, record.StderrFile,
+            mpi_cmd_obj.get_job_fileName(job, RUN_RECORD_FILE_SUFFIX)}
+        if mpi_cmd_obj.configObj.Telemetry > 0 {
+            dryJob.Files = append(dryJob.Files,
+                mpi_cmd_obj.get_job_fileName(job, TELEMETRY_FILE_SUFFIX))
+        }
+        dryRun.Jobs = append(dryRun.Jobs, dryJob)
+    }
+    dryRun.Files = make([]string, 0)
+    if !mpi_cmd_obj.configObj.SkipPreflight {
+        hosts, err := Read_hostfile(mpi_cmd_obj.configObj.HostFile)
+        if err == errors.OP_SUCCESS {
+            for idx := range hosts {
+                dryRun.Files = append(dryRun.Files,
+                    mpi_cmd_obj.get_preflight_hostfile(idx))
+            }
+        }
+        dryRun.Files = append(dryRun.Files,
+            mpi_cmd_obj.result_dir + PREFLIGHT_REPORT_FILE)
+    }
+    for _, pair := range mpi_cmd_obj.host_pairs {
+        dryRun.Files = append(dryRun.Files, pair.HostFile)
+    }
+    dryRun.Files = append(dryRun.Files,
+        mpi_cmd_obj.result_dir + config.RUN_FILE,
+        mpi_cmd_obj.result_dir + config.PLAN_FILE,
+        mpi_cmd_obj.result_dir + config.MANIFEST_FILE,
+        mpi_cmd_obj.result_dir + config.CAMPAIGN_STATE_FILE,
+        mpi_cmd_obj.result_dir + config.DEFAULT_REPORT_FILE)
+    if mpi_cmd_obj.configObj.PairMatrix {
+        for _, benchName := range PAIR_MATRIX_BENCHMARKS {
+            dryRun.Files = append(dryRun.Files, filepath.Join(
+                mpi_cmd_obj.result_dir, "host-matrix-" + benchName + ".csv"))
+        }
+    }
+    if mpi_cmd_obj.configObj.Progress {
+        dryRun.Files = append(dryRun.Files, mpi_cmd_obj.configObj.HistoryFile)
+    }
+    return dryRun
+}
+
+// Print the dry run in the format, one of config.DryRunFormats.
+func (dryRun *OSU_dry_run)Write(out io.Writer, format string) error {
+    if format == "json" {
+        jsonBytes, err := json.MarshalIndent(dryRun, "", "  ")
+        if err != nil {
+            return err
+        }
+        _, err = out.Write(append(jsonBytes, '\n'))
+        if err != nil {
+            return err
+        }
+        return errors.OP_SUCCESS
+    }
+    fmt.Fprintf(out, "Launcher   : %s\n", dryRun.Launcher)
+    fmt.Fprintf(out, "Run ID     : %s\n", dryRun.RunID)
+    fmt.Fprintf(out, "Result dir : %s\n", dryRun.ResultDir)
+    fmt.Fprintf(out, "Parallel   : %d\n", dryRun.Parallel)
+    fmt.Fprintf(out, "Jobs       : %d\n", len(dryRun.Jobs))
+    for idx, dryJob := range dryRun.Jobs {
+        fmt.Fprintf(out, "[%d/%d] %s\n", idx + 1, len(dryRun.Jobs),
+                    dryJob.Name)
+        fmt.Fprintf(out, "    command : %s\n", dryJob.Record.Command)
+        fmt.Fprintf(out, "    timeout : %s\n", dryJob.Record.Timeout)
+        fmt.Fprintf(out, "    files   : %s\n",
+                    strings.Join(dryJob.Files, " "))
+    }
+    fmt.Fprintf(out, "Files :\n")
+    for _, fileName := range dryRun.Files {
+        fmt.Fprintf(out, "    %s\n", fileName)
+    }
+    if len(dryRun.Problems) != 0 {
+        fmt.Fprintf(out, "Problems :\n")
+        for _, problem := range dryRun.Problems {
+            fmt.Fprintf(out, "    %s\n", problem)
+        }
+    }
+    return errors.OP_SUCCESS
+}
@@ -0,0 +1,105 @@
+package testRunner
+
+import (
+    "context"
+    "io"
+    "os/exec"
+    "sync"
+    "syscall"
+    "time"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+)
+
+// Time given to the launcher to tear down the remote ranks after SIGTERM,
+// the process group is killed after it.
+var KILL_GRACE_PERIOD = 10 * time.Second
+
+// Status of a command execution.
+type OSU_exec_status struct {
+    ExitCode int
+    // Signal that terminated the command, empty if it exited normally
+    Signal string
+    // Command is killed as it exceeds the deadline
+    TimedOut bool
+    // Command is killed as the campaign is interrupted(SIGINT/SIGTERM)
+    Interrupted bool
+}
+
+// Kill all the processes in the process group.
+func kill_process_group(pgid int, sig syscall.Signal) {
+    if pgid <= 0 {
+        return
+    }
+    syscall.Kill(-pgid, sig)
+}
+
+// Run a command in its own process group. When the context is done, the
+// whole group(launcher + its orted/hydra children) gets SIGTERM and then
+// SIGKILL after KILL_GRACE_PERIOD.
+func run_command(ctx context.Context, argv []string,
+                 stdout io.Writer, stderr io.Writer) (*OSU_exec_status, error) {
+    logger := logging.GetLoggerInstance()
+    if len(argv) == 0 {
+        return nil, errors.INVALID_INPUT
+    }
+    status := new(OSU_exec_status)
+    cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+    cmd.Stdout = stdout
+    cmd.Stderr = stderr
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+    // SIGKILL of the group after the grace period, stopped once the
+    // command is done so it never hits a reused process group. cancelErr
+    // is the context error when the command is killed, a command that
+    // exits on its own just before the deadline is not killed.
+    var killTimer *time.Timer
+    var cancelErr error
+    var killMutex sync.Mutex
+    cmd.Cancel = func() error {
+        pgid := cmd.Process.Pid
+        kill_process_group(pgid, syscall.SIGTERM)
+        killMutex.Lock()
+        cancelErr = ctx.Err()
+        killTimer = time.AfterFunc(KILL_GRACE_PERIOD, func() {
+            kill_process_group(pgid, syscall.SIGKILL)
+        })
+        killMutex.Unlock()
+        return nil
+    }
+    // Orphaned children can hold the output pipes, don't wait for them
+    // forever after the command is done.
+    cmd.WaitDelay = KILL_GRACE_PERIOD + time.Second
+    err := cmd.Start()
+    if err != nil {
+        logger.Error("Failed to start %s, err : %s", argv[0], err)
+        return nil, err
+    }
+    err = cmd.Wait()
+    killMutex.Lock()
+    if killTimer != nil {
+        killTimer.Stop()
+    }
+    killed := cancelErr
+    killMutex.Unlock()
+    switch killed {
+    case context.DeadlineExceeded:
+        status.TimedOut = true
+    case context.Canceled:
+        status.Interrupted = true
+    }
+    if killed != nil {
+        // Reap whatever is left in the group.
+        kill_process_group(cmd.Process.Pid, syscall.SIGKILL)
+    }
+    if cmd.ProcessState != nil {
+        status.ExitCode = cmd.ProcessState.ExitCode()
+        waitStatus, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+        if ok && waitStatus.Signaled() {
+            status.Signal = waitStatus.Signal().String()
+        }
+    }
+    if err != nil {
+        return status, err
+    }
+    return status, errors.OP_SUCCESS
+}
@@ -0,0 +1,60 @@
+package testRunner
+
+import (
+    "context"
+    "io"
+    "os/exec"
+    "ec2-osu-benchmark/errors"
+)
+
+// CommandExecutor runs the external commands of the application, the MPI
+// launchers and their version queries. A simulator can stand in for the
+// system so the runner is testable without a MPI installation.
+type CommandExecutor interface {
+    // Check if the command(name in PATH or a path) is present
+    LookPath(cmd string) bool
+    // Combined stdout and stderr of a short lived command
+    Output(argv []string) ([]byte, error)
+    // Run a command till it exits or the context is done, see run_command
+    Run(ctx context.Context, argv []string,
+        stdout io.Writer, stderr io.Writer) (*OSU_exec_status, error)
+}
+
+// Executor that runs the commands in the system.
+type System_executor struct {}
+
+func (executor *System_executor)LookPath(cmd string) bool {
+    err := exec.Command("/bin/sh", "-c", "command -v " + cmd).Run()
+    return err == nil
+}
+
+func (executor *System_executor)Output(argv []string) ([]byte, error) {
+    if len(argv) == 0 {
+        return nil, errors.INVALID_INPUT
+    }
+    res, err := exec.Command(argv[0], argv[1:]...).CombinedOutput()
+    if err != nil {
+        return res, err
+    }
+    return res, errors.OP_SUCCESS
+}
+
+func (executor *System_executor)Run(ctx context.Context, argv []string,
+                                    stdout io.Writer,
+                                    stderr io.Writer) (*OSU_exec_status,
+                                                       error) {
+    return run_command(ctx, argv, stdout, stderr)
+}
+
+// Run the commands through the executor instead of the system, must be
+// called before Init_OSU_MPI_Cmds.
+func (mpi_cmd_obj *OSU_MPI_cmds)Set_command_executor(
+                                    executor CommandExecutor) {
+    mpi_cmd_obj.executor = executor
+}
+
+// Read /proc, /sys and /etc of the host under the root instead of /, must
+// be called before Init_OSU_MPI_Cmds.
+func (mpi_cmd_obj *OSU_MPI_cmds)Set_host_root(root string) {
+    mpi_cmd_obj.host_root = root
+}
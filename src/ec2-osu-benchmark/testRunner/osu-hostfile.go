@@ -0,0 +1,108 @@
+package testRunner
+
+import (
+    "bufio"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "strconv"
+    "strings"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+type OSU_host struct {
+    Name string
+    // Number of slots of the host, 0 when not given in the hostfile
+    Slots uint
+}
+
+// Read the hosts in a MPI hostfile. The Open MPI('host slots=4'),
+// MPICH/Intel MPI('host:4') and plain('host') formats are supported.
+// Lines starting with '#' are comments.
+func Read_hostfile(fileName string) ([]OSU_host, error) {
+    logger := logging.GetLoggerInstance()
+    fp, err := os.Open(fileName)
+    if err != nil {
+        logger.Error("Failed to open hostfile %s, err : %s", fileName, err)
+        return nil, err
+    }
+    defer fp.Close()
+    hosts := make([]OSU_host, 0)
+    scanner := bufio.NewScanner(fp)
+    for scanner.Scan() {
+        line := scanner.Text()
+        if idx := strings.Index(line, "#"); idx >= 0 {
+            line = line[:idx]
+        }
+        fields := strings.Fields(line)
+        if len(fields) == 0 {
+            continue
+        }
+        var host OSU_host
+        host.Name = fields[0]
+        if idx := strings.Index(host.Name, ":"); idx >= 0 {
+            slots, err := strconv.ParseUint(host.Name[idx + 1:], 10, 32)
+            if err != nil {
+                logger.Error("Invalid host entry %s in %s", line, fileName)
+                return nil, errors.INVALID_INPUT
+            }
+            host.Name = host.Name[:idx]
+            host.Slots = uint(slots)
+        }
+        for _, field := range fields[1:] {
+            if !strings.HasPrefix(field, "slots=") {
+                continue
+            }
+            slots, err := strconv.ParseUint(
+                            strings.TrimPrefix(field, "slots="), 10, 32)
+            if err != nil {
+                logger.Error("Invalid host entry %s in %s", line, fileName)
+                return nil, errors.INVALID_INPUT
+            }
+            host.Slots = uint(slots)
+        }
+        hosts = append(hosts, host)
+    }
+    if err = scanner.Err(); err != nil {
+        logger.Error("Failed to read hostfile %s, err : %s", fileName, err)
+        return nil, err
+    }
+    return hosts, errors.OP_SUCCESS
+}
+
+// Hostnames of the hosts.
+func Get_host_names(hosts []OSU_host) []string {
+    names := make([]string, len(hosts))
+    for idx, host := range hosts {
+        names[idx] = host.Name
+    }
+    return names
+}
+
+// Write the hosts to a hostfile in the format of the launcher, hosts
+// without slots are written plain.
+func Write_hostfile(hosts []OSU_host, fileName string,
+                    launcherName string) error {
+    logger := logging.GetLoggerInstance()
+    var content strings.Builder
+    for _, host := range hosts {
+        switch {
+        case host.Slots == 0:
+            content.WriteString(host.Name)
+        case launcherName == config.LAUNCHER_OPENMPI:
+            content.WriteString(fmt.Sprintf("%s slots=%d", host.Name,
+                                            host.Slots))
+        default:
+            content.WriteString(fmt.Sprintf("%s:%d", host.Name, host.Slots))
+        }
+        content.WriteString("\n")
+    }
+    err := ioutil.WriteFile(fileName, []byte(content.String()), 0644)
+    if err != nil {
+        logger.Error("Failed to write hostfile %s, err : %s", fileName, err)
+        return err
+    }
+    return errors.OP_SUCCESS
+}
@@ -0,0 +1,131 @@
+package testRunner
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+// Prefixes searched for an OSU installation when neither -osu-prefix nor
+// OSU_HOME is given, in the order of preference.
+var OSU_WELL_KNOWN_PREFIXES = []string{"/usr/local", "/usr",
+    "/opt/osu-micro-benchmarks", "/opt/osu"}
+
+// Source of an installation found in OSU_WELL_KNOWN_PREFIXES.
+const OSU_INSTALL_SOURCE_WELL_KNOWN = "well-known"
+
+// Sub-directories of the MPI benchmarks of an OSU installation.
+var OSU_CATEGORIES = []string{OSU_CATEGORY_PT2PT, OSU_CATEGORY_COLLECTIVE,
+    OSU_CATEGORY_ONE_SIDED, OSU_CATEGORY_STARTUP}
+
+// OSU installation the benchmarks are run from.
+type OSU_install struct {
+    // Directory with the category sub-directories
+    Dir string
+    // Where the installation is found: -osu-prefix, OSU_HOME or well-known
+    Source string
+}
+
+// Directory with the benchmark categories of the installation at the
+// prefix, empty when there is no installation. The prefix can be the
+// install prefix(make install --prefix), its libexec/osu-micro-benchmarks
+// or the mpi directory itself.
+func Find_OSU_install_dir(prefix string) string {
+    candidates := []string{prefix, filepath.Join(prefix, "mpi"),
+        filepath.Join(prefix, "libexec", "osu-micro-benchmarks", "mpi")}
+    for _, dir := range candidates {
+        for _, category := range OSU_CATEGORIES {
+            info, err := os.Stat(filepath.Join(dir, category))
+            if err == nil && info.IsDir() {
+                return dir
+            }
+        }
+    }
+    return ""
+}
+
+// Find the OSU installation, -osu-prefix and OSU_HOME must point to an
+// installation, the well-known prefixes are tried only when neither is
+// given. Returns nil when no installation is found.
+func Discover_OSU_install(configObj *config.AppConfig) (*OSU_install,
+                                                         error) {
+    logger := logging.GetLoggerInstance()
+    sources := []struct {
+        source string
+        prefix string
+    }{{"-osu-prefix", configObj.OSUPrefix},
+      {config.OSU_HOME_ENV, os.Getenv(config.OSU_HOME_ENV)}}
+    for _, entry := range sources {
+        if len(entry.prefix) == 0 {
+            continue
+        }
+        dir := Find_OSU_install_dir(entry.prefix)
+        if len(dir) == 0 {
+            logger.Error("No OSU installation in %s(%s)", entry.prefix,
+                         entry.source)
+            return nil, errors.DATA_NOT_FOUND
+        }
+        return &OSU_install{dir, entry.source}, errors.OP_SUCCESS
+    }
+    for _, prefix := range OSU_WELL_KNOWN_PREFIXES {
+        if dir := Find_OSU_install_dir(prefix); len(dir) != 0 {
+            return &OSU_install{dir, OSU_INSTALL_SOURCE_WELL_KNOWN},
+                   errors.OP_SUCCESS
+        }
+    }
+    logger.Warning("No OSU installation is found, using the default " +
+                   "paths under %s", OSU_INSTALL_DIR)
+    return nil, errors.OP_SUCCESS
+}
+
+// Register the benchmarks of the installation. Every executable osu_*
+// in the category directories is registered, known benchmarks are run
+// from the installation.
+func (registry *OSU_benchmark_registry)RegisterInstall(
+                                            install *OSU_install) error {
+    logger := logging.GetLoggerInstance()
+    found := 0
+    for _, category := range OSU_CATEGORIES {
+        entries, err := ioutil.ReadDir(filepath.Join(install.Dir, category))
+        if err != nil {
+            continue
+        }
+        for _, entry := range entries {
+            if !strings.HasPrefix(entry.Name(), "osu_") {
+                continue
+            }
+            binary := filepath.Join(install.Dir, category, entry.Name())
+            // Stat follows the symbolic links of the entry.
+            info, err := os.Stat(binary)
+            if err != nil || info.IsDir() || info.Mode() & 0111 == 0 {
+                continue
+            }
+            found++
+            if existing, ok := registry.benchmarks[entry.Name()]; ok {
+                existing.Path = binary
+                existing.Category = category
+                continue
+            }
+            registry.Register(OSU_benchmark{Name: entry.Name(),
+                                            Category: category,
+                                            Path: binary})
+        }
+    }
+    if found == 0 {
+        logger.Error("No OSU benchmarks in %s", install.Dir)
+        return errors.DATA_NOT_FOUND
+    }
+    logger.Info("Found %d OSU benchmarks in %s(%s)", found, install.Dir,
+                install.Source)
+    registry.install = install
+    return errors.OP_SUCCESS
+}
+
+// Installation of the registered benchmarks, nil when none is found.
+func (registry *OSU_benchmark_registry)Get_install() *OSU_install {
+    return registry.install
+}
@@ -0,0 +1,94 @@
+package testRunner
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+    "ec2-osu-benchmark/config"
+    "ec2-osu-benchmark/errors"
+)
+
+// OSU installation with the binaries under <prefix>/libexec/..., the
+// same as 'make install --prefix'.
+func writeInstall(t *testing.T, binaries map[string]os.FileMode) string {
+    t.Helper()
+    prefix := t.TempDir()
+    for binary, mode := range binaries {
+        fileName := filepath.Join(prefix, "libexec", "osu-micro-benchmarks",
+                                  "mpi", binary)
+        if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := ioutil.WriteFile(fileName, nil, mode); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return prefix
+}
+
+func TestDiscoverOSUInstall(t *testing.T) {
+    prefix := writeInstall(t, map[string]os.FileMode{
+        "pt2pt/osu_latency": 0755,
+        "startup/osu_hello": 0755,
+        "collective/osu_allreduce": 0644,
+        "pt2pt/README": 0755})
+    installDir := filepath.Join(prefix, "libexec", "osu-micro-benchmarks",
+                                "mpi")
+    t.Setenv(config.OSU_HOME_ENV, "")
+    configObj := new(config.AppConfig)
+    configObj.OSUPrefix = prefix
+    registry, err := Get_OSU_benchmark_registry(configObj)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Get_OSU_benchmark_registry failed, err : %s", err)
+    }
+    install := registry.Get_install()
+    if install == nil || install.Dir != installDir ||
+       install.Source != "-osu-prefix" {
+        t.Fatalf("unexpected installation %+v", install)
+    }
+    tests := []struct {
+        name string
+        path string
+        category string
+    }{
+        {"osu_latency", filepath.Join(installDir, "pt2pt", "osu_latency"),
+         OSU_CATEGORY_PT2PT},
+        {"osu_hello", filepath.Join(installDir, "startup", "osu_hello"),
+         OSU_CATEGORY_STARTUP},
+        // not executable, the default path is kept
+        {"osu_allreduce", OSU_INSTALL_DIR + "collective/osu_allreduce",
+         OSU_CATEGORY_COLLECTIVE},
+    }
+    for _, test := range tests {
+        bench, err := registry.Get(test.name)
+        if err != errors.OP_SUCCESS {
+            t.Errorf("%s is not registered", test.name)
+            continue
+        }
+        if bench.Path != test.path || bench.Category != test.category {
+            t.Errorf("%s is at %s(%s), want %s(%s)", test.name, bench.Path,
+                     bench.Category, test.path, test.category)
+        }
+    }
+    if _, err = registry.Get("README"); err == errors.OP_SUCCESS {
+        t.Errorf("non OSU binary is registered")
+    }
+}
+
+func TestDiscoverOSUHome(t *testing.T) {
+    prefix := writeInstall(t, map[string]os.FileMode{"pt2pt/osu_bw": 0755})
+    t.Setenv(config.OSU_HOME_ENV, filepath.Join(prefix, "libexec",
+                                                "osu-micro-benchmarks"))
+    install, err := Discover_OSU_install(new(config.AppConfig))
+    if err != errors.OP_SUCCESS || install == nil ||
+       install.Source != config.OSU_HOME_ENV {
+        t.Fatalf("unexpected installation %+v, err : %v", install, err)
+    }
+    // An explicit prefix must have an installation.
+    t.Setenv(config.OSU_HOME_ENV, t.TempDir())
+    _, err = Discover_OSU_install(new(config.AppConfig))
+    if err == errors.OP_SUCCESS {
+        t.Errorf("OSU_HOME without an installation is accepted")
+    }
+}
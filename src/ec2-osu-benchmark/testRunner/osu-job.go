@@ -0,0 +1,171 @@
+package testRunner
+
+import (
+    "fmt"
+    "strings"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/config"
+)
+
+// A single benchmark execution of the campaign.
+type OSU_job struct {
+    Bench *OSU_benchmark
+    // Repetition of the benchmark, starts from 1
+    Repetition uint
+    // Total repetitions of the benchmark
+    Repetitions uint
+    // Number of processes and processes per node(0 for launcher default)
+    NP uint
+    PPN uint
+    // Campaign sweeps np/ppn, the configuration is part of the name
+    Sweep bool
+    // Process mapping and binding, empty for the launcher default
+    MapBy string
+    BindTo string
+    // Campaign sweeps mapping/binding policies, they are part of the name
+    PolicySweep bool
+    // Environment variant, empty when the campaign has no variants
+    Variant string
+    // Hosts of the job in the pair matrix mode, nil otherwise
+    Pair *OSU_host_pair
+    // Hosts of the job in the parallel mode, nil otherwise
+    Slice *OSU_host_slice
+    // First repetition of the first configuration of the benchmark
+    Primary bool
+}
+
+// Name of the job, the stem of all its result files.
+// ex: osu_bw, osu_bw.rep2, osu_bw.np8.ppn2.rep2, osu_bw.pair0-3,
+//     osu_latency.mapsocket.bindcore, osu_latency.mapppr-1-socket,
+//     osu_bw.envefa.rep2
+func (job *OSU_job)Name() string {
+    name := job.Bench.Name
+    if job.Pair != nil {
+        name = name + "." + job.Pair.Name
+    }
+    if job.Sweep {
+        name = fmt.Sprintf("%s.np%d", name, job.NP)
+        if job.PPN != 0 {
+            name = fmt.Sprintf("%s.ppn%d", name, job.PPN)
+        }
+    }
+    if job.PolicySweep {
+        if len(job.MapBy) != 0 {
+            name = name + ".map" + strings.Replace(job.MapBy, ":", "-", -1)
+        }
+        if len(job.BindTo) != 0 {
+            name = name + ".bind" + job.BindTo
+        }
+    }
+    if len(job.Variant) != 0 {
+        name = name + ".env" + job.Variant
+    }
+    if job.Repetitions > 1 {
+        name = fmt.Sprintf("%s.rep%d", name, job.Repetition)
+    }
+    return name
+}
+
+// Build the jobs of the campaign. Every repetition runs all the selected
+// benchmarks once with every np/ppn/mapping/binding/variant configuration,
+// so the noise on the instance is spread across the benchmarks instead of
+// hitting all the samples of one. The excluded jobs are left out.
+func (mpi_cmd_obj *OSU_MPI_cmds)build_jobs() []*OSU_job {
+    configObj := mpi_cmd_obj.configObj
+    repetitions := configObj.Repeat
+    if repetitions == 0 {
+        repetitions = 1
+    }
+    if configObj.PairMatrix {
+        return mpi_cmd_obj.build_pair_jobs(repetitions)
+    }
+    configs := get_job_configs(configObj)
+    jobs := make([]*OSU_job, 0)
+    // Benchmarks with a primary job
+    primary := make(map[string]bool)
+    for rep := uint(1); rep <= repetitions; rep++ {
+        for _, jobConfig := range configs {
+            for _, bench := range mpi_cmd_obj.osu_cmds {
+                if configObj.IsJobExcluded(bench.Name, jobConfig.np,
+                                           jobConfig.ppn, jobConfig.mapBy,
+                                           jobConfig.bindTo,
+                                           jobConfig.variant) {
+                    continue
+                }
+                job := new(OSU_job)
+                job.Bench = bench
+                job.Repetition = rep
+                job.Repetitions = repetitions
+                job.NP = jobConfig.np
+                job.PPN = jobConfig.ppn
+                job.Sweep = configObj.IsSweep()
+                job.MapBy = jobConfig.mapBy
+                job.BindTo = jobConfig.bindTo
+                job.PolicySweep = configObj.IsPolicySweep()
+                job.Variant = jobConfig.variant
+                job.Primary = rep == 1 && !primary[bench.Name]
+                primary[bench.Name] = true
+                jobs = append(jobs, job)
+            }
+        }
+    }
+    return jobs
+}
+
+// A np/ppn/mapping/binding/variant configuration of the campaign.
+type osu_job_config struct {
+    np uint
+    ppn uint
+    mapBy string
+    bindTo string
+    variant string
+}
+
+// Cartesian product of the np/ppn sweeps, the policies and the variants,
+// more processes per node than processes are skipped.
+func get_job_configs(configObj *config.AppConfig) []osu_job_config {
+    logger := logging.GetLoggerInstance()
+    configs := make([]osu_job_config, 0)
+    for _, np := range configObj.GetNPList() {
+        for _, ppn := range configObj.GetPPNList() {
+            if ppn > np {
+                logger.Warning("Skipping np %d with ppn %d", np, ppn)
+                continue
+            }
+            for _, policy := range get_policies(configObj.GetMapByList(),
+                                                configObj.GetBindToList()) {
+                for _, variant := range configObj.GetEnvVariantList() {
+                    configs = append(configs, osu_job_config{np, ppn,
+                                     policy[0], policy[1], variant})
+                }
+            }
+        }
+    }
+    return configs
+}
+
+// Every (mapping, binding) combination of the policies.
+func get_policies(mappings []string, bindings []string) [][2]string {
+    policies := make([][2]string, 0, len(mappings) * len(bindings))
+    for _, mapping := range mappings {
+        for _, binding := range bindings {
+            policies = append(policies, [2]string{mapping, binding})
+        }
+    }
+    return policies
+}
+
+// Result file of the job with the suffix. ex: .txt, .stderr
+func (mpi_cmd_obj *OSU_MPI_cmds)get_job_fileName(job *OSU_job,
+                                                 suffix string) string {
+    return mpi_cmd_obj.result_dir + job.Name() + suffix
+}
+
+// Key of the job in the run history, jobs with the same key are expected
+// to take the same time.
+func (mpi_cmd_obj *OSU_MPI_cmds)get_job_history_key(job *OSU_job) string {
+    return fmt.Sprintf("%s np=%d ppn=%d map=%s bind=%s env=%s launcher=%s " +
+                       "args=%v", job.Bench.Name, job.NP, job.PPN, job.MapBy,
+                       job.BindTo, job.Variant, mpi_cmd_obj.launcher.Name(),
+                       mpi_cmd_obj.get_benchmark_args(job.Bench))
+}
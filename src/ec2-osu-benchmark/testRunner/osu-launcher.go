@@ -0,0 +1,417 @@
+package testRunner
+
+import (
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+// Everything a launcher needs to start a MPI job.
+type OSU_launch_spec struct {
+    // Total number of MPI processes
+    NumProcs uint
+    // Hostfile with the hosts of the job
+    HostFile string
+    // Processes per node, 0 for the launcher default
+    PPN uint
+    // Process binding core/socket/numa/hwthread/none, empty for default
+    BindTo string
+    // Process mapping slot/hwthread/core/socket/numa/node or a launcher
+    // specific policy, empty for default
+    MapBy string
+    // Launcher reports where every rank is bound, see IsBindingReport
+    ReportBindings bool
+    // Environment for all the ranks, 'KEY=VALUE' entries
+    Env []string
+    // Extra launcher arguments, added right before the binary
+    ExtraArgs []string
+    // Benchmark binary and its arguments
+    Binary string
+    Args []string
+}
+
+// Launcher translates a launch spec to the commandline of a MPI launcher.
+type Launcher interface {
+    // Name of the launcher, one of config.LauncherTypes
+    Name() string
+    // Launcher binary that must be present in the system
+    Command() string
+    // Commandline to run the spec
+    BuildCmd(spec *OSU_launch_spec) ([]string, error)
+    // Check if the launcher can map and bind the processes with the
+    // policies, empty for the launcher default
+    CheckPolicy(mapBy string, bindTo string) error
+    // Check if an output line of the launcher is part of the binding
+    // report of the ranks(spec.ReportBindings)
+    IsBindingReport(line string) bool
+}
+
+//*****************************************************************************
+//***************************  Open MPI launcher ******************************
+//*****************************************************************************
+type openmpi_launcher struct {}
+
+func (launcher *openmpi_launcher)Name() string {
+    return config.LAUNCHER_OPENMPI
+}
+
+func (launcher *openmpi_launcher)Command() string {
+    return "mpirun"
+}
+
+// Open MPI takes all the mappings/bindings and its own policies.
+func (launcher *openmpi_launcher)CheckPolicy(mapBy string,
+                                             bindTo string) error {
+    return errors.OP_SUCCESS
+}
+
+func (launcher *openmpi_launcher)BuildCmd(spec *OSU_launch_spec) ([]string,
+                                                                   error) {
+    argv := []string{launcher.Command(), "--allow-run-as-root",
+                     "--np", fmt.Sprint(spec.NumProcs),
+                     "--hostfile", spec.HostFile}
+    if spec.PPN != 0 {
+        // Processes per node is a mapping policy in Open MPI.
+        if len(spec.MapBy) != 0 {
+            return nil, errors.INVALID_INPUT
+        }
+        argv = append(argv, "--map-by", fmt.Sprintf("ppr:%d:node", spec.PPN))
+    } else if len(spec.MapBy) != 0 {
+        argv = append(argv, "--map-by", spec.MapBy)
+    }
+    if len(spec.BindTo) != 0 {
+        argv = append(argv, "--bind-to", spec.BindTo)
+    }
+    if spec.ReportBindings {
+        argv = append(argv, "--report-bindings")
+    }
+    for _, env := range spec.Env {
+        argv = append(argv, "-x", env)
+    }
+    argv = append(argv, spec.ExtraArgs...)
+    argv = append(argv, spec.Binary)
+    return append(argv, spec.Args...), errors.OP_SUCCESS
+}
+
+// ex: [h1:1234] MCW rank 0 bound to socket 0[core 0[hwt 0]]: [B/.][./.]
+func (launcher *openmpi_launcher)IsBindingReport(line string) bool {
+    return strings.Contains(line, "MCW rank") &&
+           (strings.Contains(line, "bound to") ||
+            strings.Contains(line, "not bound"))
+}
+
+//*****************************************************************************
+//*************************  MPICH/Hydra launcher *****************************
+//*****************************************************************************
+type mpich_launcher struct {}
+
+// Hydra mappings, processes are mapped to the topology objects only.
+var mpich_map_by = []string {"hwthread", "core", "socket", "numa"}
+
+func (launcher *mpich_launcher)Name() string {
+    return config.LAUNCHER_MPICH
+}
+
+func (launcher *mpich_launcher)Command() string {
+    return "mpiexec"
+}
+
+func (launcher *mpich_launcher)CheckPolicy(mapBy string,
+                                           bindTo string) error {
+    if len(mapBy) != 0 && !config.IsListMember(mpich_map_by, mapBy) {
+        return errors.INVALID_INPUT
+    }
+    return errors.OP_SUCCESS
+}
+
+func (launcher *mpich_launcher)BuildCmd(spec *OSU_launch_spec) ([]string,
+                                                                 error) {
+    err := launcher.CheckPolicy(spec.MapBy, spec.BindTo)
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    argv := []string{launcher.Command(), "-n", fmt.Sprint(spec.NumProcs),
+                     "-f", spec.HostFile}
+    if spec.PPN != 0 {
+        argv = append(argv, "-ppn", fmt.Sprint(spec.PPN))
+    }
+    if len(spec.MapBy) != 0 {
+        argv = append(argv, "-map-by", spec.MapBy)
+    }
+    if len(spec.BindTo) != 0 {
+        argv = append(argv, "-bind-to", spec.BindTo)
+    }
+    if spec.ReportBindings {
+        argv = append_genv(argv, []string{"HYDRA_TOPO_DEBUG=1"})
+    }
+    argv = append_genv(argv, spec.Env)
+    argv = append(argv, spec.ExtraArgs...)
+    argv = append(argv, spec.Binary)
+    return append(argv, spec.Args...), errors.OP_SUCCESS
+}
+
+// ex: process 0 binding: 10000000
+func (launcher *mpich_launcher)IsBindingReport(line string) bool {
+    line = strings.TrimSpace(line)
+    return strings.HasPrefix(line, "process ") &&
+           strings.Contains(line, " binding: ")
+}
+
+// Environment as '-genv KEY VALUE' arguments of hydra based launchers.
+func append_genv(argv []string, envs []string) []string {
+    for _, env := range envs {
+        keyValue := strings.SplitN(env, "=", 2)
+        if len(keyValue) != 2 {
+            keyValue = append(keyValue, "")
+        }
+        argv = append(argv, "-genv", keyValue[0], keyValue[1])
+    }
+    return argv
+}
+
+//*****************************************************************************
+//****************************  Intel MPI launcher ****************************
+//*****************************************************************************
+type intelmpi_launcher struct {}
+
+// Intel MPI pinning orders of the mappings within a node.
+var intelmpi_pin_order = map[string]string {
+    "slot": "compact",
+    "hwthread": "compact",
+    "core": "compact",
+    "socket": "scatter",
+    "numa": "scatter"}
+
+// Intel MPI pinning domains of the bindings, a hwthread domain has a
+// single logical CPU.
+var intelmpi_pin_domain = map[string]string {
+    "hwthread": "1",
+    "core": "core",
+    "socket": "socket",
+    "numa": "numa"}
+
+// Pinning table of I_MPI_DEBUG, ex:
+//   [0] MPI startup(): Rank    Pid      Node name  Pin cpu
+//   [0] MPI startup(): 0       4242     h1         {0,1}
+var intelmpi_pin_line = regexp.MustCompile(
+                            `MPI startup\(\):\s+(Rank\s+Pid|\d+\s+\d+)\s`)
+
+func (launcher *intelmpi_launcher)Name() string {
+    return config.LAUNCHER_INTELMPI
+}
+
+func (launcher *intelmpi_launcher)Command() string {
+    return "mpirun"
+}
+
+func (launcher *intelmpi_launcher)CheckPolicy(mapBy string,
+                                              bindTo string) error {
+    if _, ok := intelmpi_pin_order[mapBy]; !ok && len(mapBy) != 0 &&
+       mapBy != "node" {
+        return errors.INVALID_INPUT
+    }
+    if _, ok := intelmpi_pin_domain[bindTo]; !ok && len(bindTo) != 0 &&
+       bindTo != "none" {
+        return errors.INVALID_INPUT
+    }
+    return errors.OP_SUCCESS
+}
+
+func (launcher *intelmpi_launcher)BuildCmd(spec *OSU_launch_spec) ([]string,
+                                                                    error) {
+    err := launcher.CheckPolicy(spec.MapBy, spec.BindTo)
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    argv := []string{launcher.Command(), "-n", fmt.Sprint(spec.NumProcs),
+                     "-machinefile", spec.HostFile}
+    if spec.PPN != 0 {
+        argv = append(argv, "-ppn", fmt.Sprint(spec.PPN))
+    }
+    // Ranks are placed round robin across the nodes with -rr, within a
+    // node in the pinning order.
+    switch spec.MapBy {
+    case "":
+    case "node":
+        argv = append(argv, "-rr")
+    default:
+        argv = append_genv(argv, []string{"I_MPI_PIN_ORDER=" +
+                                          intelmpi_pin_order[spec.MapBy]})
+    }
+    if spec.ReportBindings {
+        argv = append_genv(argv, []string{"I_MPI_DEBUG=4"})
+    }
+    // Intel MPI binds through its pinning environment.
+    switch spec.BindTo {
+    case "":
+    case "none":
+        argv = append_genv(argv, []string{"I_MPI_PIN=0"})
+    default:
+        argv = append_genv(argv, []string{"I_MPI_PIN=1",
+                    "I_MPI_PIN_DOMAIN=" + intelmpi_pin_domain[spec.BindTo]})
+    }
+    argv = append_genv(argv, spec.Env)
+    argv = append(argv, spec.ExtraArgs...)
+    argv = append(argv, spec.Binary)
+    return append(argv, spec.Args...), errors.OP_SUCCESS
+}
+
+func (launcher *intelmpi_launcher)IsBindingReport(line string) bool {
+    return intelmpi_pin_line.MatchString(line)
+}
+
+//*****************************************************************************
+//****************************  Slurm srun launcher ***************************
+//*****************************************************************************
+type slurm_launcher struct {}
+
+// srun names of the binding policies.
+var slurm_cpu_bind = map[string]string {
+    "core": "cores",
+    "socket": "sockets",
+    "numa": "ldoms",
+    "hwthread": "threads",
+    "none": "none"}
+
+// srun task distributions of the mappings, across the nodes:within a node.
+var slurm_distribution = map[string]string {
+    "slot": "block",
+    "hwthread": "block:block",
+    "core": "block:block",
+    "socket": "block:cyclic",
+    "numa": "block:cyclic",
+    "node": "cyclic"}
+
+func (launcher *slurm_launcher)Name() string {
+    return config.LAUNCHER_SLURM
+}
+
+func (launcher *slurm_launcher)Command() string {
+    return "srun"
+}
+
+func (launcher *slurm_launcher)CheckPolicy(mapBy string,
+                                           bindTo string) error {
+    if _, ok := slurm_distribution[mapBy]; !ok && len(mapBy) != 0 {
+        return errors.INVALID_INPUT
+    }
+    if _, ok := slurm_cpu_bind[bindTo]; !ok && len(bindTo) != 0 {
+        return errors.INVALID_INPUT
+    }
+    return errors.OP_SUCCESS
+}
+
+func (launcher *slurm_launcher)BuildCmd(spec *OSU_launch_spec) ([]string,
+                                                                 error) {
+    err := launcher.CheckPolicy(spec.MapBy, spec.BindTo)
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    argv := []string{launcher.Command(), "-n", fmt.Sprint(spec.NumProcs)}
+    if len(spec.HostFile) != 0 {
+        // srun doesn't read MPI hostfiles, pass the hosts as nodelist.
+        hosts, err := Read_hostfile(spec.HostFile)
+        if err != errors.OP_SUCCESS {
+            return nil, err
+        }
+        argv = append(argv, "--nodelist=" +
+                      strings.Join(Get_host_names(hosts), ","))
+    }
+    if spec.PPN != 0 {
+        argv = append(argv, fmt.Sprintf("--ntasks-per-node=%d", spec.PPN))
+    }
+    if len(spec.MapBy) != 0 {
+        argv = append(argv, "--distribution=" +
+                      slurm_distribution[spec.MapBy])
+    }
+    cpuBind := make([]string, 0)
+    if spec.ReportBindings {
+        cpuBind = append(cpuBind, "verbose")
+    }
+    if len(spec.BindTo) != 0 {
+        cpuBind = append(cpuBind, slurm_cpu_bind[spec.BindTo])
+    }
+    if len(cpuBind) != 0 {
+        argv = append(argv, "--cpu-bind=" + strings.Join(cpuBind, ","))
+    }
+    // srun cannot take values with ',' in --export, the environment is set
+    // on srun itself and exported to all the ranks.
+    argv = append(argv, "--export=ALL")
+    argv = append(argv, spec.ExtraArgs...)
+    argv = append(argv, spec.Binary)
+    argv = append(argv, spec.Args...)
+    if len(spec.Env) != 0 {
+        argv = append(append([]string{"env"}, spec.Env...), argv...)
+    }
+    return argv, errors.OP_SUCCESS
+}
+
+// ex: cpu-bind=MASK - h1, task  0  0 [4242]: mask 0x1 set
+func (launcher *slurm_launcher)IsBindingReport(line string) bool {
+    return strings.Contains(line, "cpu-bind") &&
+           strings.Contains(line, "mask")
+}
+
+//*****************************************************************************
+func Get_launcher(name string) (Launcher, error) {
+    switch name {
+    case config.LAUNCHER_OPENMPI:
+        return new(openmpi_launcher), errors.OP_SUCCESS
+    case config.LAUNCHER_MPICH:
+        return new(mpich_launcher), errors.OP_SUCCESS
+    case config.LAUNCHER_INTELMPI:
+        return new(intelmpi_launcher), errors.OP_SUCCESS
+    case config.LAUNCHER_SLURM:
+        return new(slurm_launcher), errors.OP_SUCCESS
+    }
+    return nil, errors.INVALID_INPUT
+}
+
+// Find the launcher from the environment. srun is used inside a slurm
+// allocation, otherwise the MPI implementation behind mpirun/mpiexec is
+// identified from its version string.
+func (mpi_cmd_obj *OSU_MPI_cmds)Detect_launcher() (Launcher, error) {
+    logger := logging.GetLoggerInstance()
+    if len(os.Getenv("SLURM_JOB_ID")) != 0 && mpi_cmd_obj.IsCmdExists("srun") {
+        return Get_launcher(config.LAUNCHER_SLURM)
+    }
+    for _, cmd := range []string{"mpirun", "mpiexec"} {
+        if mpi_cmd_obj.IsCmdExists(cmd) == false {
+            continue
+        }
+        res, err := mpi_cmd_obj.executor.Output([]string{cmd, "--version"})
+        if err != errors.OP_SUCCESS {
+            logger.Warning("Failed to get version of %s, err : %s", cmd, err)
+            continue
+        }
+        version := string(res)
+        switch {
+        case strings.Contains(version, "Open MPI") ||
+             strings.Contains(version, "OpenRTE"):
+            return Get_launcher(config.LAUNCHER_OPENMPI)
+        case strings.Contains(version, "Intel(R) MPI"):
+            return Get_launcher(config.LAUNCHER_INTELMPI)
+        case strings.Contains(version, "HYDRA"):
+            return Get_launcher(config.LAUNCHER_MPICH)
+        }
+        logger.Warning("Unknown MPI implementation behind %s", cmd)
+    }
+    logger.Error("Failed to detect the MPI launcher in the system")
+    return nil, errors.CMD_NOT_FOUND
+}
+
+// Commandline as a single string, arguments with spaces are quoted.
+func Format_cmdline(argv []string) string {
+    args := make([]string, len(argv))
+    for idx, arg := range argv {
+        args[idx] = arg
+        if len(arg) == 0 || strings.ContainsAny(arg, " \t\"'$") {
+            args[idx] = fmt.Sprintf("%q", arg)
+        }
+    }
+    return strings.Join(args, " ")
+}
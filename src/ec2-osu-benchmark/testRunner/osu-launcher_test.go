@@ -0,0 +1,287 @@
+package testRunner
+
+import (
+    "context"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/logging"
+)
+
+func TestMain(m *testing.M) {
+    logDir, err := ioutil.TempDir("", "osu-test-log")
+    if err != nil {
+        panic(err)
+    }
+    logger := new(logging.Logging)
+    logger.LogInitSingleton(logging.Error, filepath.Join(logDir, "test.log"))
+    // The runs don't look for the instance metadata service.
+    EC2_METADATA_ENDPOINT = ""
+    code := m.Run()
+    os.RemoveAll(logDir)
+    os.Exit(code)
+}
+
+func testSpec() *OSU_launch_spec {
+    spec := new(OSU_launch_spec)
+    spec.NumProcs = 4
+    spec.HostFile = "hosts"
+    spec.PPN = 2
+    spec.BindTo = "core"
+    spec.Env = []string{"FI_PROVIDER=efa"}
+    spec.ExtraArgs = []string{"--mca", "btl", "^openib"}
+    spec.Binary = "osu_bw"
+    spec.Args = []string{"-m", "1:64"}
+    return spec
+}
+
+func TestBuildCmd(t *testing.T) {
+    tests := []struct {
+        launcher string
+        want []string
+    }{
+        {"openmpi", []string{"mpirun", "--allow-run-as-root", "--np", "4",
+            "--hostfile", "hosts", "--map-by", "ppr:2:node",
+            "--bind-to", "core", "-x", "FI_PROVIDER=efa",
+            "--mca", "btl", "^openib", "osu_bw", "-m", "1:64"}},
+        {"mpich", []string{"mpiexec", "-n", "4", "-f", "hosts", "-ppn", "2",
+            "-bind-to", "core", "-genv", "FI_PROVIDER", "efa",
+            "--mca", "btl", "^openib", "osu_bw", "-m", "1:64"}},
+        {"intel", []string{"mpirun", "-n", "4", "-machinefile", "hosts",
+            "-ppn", "2", "-genv", "I_MPI_PIN", "1",
+            "-genv", "I_MPI_PIN_DOMAIN", "core",
+            "-genv", "FI_PROVIDER", "efa",
+            "--mca", "btl", "^openib", "osu_bw", "-m", "1:64"}},
+    }
+    for _, test := range tests {
+        launcher, err := Get_launcher(test.launcher)
+        if err != errors.OP_SUCCESS {
+            t.Fatalf("Get_launcher(%s) failed, err : %s", test.launcher, err)
+        }
+        argv, err := launcher.BuildCmd(testSpec())
+        if err != errors.OP_SUCCESS {
+            t.Errorf("%s BuildCmd failed, err : %s", test.launcher, err)
+            continue
+        }
+        if !reflect.DeepEqual(argv, test.want) {
+            t.Errorf("%s BuildCmd = %v, want %v", test.launcher, argv,
+                     test.want)
+        }
+    }
+}
+
+func TestBuildCmdPolicies(t *testing.T) {
+    tests := []struct {
+        launcher string
+        mapBy string
+        want []string
+    }{
+        {"openmpi", "ppr:1:socket", []string{"--map-by", "ppr:1:socket",
+            "--bind-to", "core", "--report-bindings"}},
+        {"mpich", "socket", []string{"-map-by", "socket", "-bind-to", "core",
+            "-genv", "HYDRA_TOPO_DEBUG", "1"}},
+        {"intel", "node", []string{"-rr", "-genv", "I_MPI_DEBUG", "4"}},
+        {"intel", "socket", []string{"-genv", "I_MPI_PIN_ORDER", "scatter"}},
+        {"slurm", "socket", []string{"--distribution=block:cyclic",
+            "--cpu-bind=verbose,cores"}},
+    }
+    for _, test := range tests {
+        launcher, _ := Get_launcher(test.launcher)
+        spec := new(OSU_launch_spec)
+        spec.NumProcs = 2
+        spec.BindTo = "core"
+        spec.MapBy = test.mapBy
+        spec.ReportBindings = true
+        spec.Binary = "osu_latency"
+        argv, err := launcher.BuildCmd(spec)
+        if err != errors.OP_SUCCESS {
+            t.Errorf("%s BuildCmd(%s) failed, err : %s", test.launcher,
+                     test.mapBy, err)
+            continue
+        }
+        cmdline := " " + strings.Join(argv, " ") + " "
+        if !strings.Contains(cmdline, " " + strings.Join(test.want, " ") + " ") {
+            t.Errorf("%s BuildCmd(%s) = %v, want %v in it", test.launcher,
+                     test.mapBy, argv, test.want)
+        }
+    }
+    // Intel MPI pins a hwthread binding to a single CPU domain.
+    launcher, _ := Get_launcher("intel")
+    spec := testSpec()
+    spec.BindTo = "hwthread"
+    argv, err := launcher.BuildCmd(spec)
+    if err != errors.OP_SUCCESS || !strings.Contains(strings.Join(argv, " "),
+                                        "-genv I_MPI_PIN_DOMAIN 1 ") {
+        t.Errorf("intel BuildCmd(hwthread) = %v, err : %v", argv, err)
+    }
+    // Open MPI maps the processes per node, it can't take another mapping.
+    launcher, _ = Get_launcher("openmpi")
+    spec = testSpec()
+    spec.MapBy = "socket"
+    if _, err := launcher.BuildCmd(spec); err == errors.OP_SUCCESS {
+        t.Errorf("Open MPI BuildCmd with ppn and mapping is expected to fail")
+    }
+}
+
+func TestCheckPolicy(t *testing.T) {
+    tests := []struct {
+        launcher string
+        mapBy string
+        bindTo string
+        valid bool
+    }{
+        {"openmpi", "ppr:1:socket", "hwthread", true},
+        {"mpich", "socket", "hwthread", true},
+        {"mpich", "ppr:1:socket", "core", false},
+        {"mpich", "node", "", false},
+        {"intel", "node", "hwthread", true},
+        {"intel", "", "none", true},
+        {"intel", "ppr:1:socket", "", false},
+        {"intel", "", "l3cache", false},
+        {"slurm", "numa", "numa", true},
+        {"slurm", "ppr:1:socket", "", false},
+        {"slurm", "", "l3cache", false},
+    }
+    for _, test := range tests {
+        launcher, _ := Get_launcher(test.launcher)
+        err := launcher.CheckPolicy(test.mapBy, test.bindTo)
+        if (err == errors.OP_SUCCESS) != test.valid {
+            t.Errorf("%s CheckPolicy(%q, %q) = %v, want valid %v",
+                     test.launcher, test.mapBy, test.bindTo, err, test.valid)
+        }
+        spec := testSpec()
+        spec.PPN = 0
+        spec.HostFile = ""
+        spec.MapBy = test.mapBy
+        spec.BindTo = test.bindTo
+        if _, err = launcher.BuildCmd(spec);
+           (err == errors.OP_SUCCESS) != test.valid {
+            t.Errorf("%s BuildCmd(%q, %q) = %v, want valid %v",
+                     test.launcher, test.mapBy, test.bindTo, err, test.valid)
+        }
+    }
+}
+
+func TestIsBindingReport(t *testing.T) {
+    tests := []struct {
+        launcher string
+        line string
+        want bool
+    }{
+        {"openmpi", "[h1:4242] MCW rank 0 bound to socket 0[core 0[hwt 0]]: " +
+         "[B/./.]", true},
+        {"openmpi", "[h1:4242] MCW rank 1 is not bound (or bound to all " +
+         "available processors)", true},
+        {"openmpi", "# OSU MPI Latency Test v5.6.2", false},
+        {"mpich", "process 1 binding: 00000010", true},
+        {"intel", "[0] MPI startup(): Rank    Pid      Node name  Pin cpu",
+         true},
+        {"intel", "[0] MPI startup(): 1       4243     h1         {1}", true},
+        {"intel", "[0] MPI startup(): Intel(R) MPI Library, Version 2021.9",
+         false},
+        {"slurm", "cpu-bind=MASK - h1, task  0  0 [4242]: mask 0x1 set",
+         true},
+        {"slurm", "8                       2.15", false},
+    }
+    for _, test := range tests {
+        launcher, _ := Get_launcher(test.launcher)
+        if launcher.IsBindingReport(test.line) != test.want {
+            t.Errorf("%s IsBindingReport(%q) != %t", test.launcher, test.line,
+                     test.want)
+        }
+    }
+}
+
+func TestSlurmBuildCmd(t *testing.T) {
+    spec := testSpec()
+    spec.HostFile = filepath.Join(t.TempDir(), "hosts")
+    err := Write_hostfile([]OSU_host{{Name: "h1", Slots: 2}, {Name: "h2"}},
+                          spec.HostFile, "slurm")
+    if err != errors.OP_SUCCESS {
+        t.Fatal(err)
+    }
+    launcher, _ := Get_launcher("slurm")
+    argv, err := launcher.BuildCmd(spec)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("BuildCmd failed, err : %s", err)
+    }
+    want := []string{"env", "FI_PROVIDER=efa", "srun", "-n", "4",
+        "--nodelist=h1,h2", "--ntasks-per-node=2", "--cpu-bind=cores",
+        "--export=ALL", "--mca", "btl", "^openib", "osu_bw", "-m", "1:64"}
+    if !reflect.DeepEqual(argv, want) {
+        t.Errorf("BuildCmd = %v, want %v", argv, want)
+    }
+}
+
+func TestReadHostfileFormats(t *testing.T) {
+    hostFile := filepath.Join(t.TempDir(), "hosts")
+    content := "# cluster\nh1 slots=4\nh2:8\nh3 # no slots\n\n"
+    if err := ioutil.WriteFile(hostFile, []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+    hosts, err := Read_hostfile(hostFile)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Read_hostfile failed, err : %s", err)
+    }
+    want := []OSU_host{{"h1", 4}, {"h2", 8}, {"h3", 0}}
+    if !reflect.DeepEqual(hosts, want) {
+        t.Errorf("hosts = %v, want %v", hosts, want)
+    }
+}
+
+func TestFormatCmdline(t *testing.T) {
+    got := Format_cmdline([]string{"mpirun", "-x", "A=b c", "osu_bw"})
+    if got != `mpirun -x "A=b c" osu_bw` {
+        t.Errorf("Format_cmdline = %s", got)
+    }
+}
+
+func TestSystemExecutorEmptyArgv(t *testing.T) {
+    executor := new(System_executor)
+    if _, err := executor.Output(nil); err != errors.INVALID_INPUT {
+        t.Errorf("Output of no command returned %v", err)
+    }
+    _, err := executor.Run(context.Background(), []string{}, nil, nil)
+    if err != errors.INVALID_INPUT {
+        t.Errorf("Run of no command returned %v", err)
+    }
+}
+
+func TestRunCommandInterrupt(t *testing.T) {
+    ctx, cancel := context.WithTimeout(context.Background(),
+                                       50 * time.Millisecond)
+    defer cancel()
+    start := time.Now()
+    status, err := run_command(ctx, []string{"sleep", "10"}, nil, nil)
+    if err == errors.OP_SUCCESS || status == nil || !status.TimedOut {
+        t.Fatalf("run_command returned %+v, err : %v", status, err)
+    }
+    if status.Signal != "terminated" || time.Since(start) > 5 * time.Second {
+        t.Errorf("sleep is not terminated, %+v after %s", status,
+                 time.Since(start))
+    }
+}
+
+// Context that reads as past its deadline without ever being done, like a
+// deadline that passes right after the command exits.
+type expiredContext struct {
+    context.Context
+}
+
+func (ctx expiredContext) Err() error {
+    return context.DeadlineExceeded
+}
+
+func TestRunCommandExitBeforeDeadline(t *testing.T) {
+    status, err := run_command(expiredContext{context.Background()},
+                               []string{"true"}, nil, nil)
+    if err != errors.OP_SUCCESS || status == nil || status.TimedOut ||
+       status.Interrupted || status.ExitCode != 0 {
+        t.Errorf("run_command returned %+v, err : %v", status, err)
+    }
+}
@@ -0,0 +1,366 @@
+package testRunner
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "path/filepath"
+    "regexp"
+    "runtime"
+    "strconv"
+    "strings"
+    "time"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+// EC2 instance metadata service(IMDS), empty when the instance metadata is
+// not collected.
+var EC2_METADATA_ENDPOINT = "http://169.254.169.254"
+
+// Deadline of an instance metadata request, the service answers right away
+// on EC2 and is not there elsewhere.
+var EC2_METADATA_TIMEOUT = 2 * time.Second
+
+// Version of the tool that ran the benchmarks.
+type OSU_manifest_tool struct {
+    Version string `json:"version"`
+    GitCommit string `json:"gitcommit"`
+    GoVersion string `json:"goversion"`
+}
+
+// Network interface of the host and its kernel driver.
+type OSU_manifest_nic struct {
+    Name string `json:"name"`
+    Driver string `json:"driver"`
+    MTU uint64 `json:"mtu,omitempty"`
+}
+
+// Hardware and kernel of the host running the launcher.
+type OSU_manifest_host struct {
+    Hostname string `json:"hostname"`
+    CPUModel string `json:"cpumodel"`
+    Sockets int `json:"sockets"`
+    // Physical cores and logical CPUs(hardware threads)
+    Cores int `json:"cores"`
+    CPUs int `json:"cpus"`
+    MemoryBytes uint64 `json:"memorybytes"`
+    Kernel string `json:"kernel"`
+    OS string `json:"os,omitempty"`
+    NICs []*OSU_manifest_nic `json:"nics"`
+}
+
+// MPI implementation behind the launcher.
+type OSU_manifest_mpi struct {
+    Launcher string `json:"launcher"`
+    Command string `json:"command"`
+    // Line of the version output with the version, and the whole output
+    Version string `json:"version"`
+    VersionOutput string `json:"versionoutput,omitempty"`
+}
+
+// OSU installation the benchmarks are run from.
+type OSU_manifest_osu struct {
+    Dir string `json:"dir,omitempty"`
+    Source string `json:"source,omitempty"`
+    // Version in the benchmark binaries, empty when it is not found
+    Version string `json:"version,omitempty"`
+}
+
+// EC2 instance metadata.
+type OSU_manifest_ec2 struct {
+    InstanceID string `json:"instanceid"`
+    InstanceType string `json:"instancetype"`
+    AvailabilityZone string `json:"availabilityzone"`
+    Region string `json:"region,omitempty"`
+    PlacementGroup string `json:"placementgroup,omitempty"`
+    AMI string `json:"ami,omitempty"`
+}
+
+// Environment the benchmarks are run in, written to the result directory
+// at the start of the campaign and embedded in the json report.
+type OSU_manifest struct {
+    RunID string `json:"runid"`
+    Created time.Time `json:"created"`
+    Tool OSU_manifest_tool `json:"tool"`
+    Host OSU_manifest_host `json:"host"`
+    MPI OSU_manifest_mpi `json:"mpi"`
+    OSU OSU_manifest_osu `json:"osu"`
+    // nil when the host is not an EC2 instance
+    EC2 *OSU_manifest_ec2 `json:"ec2,omitempty"`
+    // Configuration of the run with all the defaults resolved
+    Config *config.AppConfig `json:"config"`
+}
+
+// Fields of the "key : value" lines of /proc/cpuinfo and /proc/meminfo.
+func read_proc_keys(fileName string) []([2]string) {
+    entries := make([]([2]string), 0)
+    for _, line := range strings.Split(read_file_string(fileName), "\n") {
+        fields := strings.SplitN(line, ":", 2)
+        if len(fields) == 2 {
+            entries = append(entries, [2]string{strings.TrimSpace(fields[0]),
+                                                strings.TrimSpace(fields[1])})
+        }
+    }
+    return entries
+}
+
+func read_file_string(fileName string) string {
+    content, err := ioutil.ReadFile(fileName)
+    if err != nil {
+        return ""
+    }
+    return string(content)
+}
+
+func read_first_line(fileName string) string {
+    return strings.TrimSpace(strings.SplitN(read_file_string(fileName), "\n",
+                                            2)[0])
+}
+
+// CPU model, sockets, cores and logical CPUs from /proc/cpuinfo. Arm
+// instances don't have the model name and the topology in cpuinfo, every
+// CPU is counted as a core then.
+func (host *OSU_manifest_host)read_cpuinfo(root string) {
+    sockets := make(map[string]bool)
+    cores := make(map[string]bool)
+    physicalID := ""
+    for _, entry := range read_proc_keys(filepath.Join(root, "proc",
+                                                       "cpuinfo")) {
+        switch entry[0] {
+        case "processor":
+            host.CPUs++
+        case "model name":
+            host.CPUModel = entry[1]
+        case "physical id":
+            physicalID = entry[1]
+            sockets[physicalID] = true
+        case "core id":
+            cores[physicalID + "/" + entry[1]] = true
+        }
+    }
+    host.Sockets = len(sockets)
+    host.Cores = len(cores)
+    if host.Cores == 0 {
+        host.Cores = host.CPUs
+    }
+}
+
+// Network interfaces backed by a device, the virtual interfaces(lo,
+// bridges) don't have a driver.
+func (host *OSU_manifest_host)read_nics(root string) {
+    host.NICs = make([]*OSU_manifest_nic, 0)
+    netDir := filepath.Join(root, "sys", "class", "net")
+    names, _ := filepath.Glob(filepath.Join(netDir, "*"))
+    for _, dir := range names {
+        driver, err := os.Readlink(filepath.Join(dir, "device", "driver"))
+        if err != nil {
+            continue
+        }
+        nic := new(OSU_manifest_nic)
+        nic.Name = filepath.Base(dir)
+        nic.Driver = filepath.Base(driver)
+        nic.MTU, _ = strconv.ParseUint(read_first_line(
+                                       filepath.Join(dir, "mtu")), 10, 64)
+        host.NICs = append(host.NICs, nic)
+    }
+}
+
+// Hardware and kernel of the host, the files under the root that can't be
+// read leave their fields empty.
+func read_manifest_host(root string) OSU_manifest_host {
+    var host OSU_manifest_host
+    host.Hostname, _ = os.Hostname()
+    host.read_cpuinfo(root)
+    for _, entry := range read_proc_keys(filepath.Join(root, "proc",
+                                                       "meminfo")) {
+        if entry[0] == "MemTotal" {
+            kb, _ := strconv.ParseUint(strings.TrimSuffix(entry[1], " kB"),
+                                       10, 64)
+            host.MemoryBytes = kb * 1024
+        }
+    }
+    host.Kernel = read_first_line(filepath.Join(root, "proc", "sys",
+                                                "kernel", "osrelease"))
+    for _, line := range strings.Split(read_file_string(filepath.Join(root,
+                                       "etc", "os-release")), "\n") {
+        if strings.HasPrefix(line, "PRETTY_NAME=") {
+            host.OS = strings.Trim(strings.TrimPrefix(line, "PRETTY_NAME="),
+                                   "\"")
+        }
+    }
+    host.read_nics(root)
+    return host
+}
+
+var versionLineRegex = regexp.MustCompile(`[0-9]+\.[0-9]+`)
+
+// Version of the MPI implementation from '<launcher> --version'.
+func (mpi_cmd_obj *OSU_MPI_cmds)get_manifest_mpi() OSU_manifest_mpi {
+    logger := logging.GetLoggerInstance()
+    var mpi OSU_manifest_mpi
+    mpi.Launcher = mpi_cmd_obj.launcher.Name()
+    mpi.Command = mpi_cmd_obj.launcher.Command()
+    res, err := mpi_cmd_obj.executor.Output([]string{mpi.Command,
+                                                     "--version"})
+    if err != errors.OP_SUCCESS {
+        logger.Warning("Failed to get version of %s, err : %s", mpi.Command,
+                       err)
+        return mpi
+    }
+    mpi.VersionOutput = strings.TrimSpace(string(res))
+    for _, line := range strings.Split(mpi.VersionOutput, "\n") {
+        if versionLineRegex.MatchString(line) {
+            mpi.Version = strings.TrimSpace(line)
+            break
+        }
+    }
+    return mpi
+}
+
+var osuVersionRegex = regexp.MustCompile(
+                        `OSU MPI[ A-Za-z0-9_/()-]* (v[0-9]+(\.[0-9]+)*)`)
+
+// OSU version in the title string of a benchmark binary, the same string
+// the preflight probe looks for on every host.
+func get_osu_binary_version(path string) string {
+    content, err := ioutil.ReadFile(path)
+    if err != nil {
+        return ""
+    }
+    match := osuVersionRegex.FindSubmatch(content)
+    if match == nil {
+        return ""
+    }
+    return string(match[1])
+}
+
+func (mpi_cmd_obj *OSU_MPI_cmds)get_manifest_osu() OSU_manifest_osu {
+    var osu OSU_manifest_osu
+    if install := mpi_cmd_obj.registry.Get_install(); install != nil {
+        osu.Dir = install.Dir
+        osu.Source = install.Source
+    }
+    for _, bench := range mpi_cmd_obj.osu_cmds {
+        if osu.Version = get_osu_binary_version(bench.Path);
+           len(osu.Version) != 0 {
+            break
+        }
+    }
+    return osu
+}
+
+// Get an instance metadata path with the IMDSv2 token, without the token
+// when the instance allows IMDSv1 only.
+func get_ec2_metadata(client *http.Client, token string,
+                      path string) (string, error) {
+    request, err := http.NewRequest("GET", EC2_METADATA_ENDPOINT +
+                                    "/latest/meta-data/" + path, nil)
+    if err != nil {
+        return "", err
+    }
+    if len(token) != 0 {
+        request.Header.Set("X-aws-ec2-metadata-token", token)
+    }
+    response, err := client.Do(request)
+    if err != nil {
+        return "", err
+    }
+    defer response.Body.Close()
+    body, err := ioutil.ReadAll(response.Body)
+    if err != nil {
+        return "", err
+    }
+    if response.StatusCode != http.StatusOK {
+        return "", errors.DATA_NOT_FOUND
+    }
+    return strings.TrimSpace(string(body)), errors.OP_SUCCESS
+}
+
+// Instance metadata of the EC2 instance, nil when the metadata service is
+// not reachable.
+func get_manifest_ec2() *OSU_manifest_ec2 {
+    logger := logging.GetLoggerInstance()
+    if len(EC2_METADATA_ENDPOINT) == 0 {
+        return nil
+    }
+    client := &http.Client{Timeout: EC2_METADATA_TIMEOUT}
+    token := ""
+    request, err := http.NewRequest("PUT", EC2_METADATA_ENDPOINT +
+                                    "/latest/api/token", nil)
+    if err == nil {
+        request.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+        response, err := client.Do(request)
+        if err == nil {
+            body, _ := ioutil.ReadAll(response.Body)
+            response.Body.Close()
+            if response.StatusCode == http.StatusOK {
+                token = strings.TrimSpace(string(body))
+            }
+        }
+    }
+    ec2 := new(OSU_manifest_ec2)
+    ec2.InstanceID, err = get_ec2_metadata(client, token, "instance-id")
+    if err != errors.OP_SUCCESS {
+        logger.Info("Not an EC2 instance, instance metadata is not " +
+                    "reachable, err : %s", err)
+        return nil
+    }
+    ec2.InstanceType, _ = get_ec2_metadata(client, token, "instance-type")
+    ec2.AvailabilityZone, _ = get_ec2_metadata(client, token,
+                                               "placement/availability-zone")
+    ec2.Region, _ = get_ec2_metadata(client, token, "placement/region")
+    // Missing when the instance is not in a placement group
+    ec2.PlacementGroup, _ = get_ec2_metadata(client, token,
+                                             "placement/group-name")
+    ec2.AMI, _ = get_ec2_metadata(client, token, "ami-id")
+    return ec2
+}
+
+// Collect the manifest of the run.
+func (mpi_cmd_obj *OSU_MPI_cmds)Get_manifest() *OSU_manifest {
+    manifest := new(OSU_manifest)
+    manifest.RunID = mpi_cmd_obj.run_info.RunID
+    manifest.Created = time.Now()
+    manifest.Tool.Version = config.ToolVersion
+    manifest.Tool.GitCommit = config.GitCommit
+    manifest.Tool.GoVersion = runtime.Version()
+    manifest.Host = read_manifest_host(mpi_cmd_obj.host_root)
+    manifest.MPI = mpi_cmd_obj.get_manifest_mpi()
+    manifest.OSU = mpi_cmd_obj.get_manifest_osu()
+    manifest.EC2 = get_manifest_ec2()
+    manifest.Config = mpi_cmd_obj.configObj
+    return manifest
+}
+
+func (manifest *OSU_manifest)Write(fileName string) error {
+    logger := logging.GetLoggerInstance()
+    jsonBytes, err := json.MarshalIndent(manifest, "", "  ")
+    if err != nil {
+        logger.Error("Failed to marshal the manifest, err : %s", err)
+        return err
+    }
+    err = ioutil.WriteFile(fileName, jsonBytes, 0644)
+    if err != nil {
+        logger.Error("Failed to write manifest %s, err : %s", fileName, err)
+        return err
+    }
+    return errors.OP_SUCCESS
+}
+
+func Read_manifest(fileName string) (*OSU_manifest, error) {
+    logger := logging.GetLoggerInstance()
+    jsonBytes, err := ioutil.ReadFile(fileName)
+    if err != nil {
+        logger.Error("Failed to read manifest %s, err : %s", fileName, err)
+        return nil, err
+    }
+    manifest := new(OSU_manifest)
+    if err = json.Unmarshal(jsonBytes, manifest); err != nil {
+        logger.Error("Invalid manifest %s, err : %s", fileName, err)
+        return nil, err
+    }
+    return manifest, errors.OP_SUCCESS
+}
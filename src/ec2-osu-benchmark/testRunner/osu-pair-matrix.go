@@ -0,0 +1,102 @@
+package testRunner
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+)
+
+// Benchmarks run between every pair of hosts in the pair matrix mode.
+var PAIR_MATRIX_BENCHMARKS = []string{"osu_latency", "osu_bw"}
+
+// Directory in the result directory with the generated two host hostfiles.
+const PAIR_HOSTFILE_DIR = "hosts"
+
+// Two hosts of the hostfile, one rank runs on each of them.
+type OSU_host_pair struct {
+    // Name of the pair in the job name, pair<i>-<j> with the positions of
+    // the hosts in the hostfile
+    Name string
+    Hosts []string
+    HostFile string
+}
+
+// Find every unordered pair of hosts in the hostfile. The launcher runs the
+// OSU pt2pt benchmarks on exactly these two hosts, one rank per host, with
+// the hostfiles from write_pair_hostfiles.
+func (mpi_cmd_obj *OSU_MPI_cmds)init_pair_matrix() error {
+    logger := logging.GetLoggerInstance()
+    hosts, err := Read_hostfile(mpi_cmd_obj.configObj.HostFile)
+    if err != errors.OP_SUCCESS {
+        return err
+    }
+    if len(hosts) < 2 {
+        logger.Error("Host pair matrix needs at least 2 hosts in %s",
+                     mpi_cmd_obj.configObj.HostFile)
+        return errors.INVALID_INPUT
+    }
+    hostfileDir := filepath.Join(mpi_cmd_obj.result_dir, PAIR_HOSTFILE_DIR)
+    mpi_cmd_obj.host_pairs = make([]*OSU_host_pair, 0)
+    for i := 0; i < len(hosts); i++ {
+        for j := i + 1; j < len(hosts); j++ {
+            pair := new(OSU_host_pair)
+            pair.Name = fmt.Sprintf("pair%d-%d", i, j)
+            pair.Hosts = []string{hosts[i].Name, hosts[j].Name}
+            pair.HostFile = filepath.Join(hostfileDir,
+                                          pair.Name + ".hostfile")
+            mpi_cmd_obj.host_pairs = append(mpi_cmd_obj.host_pairs, pair)
+        }
+    }
+    logger.Info("Running host pair matrix of %d hosts, %d pairs",
+                len(hosts), len(mpi_cmd_obj.host_pairs))
+    return errors.OP_SUCCESS
+}
+
+// Write the two host hostfiles of all the pairs.
+func (mpi_cmd_obj *OSU_MPI_cmds)write_pair_hostfiles() error {
+    logger := logging.GetLoggerInstance()
+    hostfileDir := filepath.Join(mpi_cmd_obj.result_dir, PAIR_HOSTFILE_DIR)
+    err := os.MkdirAll(hostfileDir, os.ModePerm)
+    if err != nil {
+        logger.Error("Failed to create hostfile directory %s, err : %s",
+                     hostfileDir, err)
+        return err
+    }
+    for _, pair := range mpi_cmd_obj.host_pairs {
+        pairHosts := []OSU_host{{Name: pair.Hosts[0], Slots: 1},
+                                {Name: pair.Hosts[1], Slots: 1}}
+        err = Write_hostfile(pairHosts, pair.HostFile,
+                             mpi_cmd_obj.launcher.Name())
+        if err != errors.OP_SUCCESS {
+            return err
+        }
+    }
+    return errors.OP_SUCCESS
+}
+
+// Jobs of the pair matrix, every repetition runs the benchmarks on all
+// the pairs.
+func (mpi_cmd_obj *OSU_MPI_cmds)build_pair_jobs(
+                                    repetitions uint) []*OSU_job {
+    jobs := make([]*OSU_job, 0)
+    for rep := uint(1); rep <= repetitions; rep++ {
+        for pairIdx, pair := range mpi_cmd_obj.host_pairs {
+            for _, bench := range mpi_cmd_obj.osu_cmds {
+                job := new(OSU_job)
+                job.Bench = bench
+                job.Repetition = rep
+                job.Repetitions = repetitions
+                job.NP = 2
+                job.PPN = 1
+                job.MapBy = mpi_cmd_obj.configObj.MapBy
+                job.BindTo = mpi_cmd_obj.configObj.BindTo
+                job.Pair = pair
+                job.Primary = rep == 1 && pairIdx == 0
+                jobs = append(jobs, job)
+            }
+        }
+    }
+    return jobs
+}
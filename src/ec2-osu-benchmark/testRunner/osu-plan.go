@@ -0,0 +1,183 @@
+package testRunner
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "reflect"
+    "strings"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+// A job of the plan, everything the job is run with.
+type OSU_plan_job struct {
+    Name string `json:"name"`
+    Benchmark string `json:"benchmark"`
+    Category string `json:"category"`
+    // Benchmark binary and its arguments
+    Binary string `json:"binary"`
+    Args []string `json:"args"`
+    Repetition uint `json:"repetition"`
+    NP uint `json:"np"`
+    PPN uint `json:"ppn"`
+    MapBy string `json:"mapby,omitempty"`
+    BindTo string `json:"bindto,omitempty"`
+    Variant string `json:"variant,omitempty"`
+    Hosts []string `json:"hosts,omitempty"`
+    LauncherArgs []string `json:"launcherargs"`
+    Env []string `json:"env"`
+    Options config.OSUOptions `json:"options"`
+    Timeout string `json:"timeout"`
+    // Command line, the result directory in it is RESULT_DIR_PLACEHOLDER
+    Command string `json:"command"`
+}
+
+// Concrete job list of a campaign, saved next to the results. -plan runs
+// the arguments of the plan and checks that they expand to the same jobs.
+type OSU_plan struct {
+    // Campaign file the plan is expanded from, empty for the commandline
+    Campaign string `json:"campaign,omitempty"`
+    // Commandline of the campaign
+    Args []string `json:"args"`
+    Launcher string `json:"launcher"`
+    // Benchmarks installation, empty for the default paths
+    OSUInstall string `json:"osuinstall,omitempty"`
+    Jobs []*OSU_plan_job `json:"jobs"`
+}
+
+// Result directory in the commands of the plan, it differs in every run.
+const RESULT_DIR_PLACEHOLDER = "${RESULT_DIR}/"
+
+// Expand the jobs to the plan.
+func (mpi_cmd_obj *OSU_MPI_cmds)expand_plan(jobs []*OSU_job) (*OSU_plan,
+                                                              error) {
+    plan := new(OSU_plan)
+    plan.Campaign = mpi_cmd_obj.configObj.CampaignFile
+    plan.Args = mpi_cmd_obj.configObj.Args
+    plan.Launcher = mpi_cmd_obj.launcher.Name()
+    if install := mpi_cmd_obj.registry.Get_install(); install != nil {
+        plan.OSUInstall = install.Dir
+    }
+    plan.Jobs = make([]*OSU_plan_job, 0, len(jobs))
+    for _, job := range jobs {
+        _, record, err := mpi_cmd_obj.prepare_job(job)
+        if err != errors.OP_SUCCESS {
+            return nil, err
+        }
+        planJob := new(OSU_plan_job)
+        planJob.Name = job.Name()
+        planJob.Benchmark = job.Bench.Name
+        planJob.Category = job.Bench.Category
+        planJob.Binary = job.Bench.Path
+        planJob.Args = mpi_cmd_obj.get_benchmark_args(job.Bench)
+        planJob.Repetition = job.Repetition
+        planJob.NP = job.NP
+        planJob.PPN = job.PPN
+        planJob.MapBy = job.MapBy
+        planJob.BindTo = job.BindTo
+        planJob.Variant = job.Variant
+        planJob.Hosts = record.Hosts
+        planJob.LauncherArgs = record.LauncherArgs
+        planJob.Env = record.Env
+        planJob.Options = record.Options
+        planJob.Timeout = record.Timeout
+        planJob.Command = strings.Replace(record.Command,
+                                          mpi_cmd_obj.result_dir,
+                                          RESULT_DIR_PLACEHOLDER, -1)
+        plan.Jobs = append(plan.Jobs, planJob)
+    }
+    return plan, errors.OP_SUCCESS
+}
+
+func Read_plan(fileName string) (*OSU_plan, error) {
+    logger := logging.GetLoggerInstance()
+    jsonBytes, err := ioutil.ReadFile(fileName)
+    if err != nil {
+        logger.Error("Failed to read plan %s, err : %s", fileName, err)
+        return nil, err
+    }
+    plan := new(OSU_plan)
+    if err = json.Unmarshal(jsonBytes, plan); err != nil {
+        logger.Error("Invalid plan %s, err : %s", fileName, err)
+        return nil, err
+    }
+    return plan, errors.OP_SUCCESS
+}
+
+func (plan *OSU_plan)Write(fileName string) error {
+    logger := logging.GetLoggerInstance()
+    jsonBytes, err := json.MarshalIndent(plan, "", "  ")
+    if err != nil {
+        logger.Error("Failed to marshal the plan, err : %s", err)
+        return err
+    }
+    err = ioutil.WriteFile(fileName, jsonBytes, 0644)
+    if err != nil {
+        logger.Error("Failed to write plan %s, err : %s", fileName, err)
+        return err
+    }
+    return errors.OP_SUCCESS
+}
+
+// Differences of the jobs of the plan from the expected plan, empty when
+// the plan runs the same jobs the same way.
+func (plan *OSU_plan)Compare(expected *OSU_plan) []string {
+    diffs := make([]string, 0)
+    if plan.Launcher != expected.Launcher {
+        diffs = append(diffs, fmt.Sprintf("launcher is %s, expected %s",
+                                          plan.Launcher, expected.Launcher))
+    }
+    jobs := make(map[string]*OSU_plan_job)
+    for _, planJob := range plan.Jobs {
+        jobs[planJob.Name] = planJob
+    }
+    for _, expectedJob := range expected.Jobs {
+        planJob, ok := jobs[expectedJob.Name]
+        if !ok {
+            diffs = append(diffs, fmt.Sprintf("%s is missing",
+                                              expectedJob.Name))
+            continue
+        }
+        delete(jobs, expectedJob.Name)
+        if !reflect.DeepEqual(planJob, expectedJob) {
+            diffs = append(diffs, fmt.Sprintf("%s is run as '%s', " +
+                                              "expected '%s'", planJob.Name,
+                                              planJob.Command,
+                                              expectedJob.Command))
+        }
+    }
+    for _, planJob := range plan.Jobs {
+        if _, ok := jobs[planJob.Name]; ok {
+            diffs = append(diffs, fmt.Sprintf("%s is not in the plan",
+                                              planJob.Name))
+        }
+    }
+    return diffs
+}
+
+// Check that the run expands to the jobs of the plan it reproduces(-plan),
+// the differences are logged.
+func (mpi_cmd_obj *OSU_MPI_cmds)check_plan() error {
+    logger := logging.GetLoggerInstance()
+    planFile := mpi_cmd_obj.configObj.PlanFile
+    expected, err := Read_plan(planFile)
+    if err != errors.OP_SUCCESS {
+        return err
+    }
+    plan, err := mpi_cmd_obj.expand_plan(mpi_cmd_obj.build_jobs())
+    if err != errors.OP_SUCCESS {
+        return err
+    }
+    diffs := plan.Compare(expected)
+    if len(diffs) == 0 {
+        return errors.OP_SUCCESS
+    }
+    for _, diff := range diffs {
+        logger.Error("Plan %s : %s", planFile, diff)
+    }
+    fmt.Printf("The run does not match the plan %s, %d differences, see %s\n",
+               planFile, len(diffs), mpi_cmd_obj.configObj.LogFile)
+    return errors.INVALID_INPUT
+}
@@ -0,0 +1,498 @@
+package testRunner
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "text/tabwriter"
+    "syscall"
+    "time"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+// Preflight checks run on every host of the hostfile through the launcher
+// before the campaign, one single rank job per host:
+//  reachable :- the launcher can start a process on the host
+//  binaries  :- the OSU binaries of the campaign exist on the host
+//  mpi       :- MPI version on the host is the same as on the other hosts
+//  osu       :- OSU version on the host is the same as on the other hosts
+//  slots     :- the host has the slots the campaign needs
+//  clock     :- the clock of the host is in sync with the local clock
+
+// Check results.
+const (
+    PREFLIGHT_PASS = "pass"
+    PREFLIGHT_FAIL = "fail"
+    // Check is not done as the host is not reachable
+    PREFLIGHT_SKIP = "skip"
+)
+
+// Checks in the order of the preflight table.
+var PREFLIGHT_CHECKS = []string{"reachable", "binaries", "mpi", "osu",
+                                "slots", "clock"}
+
+// Deadline of the probe job of a host.
+var PREFLIGHT_TIMEOUT = 60 * time.Second
+
+// Hosts probed at the same time, unreachable hosts hold up their probe
+// till PREFLIGHT_TIMEOUT.
+var PREFLIGHT_PARALLEL = 16
+
+// Allowed difference between the clock of a host and the local clock,
+// beyond the time the launcher takes to start the probe.
+var PREFLIGHT_MAX_CLOCK_SKEW = time.Second
+
+// Preflight report in the result directory.
+const PREFLIGHT_REPORT_FILE = "preflight.json"
+
+// Probe run on every host. The launcher command is the first argument and
+// the OSU binaries are the rest, it prints a 'key value' line per fact.
+const PREFLIGHT_PROBE = `launcher=$1; shift
+echo "hostname $(hostname)"
+echo "time $(date +%s.%N)"
+echo "cpus $(nproc 2>/dev/null || getconf _NPROCESSORS_ONLN)"
+echo "mpi $($launcher --version 2>&1 | grep -v '^ *$' | head -n 1)"
+for binary in "$@"; do
+    path=$(command -v "$binary")
+    if [ -n "$path" ] && [ -x "$path" ]; then
+        version=$(grep -aoE -m 1 'OSU MPI[ A-Za-z0-9_/()-]* v[0-9]+(\.[0-9]+)*' "$path" | head -n 1 | sed 's/.* //')
+        echo "binary $binary ok ${version:-unknown}"
+    else
+        echo "binary $binary missing"
+    fi
+done`
+
+// Name of the probe in the probe commandline, it is $0 of the script.
+const PREFLIGHT_PROBE_NAME = "osu-preflight"
+
+// Result of a check on a host. Value is what is found on the host and
+// Detail is why the check failed.
+type OSU_preflight_check struct {
+    Name string `json:"name"`
+    Status string `json:"status"`
+    Value string `json:"value"`
+    Detail string `json:"detail,omitempty"`
+}
+
+// Preflight checks of a host.
+type OSU_host_preflight struct {
+    // Host as in the hostfile and as it calls itself
+    Host string `json:"host"`
+    Hostname string `json:"hostname"`
+    Checks []*OSU_preflight_check `json:"checks"`
+    Passed bool `json:"passed"`
+}
+
+type OSU_preflight struct {
+    Launcher string `json:"launcher"`
+    Hosts []*OSU_host_preflight `json:"hosts"`
+    Passed bool `json:"passed"`
+}
+
+// Facts printed by the probe.
+type preflight_probe struct {
+    hostname string
+    time float64
+    cpus uint
+    mpi string
+    // binary -> OSU version, missing binaries are not present
+    binaries map[string]string
+    missing []string
+}
+
+// Parse the output of PREFLIGHT_PROBE.
+func parse_preflight_probe(output string) *preflight_probe {
+    probe := new(preflight_probe)
+    probe.binaries = make(map[string]string)
+    probe.missing = make([]string, 0)
+    scanner := bufio.NewScanner(strings.NewReader(output))
+    for scanner.Scan() {
+        keyValue := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 2)
+        if len(keyValue) != 2 {
+            continue
+        }
+        value := strings.TrimSpace(keyValue[1])
+        switch keyValue[0] {
+        case "hostname":
+            probe.hostname = value
+        case "time":
+            var err error
+            probe.time, err = strconv.ParseFloat(value, 64)
+            if err != nil {
+                // date without %N support prints '<seconds>.N'
+                seconds, _ := strconv.ParseInt(
+                                strings.SplitN(value, ".", 2)[0], 10, 64)
+                probe.time = float64(seconds)
+            }
+        case "cpus":
+            cpus, _ := strconv.ParseUint(value, 10, 32)
+            probe.cpus = uint(cpus)
+        case "mpi":
+            probe.mpi = value
+        case "binary":
+            fields := strings.Fields(value)
+            if len(fields) < 2 {
+                continue
+            }
+            if fields[1] != "ok" {
+                probe.missing = append(probe.missing, fields[0])
+                continue
+            }
+            probe.binaries[fields[0]] = "unknown"
+            if len(fields) > 2 {
+                probe.binaries[fields[0]] = fields[2]
+            }
+        }
+    }
+    return probe
+}
+
+func (hostPreflight *OSU_host_preflight)add_check(name string, status string,
+                                                 value string,
+                                                 detail string) {
+    check := &OSU_preflight_check{name, status, value, detail}
+    hostPreflight.Checks = append(hostPreflight.Checks, check)
+}
+
+// Check of the host by name, nil when it is not done.
+func (hostPreflight *OSU_host_preflight)Get_check(
+                                    name string) *OSU_preflight_check {
+    for _, check := range hostPreflight.Checks {
+        if check.Name == name {
+            return check
+        }
+    }
+    return nil
+}
+
+// OSU binaries of the campaign, each binary once.
+func (mpi_cmd_obj *OSU_MPI_cmds)get_preflight_binaries() []string {
+    binaries := make([]string, 0)
+    seen := make(map[string]bool)
+    for _, bench := range mpi_cmd_obj.osu_cmds {
+        if !seen[bench.Path] {
+            seen[bench.Path] = true
+            binaries = append(binaries, bench.Path)
+        }
+    }
+    return binaries
+}
+
+// Slots the campaign needs on every host, the largest processes per node
+// or the processes spread evenly on the hosts.
+func (mpi_cmd_obj *OSU_MPI_cmds)get_preflight_slots(numHosts int) uint {
+    configObj := mpi_cmd_obj.configObj
+    if configObj.PairMatrix {
+        return 1
+    }
+    required := uint(0)
+    for _, ppn := range configObj.GetPPNList() {
+        if ppn > required {
+            required = ppn
+        }
+    }
+    if required != 0 {
+        return required
+    }
+    for _, np := range configObj.GetNPList() {
+        perHost := (np + uint(numHosts) - 1) / uint(numHosts)
+        if perHost > required {
+            required = perHost
+        }
+    }
+    return required
+}
+
+// Hostfile of the probe of the host at the position in the hostfile.
+func (mpi_cmd_obj *OSU_MPI_cmds)get_preflight_hostfile(hostIdx int) string {
+    return filepath.Join(mpi_cmd_obj.result_dir, PAIR_HOSTFILE_DIR,
+                         fmt.Sprintf("preflight%d.hostfile", hostIdx))
+}
+
+// Run the probe on a host, the probe runs as a single rank job on a
+// hostfile with only the host.
+func (mpi_cmd_obj *OSU_MPI_cmds)preflight_host(
+                                    ctx context.Context, host OSU_host,
+                                    hostIdx int, binaries []string,
+                                    requiredSlots uint) *OSU_host_preflight {
+    logger := logging.GetLoggerInstance()
+    hostPreflight := new(OSU_host_preflight)
+    hostPreflight.Host = host.Name
+    hostPreflight.Checks = make([]*OSU_preflight_check, 0)
+    skip := func(detail string) *OSU_host_preflight {
+        hostPreflight.add_check("reachable", PREFLIGHT_FAIL, "no", detail)
+        for _, name := range PREFLIGHT_CHECKS[1:] {
+            hostPreflight.add_check(name, PREFLIGHT_SKIP, "", "")
+        }
+        return hostPreflight
+    }
+
+    spec := new(OSU_launch_spec)
+    spec.NumProcs = 1
+    spec.HostFile = mpi_cmd_obj.get_preflight_hostfile(hostIdx)
+    spec.Env = mpi_cmd_obj.configObj.GetEnv("")
+    spec.ExtraArgs = mpi_cmd_obj.configObj.GetLauncherArgs("")
+    spec.Binary = "/bin/sh"
+    spec.Args = append([]string{"-c", PREFLIGHT_PROBE, PREFLIGHT_PROBE_NAME,
+                                mpi_cmd_obj.launcher.Command()}, binaries...)
+    err := Write_hostfile([]OSU_host{{Name: host.Name, Slots: 1}},
+                          spec.HostFile, mpi_cmd_obj.launcher.Name())
+    if err != errors.OP_SUCCESS {
+        return skip(fmt.Sprintf("failed to write hostfile, err : %s", err))
+    }
+    argv, err := mpi_cmd_obj.launcher.BuildCmd(spec)
+    if err != errors.OP_SUCCESS {
+        return skip(fmt.Sprintf("failed to build the probe command, err : %s",
+                                err))
+    }
+    var stdout, stderr bytes.Buffer
+    probeCtx, cancel := context.WithTimeout(ctx, PREFLIGHT_TIMEOUT)
+    defer cancel()
+    logger.Info("Running preflight probe on %s", host.Name)
+    before := time.Now()
+    status, err := mpi_cmd_obj.executor.Run(probeCtx, argv, &stdout, &stderr)
+    after := time.Now()
+    if status != nil && status.TimedOut {
+        return skip(fmt.Sprintf("probe timed out after %s",
+                                PREFLIGHT_TIMEOUT))
+    }
+    if err != errors.OP_SUCCESS {
+        detail := fmt.Sprintf("probe failed, err : %s", err)
+        lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+        if last := lines[len(lines) - 1]; len(last) != 0 {
+            detail += ", " + last
+        }
+        logger.Error("Preflight probe failed on %s, err : %s, stderr : %s",
+                     host.Name, err, stderr.String())
+        return skip(detail)
+    }
+    probe := parse_preflight_probe(stdout.String())
+    if len(probe.hostname) == 0 {
+        return skip("no output from the probe")
+    }
+    hostPreflight.Hostname = probe.hostname
+    hostPreflight.add_check("reachable", PREFLIGHT_PASS, "yes", "")
+
+    value := fmt.Sprintf("%d/%d", len(probe.binaries), len(binaries))
+    if len(probe.missing) != 0 {
+        hostPreflight.add_check("binaries", PREFLIGHT_FAIL, value,
+                                "missing " + strings.Join(probe.missing, ", "))
+    } else {
+        hostPreflight.add_check("binaries", PREFLIGHT_PASS, value, "")
+    }
+
+    // Versions are compared across the hosts once all are probed.
+    hostPreflight.add_check("mpi", PREFLIGHT_PASS, probe.mpi, "")
+    versions := make([]string, 0)
+    for _, binary := range binaries {
+        version, ok := probe.binaries[binary]
+        if ok && !config.IsListMember(versions, version) {
+            versions = append(versions, version)
+        }
+    }
+    if len(versions) > 1 {
+        hostPreflight.add_check("osu", PREFLIGHT_FAIL,
+                                strings.Join(versions, ","),
+                                "binaries of different OSU versions")
+    } else {
+        hostPreflight.add_check("osu", PREFLIGHT_PASS,
+                                strings.Join(versions, ","), "")
+    }
+
+    available := probe.cpus
+    if host.Slots != 0 && host.Slots < available {
+        available = host.Slots
+    }
+    value = fmt.Sprintf("%d/%d", requiredSlots, available)
+    if requiredSlots > available {
+        hostPreflight.add_check("slots", PREFLIGHT_FAIL, value,
+            fmt.Sprintf("needs %d slots, %d available", requiredSlots,
+                        available))
+    } else {
+        hostPreflight.add_check("slots", PREFLIGHT_PASS, value, "")
+    }
+
+    // The host clock was read somewhere between starting and finishing the
+    // probe, it is out of sync only when it is outside of that window.
+    hostTime := probe.time
+    lower := float64(before.UnixNano()) / 1e9 -
+             PREFLIGHT_MAX_CLOCK_SKEW.Seconds()
+    upper := float64(after.UnixNano()) / 1e9 +
+             PREFLIGHT_MAX_CLOCK_SKEW.Seconds()
+    midpoint := float64(before.UnixNano() + after.UnixNano()) / 2e9
+    value = fmt.Sprintf("%+.3fs", hostTime - midpoint)
+    if hostTime < lower || hostTime > upper {
+        hostPreflight.add_check("clock", PREFLIGHT_FAIL, value,
+            fmt.Sprintf("clock is off by more than %s",
+                        PREFLIGHT_MAX_CLOCK_SKEW))
+    } else {
+        hostPreflight.add_check("clock", PREFLIGHT_PASS, value, "")
+    }
+    return hostPreflight
+}
+
+// Fail the check on the hosts that don't have the value most of the hosts
+// have, ties go to the value of the first host.
+func compare_preflight_check(hosts []*OSU_host_preflight, name string) {
+    counts := make(map[string]int)
+    common := ""
+    for _, hostPreflight := range hosts {
+        check := hostPreflight.Get_check(name)
+        if check == nil || check.Status == PREFLIGHT_SKIP {
+            continue
+        }
+        counts[check.Value]++
+        if counts[check.Value] > counts[common] {
+            common = check.Value
+        }
+    }
+    for _, hostPreflight := range hosts {
+        check := hostPreflight.Get_check(name)
+        if check == nil || check.Status != PREFLIGHT_PASS ||
+           check.Value == common {
+            continue
+        }
+        check.Status = PREFLIGHT_FAIL
+        check.Detail = fmt.Sprintf("'%s' on %d other hosts", common,
+                                   counts[common])
+    }
+}
+
+// Run the preflight checks on all the hosts of the hostfile and write the
+// report to the result directory. The campaign must not start when the
+// preflight has not passed.
+func (mpi_cmd_obj *OSU_MPI_cmds)Run_preflight() (*OSU_preflight, error) {
+    logger := logging.GetLoggerInstance()
+    hosts, err := Read_hostfile(mpi_cmd_obj.configObj.HostFile)
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    if len(hosts) == 0 {
+        logger.Error("No hosts in %s", mpi_cmd_obj.configObj.HostFile)
+        return nil, errors.INVALID_INPUT
+    }
+    hostfileDir := filepath.Join(mpi_cmd_obj.result_dir, PAIR_HOSTFILE_DIR)
+    err = os.MkdirAll(hostfileDir, os.ModePerm)
+    if err != nil {
+        logger.Error("Failed to create hostfile directory %s, err : %s",
+                     hostfileDir, err)
+        return nil, err
+    }
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt,
+                                      syscall.SIGTERM)
+    defer stop()
+    preflight := new(OSU_preflight)
+    preflight.Launcher = mpi_cmd_obj.launcher.Name()
+    preflight.Hosts = make([]*OSU_host_preflight, len(hosts))
+    binaries := mpi_cmd_obj.get_preflight_binaries()
+    requiredSlots := mpi_cmd_obj.get_preflight_slots(len(hosts))
+    workers := PREFLIGHT_PARALLEL
+    if workers > len(hosts) {
+        workers = len(hosts)
+    }
+    hostIdxs := make(chan int)
+    var wait sync.WaitGroup
+    for worker := 0; worker < workers; worker++ {
+        wait.Add(1)
+        go func() {
+            defer wait.Done()
+            for idx := range hostIdxs {
+                preflight.Hosts[idx] = mpi_cmd_obj.preflight_host(ctx,
+                                hosts[idx], idx, binaries, requiredSlots)
+            }
+        }()
+    }
+    for idx := range hosts {
+        if ctx.Err() != nil {
+            break
+        }
+        hostIdxs <- idx
+    }
+    close(hostIdxs)
+    wait.Wait()
+    if ctx.Err() != nil {
+        logger.Warning("Preflight checks are interrupted")
+        return nil, errors.INVALID_OP
+    }
+    compare_preflight_check(preflight.Hosts, "mpi")
+    compare_preflight_check(preflight.Hosts, "osu")
+    preflight.Passed = true
+    for _, hostPreflight := range preflight.Hosts {
+        hostPreflight.Passed = true
+        for _, check := range hostPreflight.Checks {
+            if check.Status == PREFLIGHT_FAIL {
+                hostPreflight.Passed = false
+                preflight.Passed = false
+            }
+        }
+    }
+    jsonBytes, err := json.MarshalIndent(preflight, "", "  ")
+    if err == nil {
+        err = ioutil.WriteFile(filepath.Join(mpi_cmd_obj.result_dir,
+                                             PREFLIGHT_REPORT_FILE),
+                               jsonBytes, 0644)
+    }
+    if err != nil {
+        logger.Error("Failed to write preflight report, err : %s", err)
+    }
+    if !preflight.Passed {
+        logger.Error("Preflight checks failed")
+    }
+    return preflight, errors.OP_SUCCESS
+}
+
+// Print the pass/fail table of the hosts and why the checks failed.
+func (preflight *OSU_preflight)Write(out io.Writer) {
+    table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+    fmt.Fprintf(table, "HOST\t%s\tRESULT\n",
+                strings.ToUpper(strings.Join(PREFLIGHT_CHECKS, "\t")))
+    for _, hostPreflight := range preflight.Hosts {
+        fmt.Fprintf(table, "%s", hostPreflight.Host)
+        for _, name := range PREFLIGHT_CHECKS {
+            status := PREFLIGHT_SKIP
+            if check := hostPreflight.Get_check(name); check != nil {
+                status = check.Status
+            }
+            fmt.Fprintf(table, "\t%s", status)
+        }
+        result := PREFLIGHT_PASS
+        if !hostPreflight.Passed {
+            result = PREFLIGHT_FAIL
+        }
+        fmt.Fprintf(table, "\t%s\n", result)
+    }
+    table.Flush()
+    for _, hostPreflight := range preflight.Hosts {
+        for _, check := range hostPreflight.Checks {
+            if check.Status != PREFLIGHT_FAIL {
+                continue
+            }
+            value := ""
+            if len(check.Value) != 0 {
+                value = fmt.Sprintf(" '%s'", check.Value)
+            }
+            fmt.Fprintf(out, "%s %s%s : %s\n", hostPreflight.Host,
+                        check.Name, value, check.Detail)
+        }
+    }
+    passed := 0
+    for _, hostPreflight := range preflight.Hosts {
+        if hostPreflight.Passed {
+            passed++
+        }
+    }
+    fmt.Fprintf(out, "Preflight : %d of %d hosts passed\n", passed,
+                len(preflight.Hosts))
+}
@@ -0,0 +1,116 @@
+package testRunner_test
+
+import (
+    "bytes"
+    "context"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/simulator"
+    "ec2-osu-benchmark/testRunner"
+)
+
+func TestPreflight(t *testing.T) {
+    configObj := testConfig(t)
+    configObj.Benchmarks = []string{"osu_latency", "osu_bw"}
+    configObj.PPN = 80
+    configObj.MPIcount = 160
+    simConfig := simulator.Default_config()
+    simConfig.MissingOn = []string{"h3"}
+    simConfig.ClockSkew["h2"] = 5 * time.Second
+    simConfig.MPIVersionOn["h1"] = "mpirun (Open MPI) 4.1.4"
+    mpi_cmd_obj := new(testRunner.OSU_MPI_cmds)
+    mpi_cmd_obj.Set_command_executor(simulator.New_executor(simConfig))
+    err := mpi_cmd_obj.Init_OSU_MPI_Cmds(configObj)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Init_OSU_MPI_Cmds failed, err : %s", err)
+    }
+    resultDir := mpi_cmd_obj.Get_OSU_MPI_test_result_path()
+    preflight, err := mpi_cmd_obj.Run_preflight()
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Run_preflight failed, err : %s", err)
+    }
+    if preflight.Passed {
+        t.Errorf("preflight passed with broken hosts")
+    }
+    // host -> checks expected to fail, the slots fail everywhere
+    want := map[string][]string{
+        "h1": {"mpi", "slots"},
+        "h2": {"slots", "clock"},
+        "h3": {"binaries", "osu", "slots"}}
+    for _, hostPreflight := range preflight.Hosts {
+        for _, check := range hostPreflight.Checks {
+            failed := check.Status == testRunner.PREFLIGHT_FAIL
+            expected := false
+            for _, name := range want[hostPreflight.Host] {
+                expected = expected || name == check.Name
+            }
+            if failed != expected {
+                t.Errorf("%s %s check is %s(%s : %s)", hostPreflight.Host,
+                         check.Name, check.Status, check.Value, check.Detail)
+            }
+        }
+    }
+    var out bytes.Buffer
+    preflight.Write(&out)
+    if !strings.Contains(out.String(), "h3 binaries '0/2' : missing") ||
+       !strings.Contains(out.String(), "0 of 3 hosts passed") {
+        t.Errorf("unexpected preflight table\n%s", out.String())
+    }
+    _, err = os.Stat(filepath.Join(resultDir, testRunner.PREFLIGHT_REPORT_FILE))
+    if err != nil {
+        t.Errorf("preflight report is not written, err : %s", err)
+    }
+}
+
+// Executor of unreachable hosts, every command hangs till it is killed.
+type hangingExecutor struct {
+    *simulator.Executor
+}
+
+func (executor hangingExecutor) Run(ctx context.Context, argv []string,
+                                    stdout io.Writer,
+                                    stderr io.Writer) (
+                                        *testRunner.OSU_exec_status, error) {
+    <-ctx.Done()
+    return &testRunner.OSU_exec_status{TimedOut: true}, ctx.Err()
+}
+
+// Unreachable hosts are probed at the same time, the preflight takes about
+// a single probe timeout.
+func TestPreflightUnreachableHosts(t *testing.T) {
+    savedTimeout := testRunner.PREFLIGHT_TIMEOUT
+    testRunner.PREFLIGHT_TIMEOUT = 300 * time.Millisecond
+    defer func() { testRunner.PREFLIGHT_TIMEOUT = savedTimeout }()
+    configObj := testConfig(t)
+    mpi_cmd_obj := new(testRunner.OSU_MPI_cmds)
+    mpi_cmd_obj.Set_command_executor(hangingExecutor{
+        simulator.New_executor(simulator.Default_config())})
+    err := mpi_cmd_obj.Init_OSU_MPI_Cmds(configObj)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Init_OSU_MPI_Cmds failed, err : %s", err)
+    }
+    start := time.Now()
+    preflight, err := mpi_cmd_obj.Run_preflight()
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Run_preflight failed, err : %s", err)
+    }
+    if elapsed := time.Since(start); elapsed > 2 * testRunner.PREFLIGHT_TIMEOUT {
+        t.Errorf("preflight of 3 unreachable hosts took %s", elapsed)
+    }
+    if preflight.Passed || len(preflight.Hosts) != 3 {
+        t.Fatalf("unexpected preflight %+v", preflight)
+    }
+    for idx, host := range []string{"h1", "h2", "h3"} {
+        hostPreflight := preflight.Hosts[idx]
+        check := hostPreflight.Get_check("reachable")
+        if hostPreflight.Host != host || check == nil ||
+           check.Status != testRunner.PREFLIGHT_FAIL {
+            t.Errorf("unexpected preflight of %s %+v", host, hostPreflight)
+        }
+    }
+}
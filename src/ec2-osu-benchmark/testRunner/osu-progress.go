@@ -0,0 +1,219 @@
+package testRunner
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+)
+
+// Duration and number of result rows of a previous run of a job, used to
+// estimate the time left in the next campaigns.
+type OSU_history_entry struct {
+    Duration float64 `json:"duration"`
+    Rows int `json:"rows"`
+}
+
+// A running job of the campaign.
+type osu_progress_job struct {
+    name string
+    start time.Time
+    rows int
+    pktsize string
+}
+
+// Progress of the campaign, printed on every result row when enabled.
+// [3/12] osu_alltoall.rep1 size 65536 rows 17/23 elapsed 1m2s ETA 3m10s
+type OSU_progress struct {
+    mutex sync.Mutex
+    enabled bool
+    out io.Writer
+    history_file string
+    history map[string]OSU_history_entry
+    // History keys of all the jobs of the campaign
+    keys []string
+    // Number of jobs run at a time
+    parallel int
+    // Durations of the jobs completed in this campaign
+    completed []float64
+    // Jobs that are done and the running jobs, by index
+    done map[int]bool
+    running map[int]*osu_progress_job
+}
+
+// Load the history of the previous runs, a missing history file starts an
+// empty history.
+func (progress *OSU_progress)Init(enabled bool, out io.Writer,
+                                  historyFile string) {
+    logger := logging.GetLoggerInstance()
+    progress.enabled = enabled
+    progress.out = out
+    progress.history_file = historyFile
+    progress.history = make(map[string]OSU_history_entry)
+    if len(historyFile) == 0 {
+        return
+    }
+    jsonBytes, err := ioutil.ReadFile(historyFile)
+    if err != nil {
+        return
+    }
+    if err = json.Unmarshal(jsonBytes, &progress.history); err != nil {
+        logger.Warning("Ignoring invalid run history %s, err : %s",
+                       historyFile, err)
+        progress.history = make(map[string]OSU_history_entry)
+    }
+}
+
+// Start the campaign of the jobs with the history keys, parallel jobs are
+// run at a time.
+func (progress *OSU_progress)Start_campaign(keys []string, parallel int) {
+    progress.mutex.Lock()
+    defer progress.mutex.Unlock()
+    progress.keys = keys
+    progress.parallel = parallel
+    progress.completed = make([]float64, 0)
+    progress.done = make(map[int]bool)
+    progress.running = make(map[int]*osu_progress_job)
+}
+
+func (progress *OSU_progress)Start_job(idx int, name string) {
+    progress.mutex.Lock()
+    defer progress.mutex.Unlock()
+    progress.running[idx] = &osu_progress_job{name: name, start: time.Now()}
+    progress.print(idx)
+}
+
+// Account a line of the benchmark output of the job, data rows start with
+// the message size.
+func (progress *OSU_progress)Line(idx int, line string) {
+    fields := strings.Fields(line)
+    if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+        return
+    }
+    if _, err := strconv.ParseFloat(fields[0], 64); err != nil {
+        return
+    }
+    progress.mutex.Lock()
+    defer progress.mutex.Unlock()
+    job, ok := progress.running[idx]
+    if !ok {
+        return
+    }
+    job.rows++
+    job.pktsize = fields[0]
+    progress.print(idx)
+}
+
+// Account a job that is not run, ex: its benchmark is not found.
+func (progress *OSU_progress)Skip_job(idx int) {
+    progress.mutex.Lock()
+    defer progress.mutex.Unlock()
+    delete(progress.running, idx)
+    progress.done[idx] = true
+}
+
+// Finish the job, successful runs are added to the history.
+func (progress *OSU_progress)End_job(idx int, status string,
+                                     duration float64) {
+    logger := logging.GetLoggerInstance()
+    progress.mutex.Lock()
+    defer progress.mutex.Unlock()
+    rows := 0
+    if job, ok := progress.running[idx]; ok {
+        rows = job.rows
+    }
+    delete(progress.running, idx)
+    progress.done[idx] = true
+    progress.completed = append(progress.completed, duration)
+    if status != RUN_STATUS_SUCCESS || len(progress.history_file) == 0 {
+        return
+    }
+    progress.history[progress.keys[idx]] =
+        OSU_history_entry{Duration: duration, Rows: rows}
+    jsonBytes, err := json.MarshalIndent(progress.history, "", "  ")
+    if err == nil {
+        err = ioutil.WriteFile(progress.history_file, jsonBytes, 0644)
+    }
+    if err != nil {
+        logger.Warning("Failed to save run history %s, err : %s",
+                       progress.history_file, err)
+    }
+}
+
+// Expected duration of a job, from the history or the average of the jobs
+// completed so far.
+func (progress *OSU_progress)expected_duration(idx int) (float64, error) {
+    if entry, ok := progress.history[progress.keys[idx]]; ok {
+        return entry.Duration, errors.OP_SUCCESS
+    }
+    if len(progress.completed) == 0 {
+        return 0, errors.DATA_NOT_FOUND
+    }
+    total := 0.0
+    for _, duration := range progress.completed {
+        total += duration
+    }
+    return total / float64(len(progress.completed)), errors.OP_SUCCESS
+}
+
+// Time left in the campaign, including the rest of the running jobs. The
+// jobs left are expected to keep all the parallel slots busy.
+func (progress *OSU_progress)eta() (time.Duration, error) {
+    remaining := 0.0
+    left := 0
+    for idx := range progress.keys {
+        if progress.done[idx] {
+            continue
+        }
+        expected, err := progress.expected_duration(idx)
+        if err != errors.OP_SUCCESS {
+            return 0, err
+        }
+        if job, ok := progress.running[idx]; ok {
+            expected -= time.Since(job.start).Seconds()
+            if expected < 0 {
+                expected = 0
+            }
+        }
+        remaining += expected
+        left++
+    }
+    if progress.parallel > 1 && left > 1 {
+        if left > progress.parallel {
+            left = progress.parallel
+        }
+        remaining /= float64(left)
+    }
+    return time.Duration(remaining * float64(time.Second)), errors.OP_SUCCESS
+}
+
+func (progress *OSU_progress)print(idx int) {
+    if !progress.enabled {
+        return
+    }
+    job := progress.running[idx]
+    elapsed := time.Since(job.start)
+    line := fmt.Sprintf("[%d/%d] %s", idx + 1, len(progress.keys), job.name)
+    if len(job.pktsize) != 0 {
+        line += " size " + job.pktsize
+    }
+    line += fmt.Sprintf(" rows %d", job.rows)
+    entry, ok := progress.history[progress.keys[idx]]
+    if ok && entry.Rows != 0 {
+        line += fmt.Sprintf("/%d", entry.Rows)
+    }
+    line += " elapsed " + elapsed.Round(time.Second).String()
+    eta, err := progress.eta()
+    if err == errors.OP_SUCCESS {
+        line += " ETA " + eta.Round(time.Second).String()
+    } else {
+        line += " ETA unknown"
+    }
+    fmt.Fprintln(progress.out, line)
+}
@@ -0,0 +1,138 @@
+package testRunner
+
+import (
+    "crypto/rand"
+    "encoding/binary"
+    "encoding/json"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "time"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+// Crockford's base32 of the ULIDs, sorts the same as the values.
+const RUN_ID_ALPHABET = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Run ID length, 10 characters of the millisecond timestamp and 16 of
+// randomness.
+const RUN_ID_LENGTH = 26
+
+// Identity and start of a run, written to the result directory so that
+// nothing is recovered from the result directory path.
+type OSU_run_info struct {
+    // ULID of the run, sortable by the start time
+    RunID string `json:"runid"`
+    StartTime time.Time `json:"starttime"`
+    ResultDir string `json:"resultdir"`
+}
+
+// New run ID, a ULID(https://github.com/ulid/spec) of the time. The IDs of
+// the runs sort by their start time to the millisecond.
+func New_run_id(start time.Time) (string, error) {
+    var value [16]byte
+    ms := uint64(start.UnixNano() / int64(time.Millisecond))
+    for idx := 0; idx < 6; idx++ {
+        value[idx] = byte(ms >> uint(40 - 8 * idx))
+    }
+    if _, err := rand.Read(value[6:]); err != nil {
+        return "", err
+    }
+    // 128 bits in 26 characters of 5 bits from the lowest, the first
+    // character has the top 3 bits.
+    high := binary.BigEndian.Uint64(value[:8])
+    low := binary.BigEndian.Uint64(value[8:])
+    id := make([]byte, RUN_ID_LENGTH)
+    for idx := RUN_ID_LENGTH - 1; idx >= 0; idx-- {
+        id[idx] = RUN_ID_ALPHABET[low & 31]
+        low = low >> 5 | high << 59
+        high >>= 5
+    }
+    return string(id), errors.OP_SUCCESS
+}
+
+// Start time of the run in the timestamp of its ID.
+func Get_run_id_time(id string) (time.Time, error) {
+    // First character has the top 3 bits of the 128.
+    if len(id) != RUN_ID_LENGTH || id[0] > '7' ||
+       strings.Trim(id, RUN_ID_ALPHABET) != "" {
+        return time.Time{}, errors.INVALID_INPUT
+    }
+    ms := uint64(0)
+    for _, char := range id[:10] {
+        ms = ms << 5 | uint64(strings.IndexRune(RUN_ID_ALPHABET, char))
+    }
+    return time.Unix(0, int64(ms) * int64(time.Millisecond)),
+           errors.OP_SUCCESS
+}
+
+func Read_run_info(resultDir string) (*OSU_run_info, error) {
+    logger := logging.GetLoggerInstance()
+    fileName := filepath.Join(resultDir, config.RUN_FILE)
+    jsonBytes, err := ioutil.ReadFile(fileName)
+    if err != nil {
+        logger.Error("Failed to read run info %s, err : %s", fileName, err)
+        return nil, err
+    }
+    info := new(OSU_run_info)
+    if err = json.Unmarshal(jsonBytes, info); err != nil {
+        logger.Error("Invalid run info %s, err : %s", fileName, err)
+        return nil, err
+    }
+    return info, errors.OP_SUCCESS
+}
+
+func (info *OSU_run_info)Write() error {
+    logger := logging.GetLoggerInstance()
+    fileName := filepath.Join(info.ResultDir, config.RUN_FILE)
+    jsonBytes, err := json.MarshalIndent(info, "", "  ")
+    if err != nil {
+        logger.Error("Failed to marshal run info, err : %s", err)
+        return err
+    }
+    err = ioutil.WriteFile(fileName, jsonBytes, 0644)
+    if err != nil {
+        logger.Error("Failed to write run info %s, err : %s", fileName, err)
+        return err
+    }
+    return errors.OP_SUCCESS
+}
+
+// Identity of a new run and its result directory in the results root.
+func new_run_info(configObj *config.AppConfig) (*OSU_run_info, error) {
+    info := new(OSU_run_info)
+    info.StartTime = time.Now()
+    runID, err := New_run_id(info.StartTime)
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    info.RunID = runID
+    root := configObj.ResultsRoot
+    if len(root) == 0 {
+        root = config.DEFAULT_PATH
+    }
+    layout := configObj.ResultLayout
+    if len(layout) == 0 {
+        layout = config.DEFAULT_RESULT_LAYOUT
+    }
+    info.ResultDir = config.ExpandResultLayout(root, layout, info.RunID,
+                                               info.StartTime)
+    return info, errors.OP_SUCCESS
+}
+
+// Identity of a resumed campaign without the run info, the ID is of the
+// campaign start.
+func resumed_run_info(campaign *OSU_campaign_state,
+                      resultDir string) (*OSU_run_info, error) {
+    info := new(OSU_run_info)
+    info.StartTime = campaign.StartTime
+    runID, err := New_run_id(info.StartTime)
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    info.RunID = runID
+    info.ResultDir = resultDir
+    return info, errors.OP_SUCCESS
+}
@@ -0,0 +1,142 @@
+package testRunner
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "path/filepath"
+    "sort"
+    "time"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/config"
+)
+
+// Suffix of the run record file, stored next to the result file.
+const RUN_RECORD_FILE_SUFFIX = ".meta.json"
+
+// Status of a benchmark run
+const (
+    RUN_STATUS_SUCCESS = "success"
+    RUN_STATUS_FAILED = "failed"
+    RUN_STATUS_TIMEOUT = "timeout"
+    RUN_STATUS_INTERRUPTED = "interrupted"
+)
+
+// Metadata of a benchmark run, persisted in the result directory and
+// exported in the json report.
+type OSU_run_record struct {
+    Benchmark string `json:"benchmark"`
+    Category string `json:"category"`
+    // Repetition of the benchmark, starts from 1
+    Repetition uint `json:"repetition"`
+    // Number of processes and processes per node(0 for launcher default)
+    NP uint `json:"np"`
+    PPN uint `json:"ppn"`
+    // Process mapping and binding, empty for the launcher default
+    MapBy string `json:"mapby,omitempty"`
+    BindTo string `json:"bindto,omitempty"`
+    // Environment variant of the run, its entries are in Env
+    Variant string `json:"variant,omitempty"`
+    // Binding report of the launcher, where every rank is bound
+    Bindings []string `json:"bindings,omitempty"`
+    // First repetition of the first np/ppn configuration, the one
+    // reported in the per benchmark sections of the report
+    Primary bool `json:"primary"`
+    // Hosts of the run in the pair matrix mode
+    Hosts []string `json:"hosts,omitempty"`
+    // Hosts the run is given in the parallel mode
+    HostSlice []string `json:"hostslice,omitempty"`
+    // MPI launcher used for the run
+    Launcher string `json:"launcher"`
+    // Complete command line used for the run
+    Command string `json:"command"`
+    // Extra launcher arguments and environment(tunables) of the run
+    LauncherArgs []string `json:"launcherargs"`
+    Env []string `json:"env"`
+    // OSU options used for the run
+    Options config.OSUOptions `json:"options"`
+    // OSU version from the title of the results, set in the report
+    OSUVersion string `json:"osuversion,omitempty"`
+    // Files with the stdout(OSU results) and stderr of the run
+    StdoutFile string `json:"stdoutfile"`
+    StderrFile string `json:"stderrfile"`
+    // Deadline of the run, "0s" for no deadline
+    Timeout string `json:"timeout"`
+    // One of RUN_STATUS_*
+    Status string `json:"status"`
+    StartTime time.Time `json:"starttime"`
+    EndTime time.Time `json:"endtime"`
+    // Wall time of the run in seconds
+    Duration float64 `json:"duration"`
+    // Exit code of the launcher, -1 when it is killed by a signal
+    ExitCode int `json:"exitcode"`
+    // Signal that killed the launcher, empty when it exited normally
+    Signal string `json:"signal,omitempty"`
+    // Host telemetry over the run, nil when it is not sampled
+    Telemetry *OSU_telemetry_summary `json:"telemetry,omitempty"`
+}
+
+func Write_run_record(record *OSU_run_record, fileName string) error {
+    logger := logging.GetLoggerInstance()
+    jsonBytes, err := json.MarshalIndent(record, "", "  ")
+    if err != nil {
+        logger.Error("Failed to marshal run record of %s, err : %s",
+                     record.Benchmark, err)
+        return err
+    }
+    err = ioutil.WriteFile(fileName, jsonBytes, 0644)
+    if err != nil {
+        logger.Error("Failed to write run record %s, err : %s",
+                     fileName, err)
+        return err
+    }
+    return errors.OP_SUCCESS
+}
+
+// Read all the run records in a result directory, sorted by benchmark,
+// np, ppn, mapping, binding, variant and repetition.
+func Read_run_records(resultDir string) ([]*OSU_run_record, error) {
+    logger := logging.GetLoggerInstance()
+    fileNames, err := filepath.Glob(filepath.Join(resultDir,
+                                   "*" + RUN_RECORD_FILE_SUFFIX))
+    if err != nil {
+        return nil, err
+    }
+    records := make([]*OSU_run_record, 0, len(fileNames))
+    for _, fileName := range fileNames {
+        jsonBytes, err := ioutil.ReadFile(fileName)
+        if err != nil {
+            logger.Error("Failed to read run record %s, err : %s",
+                         fileName, err)
+            continue
+        }
+        record := new(OSU_run_record)
+        if err = json.Unmarshal(jsonBytes, record); err != nil {
+            logger.Error("Invalid run record %s, err : %s", fileName, err)
+            continue
+        }
+        records = append(records, record)
+    }
+    sort.SliceStable(records, func(i, j int) bool {
+        if records[i].Benchmark != records[j].Benchmark {
+            return records[i].Benchmark < records[j].Benchmark
+        }
+        if records[i].NP != records[j].NP {
+            return records[i].NP < records[j].NP
+        }
+        if records[i].PPN != records[j].PPN {
+            return records[i].PPN < records[j].PPN
+        }
+        if records[i].MapBy != records[j].MapBy {
+            return records[i].MapBy < records[j].MapBy
+        }
+        if records[i].BindTo != records[j].BindTo {
+            return records[i].BindTo < records[j].BindTo
+        }
+        if records[i].Variant != records[j].Variant {
+            return records[i].Variant < records[j].Variant
+        }
+        return records[i].Repetition < records[j].Repetition
+    })
+    return records, errors.OP_SUCCESS
+}
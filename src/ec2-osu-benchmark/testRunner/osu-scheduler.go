@@ -0,0 +1,190 @@
+package testRunner
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "sync"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+)
+
+// Hosts of the hostfile a job runs on in the parallel mode.
+type OSU_host_slice struct {
+    Hosts []OSU_host
+    // Hostfile with the hosts of the slice
+    HostFile string
+}
+
+// Hands out disjoint slices of the hostfile to the jobs run at a time.
+type osu_scheduler struct {
+    hosts []OSU_host
+    // Hosts in a slice of a running job
+    busy map[string]bool
+}
+
+func new_scheduler(hosts []OSU_host) *osu_scheduler {
+    scheduler := new(osu_scheduler)
+    scheduler.hosts = hosts
+    scheduler.busy = make(map[string]bool)
+    return scheduler
+}
+
+// Number of hosts the job needs, enough hosts for np processes with ppn
+// per host or with the slots of the hosts. Hosts without slots take a
+// process. A job that needs more than the hostfile takes all the hosts.
+func get_job_host_count(job *OSU_job, hosts []OSU_host) int {
+    if job.PPN != 0 {
+        count := int((job.NP + job.PPN - 1) / job.PPN)
+        if count > len(hosts) {
+            return len(hosts)
+        }
+        return count
+    }
+    slots := uint(0)
+    for idx, host := range hosts {
+        if host.Slots == 0 {
+            slots++
+        } else {
+            slots += host.Slots
+        }
+        if slots >= job.NP {
+            return idx + 1
+        }
+    }
+    return len(hosts)
+}
+
+// Allocate the hosts of the job, the pair hosts in the pair matrix mode
+// and the first free hosts otherwise. Returns nil when the hosts are not
+// free.
+func (scheduler *osu_scheduler)allocate(job *OSU_job) []OSU_host {
+    slice := make([]OSU_host, 0)
+    if job.Pair != nil {
+        for _, name := range job.Pair.Hosts {
+            if scheduler.busy[name] {
+                return nil
+            }
+            slice = append(slice, OSU_host{Name: name})
+        }
+    } else {
+        count := get_job_host_count(job, scheduler.hosts)
+        for _, host := range scheduler.hosts {
+            if len(slice) == count {
+                break
+            }
+            if !scheduler.busy[host.Name] {
+                slice = append(slice, host)
+            }
+        }
+        if len(slice) < count {
+            return nil
+        }
+    }
+    for _, host := range slice {
+        scheduler.busy[host.Name] = true
+    }
+    return slice
+}
+
+func (scheduler *osu_scheduler)release(slice []OSU_host) {
+    for _, host := range slice {
+        delete(scheduler.busy, host.Name)
+    }
+}
+
+// Slice of the hosts for the job, the pair hostfile in the pair matrix
+// mode and a hostfile of the job otherwise.
+func (mpi_cmd_obj *OSU_MPI_cmds)new_host_slice(job *OSU_job,
+                                hosts []OSU_host) (*OSU_host_slice, error) {
+    slice := new(OSU_host_slice)
+    slice.Hosts = hosts
+    if job.Pair != nil {
+        slice.HostFile = job.Pair.HostFile
+        return slice, errors.OP_SUCCESS
+    }
+    slice.HostFile = filepath.Join(mpi_cmd_obj.result_dir, PAIR_HOSTFILE_DIR,
+                                   job.Name() + ".hostfile")
+    err := Write_hostfile(hosts, slice.HostFile, mpi_cmd_obj.launcher.Name())
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    return slice, errors.OP_SUCCESS
+}
+
+// A job that is done in the parallel mode.
+type osu_job_result struct {
+    hosts []OSU_host
+    err error
+}
+
+// Run the jobs with up to parallel jobs at a time on disjoint hosts. The
+// jobs are started in the campaign order, a job whose hosts are busy lets
+// the next jobs that fit in the free hosts go first. All the jobs stream
+// to the result writer and write their own result files.
+func (mpi_cmd_obj *OSU_MPI_cmds)run_parallel(ctx context.Context,
+                                             jobs []*OSU_job,
+                                             parallel int) error {
+    logger := logging.GetLoggerInstance()
+    hosts, err := Read_hostfile(mpi_cmd_obj.configObj.HostFile)
+    if err != errors.OP_SUCCESS {
+        return err
+    }
+    hostfileDir := filepath.Join(mpi_cmd_obj.result_dir, PAIR_HOSTFILE_DIR)
+    if err := os.MkdirAll(hostfileDir, os.ModePerm); err != nil {
+        logger.Error("Failed to create hostfile directory %s, err : %s",
+                     hostfileDir, err)
+        return err
+    }
+    scheduler := new_scheduler(hosts)
+    results := make(chan osu_job_result)
+    var wait sync.WaitGroup
+    pending := make([]int, len(jobs))
+    for idx := range jobs {
+        pending[idx] = idx
+    }
+    running := 0
+    err = errors.OP_SUCCESS
+    for len(pending) != 0 || running != 0 {
+        for pos := 0; pos < len(pending) && running < parallel &&
+                      ctx.Err() == nil; {
+            idx := pending[pos]
+            job := jobs[idx]
+            sliceHosts := scheduler.allocate(job)
+            if sliceHosts == nil {
+                pos++
+                continue
+            }
+            pending = append(pending[:pos], pending[pos + 1:]...)
+            slice, sliceErr := mpi_cmd_obj.new_host_slice(job, sliceHosts)
+            if sliceErr != errors.OP_SUCCESS {
+                scheduler.release(sliceHosts)
+                mpi_cmd_obj.progress.Skip_job(idx)
+                if err == errors.OP_SUCCESS {
+                    err = sliceErr
+                }
+                continue
+            }
+            job.Slice = slice
+            running++
+            wait.Add(1)
+            go func(idx int, job *OSU_job) {
+                defer wait.Done()
+                results <- osu_job_result{job.Slice.Hosts,
+                                          mpi_cmd_obj.run_job(ctx, idx, job)}
+            }(idx, job)
+        }
+        if running == 0 {
+            // Interrupted, the pending jobs are left for -resume.
+            break
+        }
+        result := <-results
+        running--
+        scheduler.release(result.hosts)
+        if result.err != errors.OP_SUCCESS && err == errors.OP_SUCCESS {
+            err = result.err
+        }
+    }
+    wait.Wait()
+    return err
+}
@@ -0,0 +1,91 @@
+package testRunner
+
+import (
+    "bytes"
+    "sync"
+)
+
+// io.Writer that hands the output of a command to a callback line by line,
+// so the results reach the result file while the benchmark is running. A
+// partial line is held back till its newline arrives or Flush is called.
+type line_writer struct {
+    mutex sync.Mutex
+    pending []byte
+    on_line func(line string)
+}
+
+func new_line_writer(on_line func(line string)) *line_writer {
+    writer := new(line_writer)
+    writer.on_line = on_line
+    return writer
+}
+
+func (writer *line_writer)Write(data []byte) (int, error) {
+    writer.mutex.Lock()
+    defer writer.mutex.Unlock()
+    writer.pending = append(writer.pending, data...)
+    for {
+        idx := bytes.IndexByte(writer.pending, '\n')
+        if idx < 0 {
+            break
+        }
+        line := string(writer.pending[:idx + 1])
+        writer.pending = writer.pending[idx + 1:]
+        writer.on_line(line)
+    }
+    return len(data), nil
+}
+
+// Hand over the partial last line, terminated with a newline so the
+// result file stays line oriented.
+func (writer *line_writer)Flush() {
+    writer.mutex.Lock()
+    defer writer.mutex.Unlock()
+    if len(writer.pending) != 0 {
+        writer.on_line(string(writer.pending) + "\n")
+        writer.pending = nil
+    }
+}
+
+// Line writer that streams the lines of a command output to a result file
+// through the result writer.
+type osu_result_stream struct {
+    *line_writer
+    mpi_cmd_obj *OSU_MPI_cmds
+    file_name string
+}
+
+// Result stream of a result file. The result file is created right away,
+// so it is there even when the command prints nothing.
+func (mpi_cmd_obj *OSU_MPI_cmds)new_result_stream(fileName string,
+                            on_line func(line string)) *osu_result_stream {
+    stream := new(osu_result_stream)
+    stream.mpi_cmd_obj = mpi_cmd_obj
+    stream.file_name = fileName
+    stream.push("")
+    stream.line_writer = new_line_writer(func(line string) {
+        stream.push(line)
+        if on_line != nil {
+            on_line(line)
+        }
+    })
+    return stream
+}
+
+func (stream *osu_result_stream)push(data string) {
+    var result osu_result_channel
+    result.SetResultChannel(data, stream.file_name)
+    stream.mpi_cmd_obj.result_channel <- result
+}
+
+// Hand over the partial last line and close the result file in the
+// writer, nothing can be written to the stream after it. It returns once
+// the writer has written and closed the file, with the first error of it.
+func (stream *osu_result_stream)Close() error {
+    stream.Flush()
+    var result osu_result_channel
+    done := make(chan error, 1)
+    result.SetResultClose(stream.file_name, done)
+    stream.mpi_cmd_obj.result_channel <- result
+    return <-done
+}
@@ -0,0 +1,541 @@
+package testRunner
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/errors"
+)
+
+const TELEMETRY_FILE_SUFFIX = ".telemetry.json"
+
+// Host noise above these is reported in the warnings of the summary.
+const (
+    // Percent of the CPU time taken by the hypervisor
+    TELEMETRY_STEAL_WARNING = 1.0
+    // Percent of the CPU time waiting for IO
+    TELEMETRY_IOWAIT_WARNING = 5.0
+    // Lowest CPU frequency relative to the highest
+    TELEMETRY_FREQ_SPREAD_WARNING = 0.9
+    // IRQ sources listed in the summary
+    TELEMETRY_TOP_INTERRUPTS = 5
+)
+
+// Host state in a sampling interval, the counters are the change since the
+// previous sample.
+type OSU_telemetry_sample struct {
+    Time time.Time `json:"time"`
+    // 1 minute load average and runnable processes(/proc/loadavg)
+    Load1 float64 `json:"load1"`
+    Running uint64 `json:"running"`
+    // Percent of the CPU time of all the CPUs(/proc/stat), user includes
+    // nice and irq includes softirq
+    User float64 `json:"user"`
+    System float64 `json:"system"`
+    IOWait float64 `json:"iowait"`
+    IRQ float64 `json:"irq"`
+    Steal float64 `json:"steal"`
+    Idle float64 `json:"idle"`
+    // Interrupts of all the CPUs(/proc/interrupts) and context switches
+    Interrupts uint64 `json:"interrupts"`
+    ContextSwitches uint64 `json:"contextswitches"`
+    // Bytes received and sent on all the interfaces but lo(/proc/net/dev)
+    RxBytes uint64 `json:"rxbytes"`
+    TxBytes uint64 `json:"txbytes"`
+    // Current frequency of the CPUs in MHz(cpufreq), 0 without cpufreq
+    FreqMin float64 `json:"freqmin,omitempty"`
+    FreqMean float64 `json:"freqmean,omitempty"`
+    FreqMax float64 `json:"freqmax,omitempty"`
+}
+
+// Time series of a benchmark run, saved next to its results.
+type OSU_telemetry struct {
+    Interval string `json:"interval"`
+    Start time.Time `json:"start"`
+    Samples []*OSU_telemetry_sample `json:"samples"`
+}
+
+// Interrupts of an IRQ source during a run.
+type OSU_telemetry_irq struct {
+    IRQ string `json:"irq"`
+    Name string `json:"name,omitempty"`
+    Count uint64 `json:"count"`
+}
+
+// Host state over a benchmark run, kept in the run record.
+type OSU_telemetry_summary struct {
+    // Host that is sampled, the one running the launcher
+    Host string `json:"host"`
+    Interval string `json:"interval"`
+    Samples int `json:"samples"`
+    File string `json:"file"`
+    Load1Max float64 `json:"load1max"`
+    // Percent of the CPU time over the run
+    User float64 `json:"user"`
+    System float64 `json:"system"`
+    IOWait float64 `json:"iowait"`
+    IRQ float64 `json:"irq"`
+    Steal float64 `json:"steal"`
+    StealMax float64 `json:"stealmax"`
+    Idle float64 `json:"idle"`
+    // Per second over the run
+    InterruptRate float64 `json:"interruptrate"`
+    ContextSwitchRate float64 `json:"contextswitchrate"`
+    RxRate float64 `json:"rxrate"`
+    TxRate float64 `json:"txrate"`
+    // CPU frequency range of the samples in MHz
+    FreqMin float64 `json:"freqmin,omitempty"`
+    FreqMax float64 `json:"freqmax,omitempty"`
+    TopInterrupts []*OSU_telemetry_irq `json:"topinterrupts,omitempty"`
+    // Jobs that ran on the host at the same time(-parallel), the host
+    // state includes them
+    ConcurrentJobs []string `json:"concurrentjobs,omitempty"`
+    // Host noise that may explain an outlier, ex: steal time
+    Warnings []string `json:"warnings,omitempty"`
+}
+
+// Raw counters of the host at a time.
+type osu_telemetry_counters struct {
+    time time.Time
+    load1 float64
+    running uint64
+    cpus int
+    // user, nice, system, idle, iowait, irq, softirq, steal
+    cpu [8]uint64
+    ctxt uint64
+    // Interrupts and name of every IRQ source
+    interrupts map[string]uint64
+    irqNames map[string]string
+    rx uint64
+    tx uint64
+    // Frequency of every CPU in MHz
+    freqs []float64
+}
+
+// Samples the host while a benchmark runs. The host is the one running
+// the launcher, the ranks on the other hosts are not sampled. Parallel jobs
+// share the host, their samplers see each other's load and the summary
+// lists the jobs that ran at the same time.
+type OSU_telemetry_sampler struct {
+    interval time.Duration
+    host string
+    procDir string
+    sysDir string
+    // Other jobs running while sampling, guarded by osu_telemetry_jobs
+    concurrent map[string]bool
+    baseline *osu_telemetry_counters
+    last *osu_telemetry_counters
+    samples []*OSU_telemetry_sample
+    stop chan struct{}
+    done chan struct{}
+}
+
+func new_telemetry_sampler(interval time.Duration,
+                           root string) *OSU_telemetry_sampler {
+    sampler := new(OSU_telemetry_sampler)
+    sampler.interval = interval
+    sampler.procDir = filepath.Join(root, "proc")
+    sampler.sysDir = filepath.Join(root, "sys")
+    sampler.concurrent = make(map[string]bool)
+    sampler.host = "localhost"
+    if lines := read_telemetry_lines(filepath.Join(sampler.procDir, "sys",
+                                                   "kernel", "hostname"));
+       len(lines) != 0 && len(lines[0]) != 0 {
+        sampler.host = lines[0][0]
+    }
+    return sampler
+}
+
+// Samplers of the jobs running at a time, each knows the jobs that ran
+// alongside it.
+type osu_telemetry_jobs struct {
+    mutex sync.Mutex
+    running map[*OSU_telemetry_sampler]string
+}
+
+// Start sampling for the job, the running jobs and the job see each other.
+func (jobs *osu_telemetry_jobs)Start(sampler *OSU_telemetry_sampler,
+                                     jobName string) {
+    jobs.mutex.Lock()
+    if jobs.running == nil {
+        jobs.running = make(map[*OSU_telemetry_sampler]string)
+    }
+    for other, otherName := range jobs.running {
+        other.concurrent[jobName] = true
+        sampler.concurrent[otherName] = true
+    }
+    jobs.running[sampler] = jobName
+    jobs.mutex.Unlock()
+    sampler.Start()
+}
+
+// Stop sampling for the job, see OSU_telemetry_sampler.Stop.
+func (jobs *osu_telemetry_jobs)Stop(
+                            sampler *OSU_telemetry_sampler) *OSU_telemetry {
+    jobs.mutex.Lock()
+    delete(jobs.running, sampler)
+    jobs.mutex.Unlock()
+    return sampler.Stop()
+}
+
+// Read the line fields of a file, nil when the file can't be read.
+func read_telemetry_lines(fileName string) [][]string {
+    file, err := os.Open(fileName)
+    if err != nil {
+        return nil
+    }
+    defer file.Close()
+    lines := make([][]string, 0)
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(make([]byte, 64 * 1024), 1024 * 1024)
+    for scanner.Scan() {
+        lines = append(lines, strings.Fields(scanner.Text()))
+    }
+    return lines
+}
+
+// /proc/loadavg : 0.52 0.58 0.59 1/467 12345
+func (counters *osu_telemetry_counters)read_loadavg(procDir string) {
+    lines := read_telemetry_lines(filepath.Join(procDir, "loadavg"))
+    if len(lines) == 0 || len(lines[0]) < 4 {
+        return
+    }
+    counters.load1, _ = strconv.ParseFloat(lines[0][0], 64)
+    running := strings.SplitN(lines[0][3], "/", 2)
+    counters.running, _ = strconv.ParseUint(running[0], 10, 64)
+}
+
+// /proc/stat : cpu  user nice system idle iowait irq softirq steal ...
+func (counters *osu_telemetry_counters)read_stat(procDir string) {
+    for _, fields := range read_telemetry_lines(filepath.Join(procDir,
+                                                              "stat")) {
+        if len(fields) < 2 {
+            continue
+        }
+        switch {
+        case fields[0] == "cpu":
+            for idx := range counters.cpu {
+                if idx + 1 < len(fields) {
+                    counters.cpu[idx], _ = strconv.ParseUint(fields[idx + 1],
+                                                             10, 64)
+                }
+            }
+        case strings.HasPrefix(fields[0], "cpu"):
+            counters.cpus++
+        case fields[0] == "ctxt":
+            counters.ctxt, _ = strconv.ParseUint(fields[1], 10, 64)
+        }
+    }
+}
+
+// /proc/interrupts : a count per CPU followed by the name of the source
+//            CPU0       CPU1
+//   0:         22          0   IO-APIC   2-edge      timer
+// NMI:          0          0   Non-maskable interrupts
+func (counters *osu_telemetry_counters)read_interrupts(procDir string) {
+    lines := read_telemetry_lines(filepath.Join(procDir, "interrupts"))
+    if len(lines) == 0 {
+        return
+    }
+    cpus := len(lines[0])
+    for _, fields := range lines[1:] {
+        if len(fields) < 2 || !strings.HasSuffix(fields[0], ":") {
+            continue
+        }
+        irq := strings.TrimSuffix(fields[0], ":")
+        count := uint64(0)
+        idx := 1
+        for ; idx < len(fields) && idx <= cpus; idx++ {
+            value, err := strconv.ParseUint(fields[idx], 10, 64)
+            if err != nil {
+                break
+            }
+            count += value
+        }
+        counters.interrupts[irq] = count
+        counters.irqNames[irq] = strings.Join(fields[idx:], " ")
+    }
+}
+
+// /proc/net/dev : two header lines, then
+//   eth0: rx_bytes rx_packets ... (8 rx fields) tx_bytes ...
+func (counters *osu_telemetry_counters)read_net_dev(procDir string) {
+    lines := read_telemetry_lines(filepath.Join(procDir, "net", "dev"))
+    for _, fields := range lines {
+        line := strings.Join(fields, " ")
+        colon := strings.Index(line, ":")
+        if colon < 0 {
+            continue
+        }
+        name := strings.TrimSpace(line[:colon])
+        values := strings.Fields(line[colon + 1:])
+        if name == "lo" || len(values) < 9 {
+            continue
+        }
+        rx, _ := strconv.ParseUint(values[0], 10, 64)
+        tx, _ := strconv.ParseUint(values[8], 10, 64)
+        counters.rx += rx
+        counters.tx += tx
+    }
+}
+
+// scaling_cur_freq of every CPU in kHz, missing on the instances without
+// cpufreq.
+func (counters *osu_telemetry_counters)read_cpufreq(sysDir string) {
+    fileNames, _ := filepath.Glob(filepath.Join(sysDir, "devices", "system",
+                                  "cpu", "cpu[0-9]*", "cpufreq",
+                                  "scaling_cur_freq"))
+    for _, fileName := range fileNames {
+        lines := read_telemetry_lines(fileName)
+        if len(lines) == 0 || len(lines[0]) == 0 {
+            continue
+        }
+        khz, err := strconv.ParseFloat(lines[0][0], 64)
+        if err == nil {
+            counters.freqs = append(counters.freqs, khz / 1000)
+        }
+    }
+}
+
+// Read the counters, the files that can't be read leave their counters 0.
+func (sampler *OSU_telemetry_sampler)read_counters() *osu_telemetry_counters {
+    counters := new(osu_telemetry_counters)
+    counters.time = time.Now()
+    counters.interrupts = make(map[string]uint64)
+    counters.irqNames = make(map[string]string)
+    counters.read_loadavg(sampler.procDir)
+    counters.read_stat(sampler.procDir)
+    counters.read_interrupts(sampler.procDir)
+    counters.read_net_dev(sampler.procDir)
+    counters.read_cpufreq(sampler.sysDir)
+    return counters
+}
+
+// Counters wrap or reset when a device goes away.
+func counter_delta(from uint64, to uint64) uint64 {
+    if to < from {
+        return 0
+    }
+    return to - from
+}
+
+func interrupt_total(interrupts map[string]uint64) uint64 {
+    total := uint64(0)
+    for _, count := range interrupts {
+        total += count
+    }
+    return total
+}
+
+// Sample of the change of the counters.
+func new_telemetry_sample(from *osu_telemetry_counters,
+                          to *osu_telemetry_counters) *OSU_telemetry_sample {
+    sample := new(OSU_telemetry_sample)
+    sample.Time = to.time
+    sample.Load1 = to.load1
+    sample.Running = to.running
+    var cpu [8]float64
+    total := 0.0
+    for idx := range cpu {
+        cpu[idx] = float64(counter_delta(from.cpu[idx], to.cpu[idx]))
+        total += cpu[idx]
+    }
+    if total > 0 {
+        percent := func(value float64) float64 {
+            return 100 * value / total
+        }
+        sample.User = percent(cpu[0] + cpu[1])
+        sample.System = percent(cpu[2])
+        sample.Idle = percent(cpu[3])
+        sample.IOWait = percent(cpu[4])
+        sample.IRQ = percent(cpu[5] + cpu[6])
+        sample.Steal = percent(cpu[7])
+    }
+    sample.Interrupts = counter_delta(interrupt_total(from.interrupts),
+                              interrupt_total(to.interrupts))
+    sample.ContextSwitches = counter_delta(from.ctxt, to.ctxt)
+    sample.RxBytes = counter_delta(from.rx, to.rx)
+    sample.TxBytes = counter_delta(from.tx, to.tx)
+    for idx, freq := range to.freqs {
+        if idx == 0 || freq < sample.FreqMin {
+            sample.FreqMin = freq
+        }
+        if freq > sample.FreqMax {
+            sample.FreqMax = freq
+        }
+        sample.FreqMean += freq / float64(len(to.freqs))
+    }
+    return sample
+}
+
+func (sampler *OSU_telemetry_sampler)take_sample() {
+    counters := sampler.read_counters()
+    sampler.samples = append(sampler.samples,
+                             new_telemetry_sample(sampler.last, counters))
+    sampler.last = counters
+}
+
+// Start sampling in the background, till Stop.
+func (sampler *OSU_telemetry_sampler)Start() {
+    sampler.baseline = sampler.read_counters()
+    sampler.last = sampler.baseline
+    sampler.samples = make([]*OSU_telemetry_sample, 0)
+    sampler.stop = make(chan struct{})
+    sampler.done = make(chan struct{})
+    go func() {
+        defer close(sampler.done)
+        ticker := time.NewTicker(sampler.interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                sampler.take_sample()
+            case <-sampler.stop:
+                return
+            }
+        }
+    }()
+}
+
+// Stop sampling, the last sample is taken at the end of the run.
+func (sampler *OSU_telemetry_sampler)Stop() *OSU_telemetry {
+    close(sampler.stop)
+    <-sampler.done
+    sampler.take_sample()
+    telemetry := new(OSU_telemetry)
+    telemetry.Interval = sampler.interval.String()
+    telemetry.Start = sampler.baseline.time
+    telemetry.Samples = sampler.samples
+    return telemetry
+}
+
+// Summary of the run from the start to the end of the sampling and the
+// peaks of the samples.
+func (sampler *OSU_telemetry_sampler)Summary() *OSU_telemetry_summary {
+    summary := new(OSU_telemetry_summary)
+    summary.Host = sampler.host
+    summary.Interval = sampler.interval.String()
+    summary.Samples = len(sampler.samples)
+    run := new_telemetry_sample(sampler.baseline, sampler.last)
+    summary.User = run.User
+    summary.System = run.System
+    summary.IOWait = run.IOWait
+    summary.IRQ = run.IRQ
+    summary.Steal = run.Steal
+    summary.Idle = run.Idle
+    if seconds := sampler.last.time.Sub(sampler.baseline.time).Seconds();
+       seconds > 0 {
+        summary.InterruptRate = float64(run.Interrupts) / seconds
+        summary.ContextSwitchRate = float64(run.ContextSwitches) / seconds
+        summary.RxRate = float64(run.RxBytes) / seconds
+        summary.TxRate = float64(run.TxBytes) / seconds
+    }
+    for _, sample := range sampler.samples {
+        if sample.Load1 > summary.Load1Max {
+            summary.Load1Max = sample.Load1
+        }
+        if sample.Steal > summary.StealMax {
+            summary.StealMax = sample.Steal
+        }
+        if sample.FreqMax == 0 {
+            continue
+        }
+        if summary.FreqMin == 0 || sample.FreqMin < summary.FreqMin {
+            summary.FreqMin = sample.FreqMin
+        }
+        if sample.FreqMax > summary.FreqMax {
+            summary.FreqMax = sample.FreqMax
+        }
+    }
+    for irq, count := range sampler.last.interrupts {
+        count = counter_delta(sampler.baseline.interrupts[irq], count)
+        if count != 0 {
+            summary.TopInterrupts = append(summary.TopInterrupts,
+                &OSU_telemetry_irq{irq, sampler.last.irqNames[irq], count})
+        }
+    }
+    sort.Slice(summary.TopInterrupts, func(i, j int) bool {
+        left, right := summary.TopInterrupts[i], summary.TopInterrupts[j]
+        if left.Count != right.Count {
+            return left.Count > right.Count
+        }
+        return left.IRQ < right.IRQ
+    })
+    if len(summary.TopInterrupts) > TELEMETRY_TOP_INTERRUPTS {
+        summary.TopInterrupts =
+            summary.TopInterrupts[:TELEMETRY_TOP_INTERRUPTS]
+    }
+    for jobName := range sampler.concurrent {
+        summary.ConcurrentJobs = append(summary.ConcurrentJobs, jobName)
+    }
+    sort.Strings(summary.ConcurrentJobs)
+    summary.Warnings = sampler.get_warnings(summary)
+    return summary
+}
+
+func (sampler *OSU_telemetry_sampler)get_warnings(
+                            summary *OSU_telemetry_summary) []string {
+    warnings := make([]string, 0)
+    if summary.Steal >= TELEMETRY_STEAL_WARNING {
+        warnings = append(warnings, fmt.Sprintf("steal time %.1f%%, " +
+                          "peak %.1f%%", summary.Steal, summary.StealMax))
+    }
+    if summary.IOWait >= TELEMETRY_IOWAIT_WARNING {
+        warnings = append(warnings, fmt.Sprintf("iowait %.1f%%",
+                                                summary.IOWait))
+    }
+    if cpus := sampler.last.cpus; cpus != 0 &&
+       summary.Load1Max > float64(cpus) {
+        warnings = append(warnings, fmt.Sprintf("load average %.2f above " +
+                          "%d CPUs", summary.Load1Max, cpus))
+    }
+    if summary.FreqMin != 0 &&
+       summary.FreqMin < TELEMETRY_FREQ_SPREAD_WARNING * summary.FreqMax {
+        warnings = append(warnings, fmt.Sprintf("CPU frequency varies " +
+                          "%.0f-%.0f MHz", summary.FreqMin, summary.FreqMax))
+    }
+    if len(summary.ConcurrentJobs) != 0 {
+        warnings = append(warnings, fmt.Sprintf("%d other jobs ran on %s",
+                          len(summary.ConcurrentJobs), summary.Host))
+    }
+    return warnings
+}
+
+func (telemetry *OSU_telemetry)Write(fileName string) error {
+    logger := logging.GetLoggerInstance()
+    jsonBytes, err := json.MarshalIndent(telemetry, "", "  ")
+    if err != nil {
+        logger.Error("Failed to marshal telemetry, err : %s", err)
+        return err
+    }
+    err = ioutil.WriteFile(fileName, jsonBytes, 0644)
+    if err != nil {
+        logger.Error("Failed to write telemetry %s, err : %s", fileName, err)
+        return err
+    }
+    return errors.OP_SUCCESS
+}
+
+func Read_telemetry(fileName string) (*OSU_telemetry, error) {
+    logger := logging.GetLoggerInstance()
+    jsonBytes, err := ioutil.ReadFile(fileName)
+    if err != nil {
+        logger.Error("Failed to read telemetry %s, err : %s", fileName, err)
+        return nil, err
+    }
+    telemetry := new(OSU_telemetry)
+    if err = json.Unmarshal(jsonBytes, telemetry); err != nil {
+        logger.Error("Invalid telemetry %s, err : %s", fileName, err)
+        return nil, err
+    }
+    return telemetry, errors.OP_SUCCESS
+}
@@ -1,41 +1,67 @@
 package testRunner
 
 import (
+    "bytes"
+    "context"
     "fmt"
+    "io"
     "time"
-    "strings"
     "os"
-    "os/exec"
+    "os/signal"
+    "path/filepath"
+    "strings"
+    "sync"
+    "syscall"
     "ec2-osu-benchmark/logging"
     "ec2-osu-benchmark/errors"
     "ec2-osu-benchmark/sys"
     "ec2-osu-benchmark/config"
 )
 
-//*****************************************************************************
-// ************ NOTE ::: MAKE SURE COMMANDS ARE IN RIGHT PATH *****************
-//*****************************************************************************
-var osu_cmds = []string {
-    "/usr/local/libexec/osu-micro-benchmarks/mpi/pt2pt/osu_latency",
-    "/usr/local/libexec/osu-micro-benchmarks/mpi/pt2pt/osu_bw",
-    "/usr/local/libexec/osu-micro-benchmarks/mpi/pt2pt/osu_bibw"}
-
 // Expecting at max of 2000 results produced at a time.
 var RESULT_CHANNEL_SIZE uint64 = 2000
 
 type osu_result_channel struct {
     resultData string
     resultFileName string
+    // Closes the result file, the job has no more output for it. The
+    // writer sends the first error of the file on done once it is closed.
+    closeFile bool
+    done chan error
+}
+
+// Result file open in the result writer.
+type osu_result_file struct {
+    fp *os.File
+    // First error writing the file
+    err error
 }
 
 
 type OSU_MPI_cmds struct {
-    mpirunCmd string
-    osu_cmds []string
+    launcher Launcher
+    executor CommandExecutor
+    osu_cmds []*OSU_benchmark
+    registry *OSU_benchmark_registry
     result_channel chan osu_result_channel
     result_channel_size uint64
-    exit_result_write bool
+    // Closes the result channel once, the writer exits after draining it
+    close_result_channel sync.Once
+    // Errors of the result writer
+    write_errors sys.ErrorCollector
     result_dir string
+    progress OSU_progress
+    configObj *config.AppConfig
+    // Hosts pairs of the pair matrix mode
+    host_pairs []*OSU_host_pair
+    // State of the campaign, loaded from the result directory on resume
+    campaign *OSU_campaign_state
+    // Root of /proc, /sys and /etc of the host
+    host_root string
+    // ID and start time of the run
+    run_info *OSU_run_info
+    // Telemetry samplers of the running jobs
+    telemetry_jobs osu_telemetry_jobs
 }
 
 //*****************************************************************************
@@ -47,6 +73,13 @@ func (chanObj *osu_result_channel)SetResultChannel(resultData string,
     chanObj.resultFileName = resultFileName
 }
 
+func (chanObj *osu_result_channel)SetResultClose(resultFileName string,
+                                                 done chan error) {
+    chanObj.resultFileName = resultFileName
+    chanObj.closeFile = true
+    chanObj.done = done
+}
+
 func (chanObj *osu_result_channel)GetResultChannelData() (string, string) {
     resultData := chanObj.resultData
     resultFileName := chanObj.resultFileName
@@ -55,95 +88,515 @@ func (chanObj *osu_result_channel)GetResultChannelData() (string, string) {
 
 //*****************************************************************************
 func (mpi_cmd_obj *OSU_MPI_cmds)IsCmdExists(execmd string) bool {
-      cmd := exec.Command("/bin/sh", "-c", "command -v "+execmd)
-      if err := cmd.Run(); err != nil {
-              return false
-      }
-      return true
+      return mpi_cmd_obj.executor.LookPath(execmd)
 }
 
-func (mpi_cmd_obj *OSU_MPI_cmds)Init_OSU_MPI_Cmds(MPIcount uint,
-                                                  hostfile string) error {
+func (mpi_cmd_obj *OSU_MPI_cmds)Init_OSU_MPI_Cmds(
+                                    configObj *config.AppConfig) error {
     var err error
     err = errors.OP_SUCCESS
     logger := logging.GetLoggerInstance()
-    if mpi_cmd_obj.IsCmdExists("mpirun") == false {
-        logger.Error("Failed to find 'mpirun' in the system")
+    if len(mpi_cmd_obj.host_root) == 0 {
+        mpi_cmd_obj.host_root = "/"
+    }
+    if mpi_cmd_obj.executor == nil {
+        mpi_cmd_obj.executor = new(System_executor)
+    }
+    if configObj.Launcher == config.LAUNCHER_AUTO {
+        mpi_cmd_obj.launcher, err = mpi_cmd_obj.Detect_launcher()
+    } else {
+        mpi_cmd_obj.launcher, err = Get_launcher(configObj.Launcher)
+    }
+    if err != errors.OP_SUCCESS {
+        logger.Error("Failed to get the MPI launcher %s", configObj.Launcher)
+        return err
+    }
+    if configObj.Launcher == config.LAUNCHER_AUTO {
+        // Mappings of an explicit launcher are checked by the config.
+        err = configObj.ValidateMapBy(mpi_cmd_obj.launcher.Name())
+        if err != errors.OP_SUCCESS {
+            logger.Error("Process mapping can't be run with the detected " +
+                         "%s launcher", mpi_cmd_obj.launcher.Name())
+            return err
+        }
+    }
+    // Every job's mapping and binding must be run by the launcher, the
+    // campaign is not started otherwise.
+    for _, mapping := range configObj.GetMapByList() {
+        for _, bind := range configObj.GetBindToList() {
+            err = mpi_cmd_obj.launcher.CheckPolicy(mapping, bind)
+            if err != errors.OP_SUCCESS {
+                fmt.Printf("%s launcher cannot map by '%s' and bind to " +
+                           "'%s'\n", mpi_cmd_obj.launcher.Name(), mapping,
+                           bind)
+                return err
+            }
+        }
+    }
+    if mpi_cmd_obj.IsCmdExists(mpi_cmd_obj.launcher.Command()) == false {
+        logger.Error("Failed to find '%s' in the system",
+                     mpi_cmd_obj.launcher.Command())
         return errors.CMD_NOT_FOUND
     }
-    mpi_cmd_obj.mpirunCmd = fmt.Sprintf("mpirun --allow-run-as-root " +
-                             "--np %d --hostfile %s",
-                             MPIcount, hostfile)
-    // TODO :: May need to allow selective command execution.
-    mpi_cmd_obj.osu_cmds = osu_cmds
+    logger.Info("Running benchmarks with %s launcher",
+                mpi_cmd_obj.launcher.Name())
+    mpi_cmd_obj.configObj = configObj
+    registry, err := Get_OSU_benchmark_registry(configObj)
+    if err != errors.OP_SUCCESS {
+        logger.Error("Failed to build the benchmark registry")
+        return err
+    }
+    mpi_cmd_obj.registry = registry
+    if configObj.PairMatrix {
+        mpi_cmd_obj.osu_cmds, err = registry.Select(PAIR_MATRIX_BENCHMARKS,
+                                                    nil)
+    } else {
+        mpi_cmd_obj.osu_cmds, err = registry.Select(configObj.Benchmarks,
+                                                configObj.ExcludeBenchmarks)
+    }
+    if err != errors.OP_SUCCESS {
+        logger.Error("Failed to select the benchmarks to run")
+        return err
+    }
     mpi_cmd_obj.result_channel_size = RESULT_CHANNEL_SIZE
     mpi_cmd_obj.result_channel = make(chan osu_result_channel, 
                                         mpi_cmd_obj.result_channel_size)
-    mpi_cmd_obj.exit_result_write = false
-    result_dir := fmt.Sprintf("%s%s/%d/", config.DEFAULT_PATH, 
-                            time.Now().Format(config.DEFAULT_TIME_LAYOUT),
-                            os.Getpid())
+    // Run info is written for new runs and older resumed campaigns.
+    writeInfo := true
+    if len(configObj.Resume) != 0 {
+        // The resumed campaign keeps writing to its result directory and
+        // keeps its run ID.
+        result_dir := filepath.Clean(configObj.Resume) + "/"
+        mpi_cmd_obj.campaign, err = Read_campaign_state(result_dir)
+        if err != errors.OP_SUCCESS {
+            return err
+        }
+        mpi_cmd_obj.run_info, err = Read_run_info(result_dir)
+        writeInfo = err != errors.OP_SUCCESS
+        if writeInfo {
+            // Campaigns from before the run IDs get one of their start.
+            mpi_cmd_obj.run_info, err = resumed_run_info(mpi_cmd_obj.campaign,
+                                                         result_dir)
+            if err != errors.OP_SUCCESS {
+                return err
+            }
+        }
+        mpi_cmd_obj.run_info.ResultDir = result_dir
+    } else {
+        mpi_cmd_obj.run_info, err = new_run_info(configObj)
+        if err != errors.OP_SUCCESS {
+            logger.Error("Failed to create the run ID, err : %s", err)
+            return err
+        }
+    }
+    result_dir := mpi_cmd_obj.run_info.ResultDir
+    mpi_cmd_obj.result_dir = result_dir
+    if configObj.PairMatrix {
+        err = mpi_cmd_obj.init_pair_matrix()
+        if err != errors.OP_SUCCESS {
+            return err
+        }
+    }
+    if len(configObj.PlanFile) != 0 {
+        err = mpi_cmd_obj.check_plan()
+        if err != errors.OP_SUCCESS {
+            return err
+        }
+    }
+    if configObj.DryRun {
+        // Nothing is created in the system in a dry run.
+        return errors.OP_SUCCESS
+    }
     err = os.MkdirAll(result_dir, os.ModePerm)
     if err != nil {
         logger.Error("Failed to create result directory\n err : %s", err)
         return err
     }
-    mpi_cmd_obj.result_dir = result_dir
+    if writeInfo {
+        err = mpi_cmd_obj.run_info.Write()
+        if err != errors.OP_SUCCESS {
+            return err
+        }
+    }
+    logger.Info("Run %s, results in %s", mpi_cmd_obj.run_info.RunID,
+                result_dir)
+    mpi_cmd_obj.progress.Init(configObj.Progress, os.Stderr,
+                              configObj.HistoryFile)
+    if configObj.PairMatrix {
+        return mpi_cmd_obj.write_pair_hostfiles()
+    }
     return errors.OP_SUCCESS
 }
 
-func (mpi_cmd_obj *OSU_MPI_cmds)get_cmd_fileName(cmd string) string{
-    execCmd := strings.Split(cmd, "/")
-    lastCmd := execCmd[len(execCmd) -1]
-    lastCmd = mpi_cmd_obj.result_dir + lastCmd + ".txt"
-    return lastCmd
+// Drop the OSU options that are not supported by the benchmark.
+func get_supported_osu_options(bench *OSU_benchmark,
+                               opts config.OSUOptions) config.OSUOptions {
+    if !bench.IsOptionSupported("-m") {
+        opts.MessageSize = ""
+    }
+    if !bench.IsOptionSupported("-i") {
+        opts.Iterations = 0
+    }
+    if !bench.IsOptionSupported("-x") {
+        opts.Warmup = 0
+    }
+    if !bench.IsOptionSupported("-f") {
+        opts.FullStats = false
+    }
+    if !bench.IsOptionSupported("-M") {
+        opts.MemLimit = 0
+    }
+    return opts
+}
+
+// OSU options of the benchmark to run with.
+func (mpi_cmd_obj *OSU_MPI_cmds)get_osu_options(
+                                    bench *OSU_benchmark) config.OSUOptions {
+    return get_supported_osu_options(bench,
+                            mpi_cmd_obj.configObj.GetOSUOptions(bench.Name))
+}
+
+// Arguments to the OSU benchmark binary.
+func (mpi_cmd_obj *OSU_MPI_cmds)get_benchmark_args(bench *OSU_benchmark) []string {
+    args := mpi_cmd_obj.get_osu_options(bench).Args()
+    if bench.Name == "osu_mbw_mr" {
+        if mpi_cmd_obj.configObj.Pairs != 0 {
+            args = append(args, "-p",
+                          fmt.Sprint(mpi_cmd_obj.configObj.Pairs))
+        }
+        if mpi_cmd_obj.configObj.WindowSize != 0 {
+            args = append(args, "-W",
+                          fmt.Sprint(mpi_cmd_obj.configObj.WindowSize))
+        }
+    }
+    if bench.Category == OSU_CATEGORY_ONE_SIDED {
+        if len(mpi_cmd_obj.configObj.RMAWindow) != 0 {
+            args = append(args, "-w", mpi_cmd_obj.configObj.RMAWindow)
+        }
+        if len(mpi_cmd_obj.configObj.RMASync) != 0 {
+            args = append(args, "-s", mpi_cmd_obj.configObj.RMASync)
+        }
+    }
+    return args
+}
+
+// Launch spec to run the job.
+func (mpi_cmd_obj *OSU_MPI_cmds)get_launch_spec(
+                                    job *OSU_job) *OSU_launch_spec {
+    bench := job.Bench
+    spec := new(OSU_launch_spec)
+    spec.NumProcs = job.NP
+    spec.HostFile = mpi_cmd_obj.configObj.HostFile
+    if job.Slice != nil {
+        spec.HostFile = job.Slice.HostFile
+    } else if job.Pair != nil {
+        spec.HostFile = job.Pair.HostFile
+    }
+    spec.PPN = job.PPN
+    spec.MapBy = job.MapBy
+    spec.BindTo = job.BindTo
+    // The binding report verifies that the policy is in effect.
+    spec.ReportBindings = len(job.MapBy) != 0 || len(job.BindTo) != 0
+    spec.Env = mpi_cmd_obj.configObj.GetVariantEnv(bench.Name, job.Variant)
+    spec.ExtraArgs = mpi_cmd_obj.configObj.GetLauncherArgs(bench.Name)
+    spec.Binary = bench.Path
+    spec.Args = mpi_cmd_obj.get_benchmark_args(bench)
+    return spec
+}
+
+// Commandline of a job and its run record, without the run results.
+func (mpi_cmd_obj *OSU_MPI_cmds)prepare_job(
+                            job *OSU_job) ([]string, *OSU_run_record, error) {
+    logger := logging.GetLoggerInstance()
+    bench := job.Bench
+    spec := mpi_cmd_obj.get_launch_spec(job)
+    argv, err := mpi_cmd_obj.launcher.BuildCmd(spec)
+    if err != errors.OP_SUCCESS {
+        logger.Error("Failed to build %s command for %s",
+                     mpi_cmd_obj.launcher.Name(), bench.Name)
+        return nil, nil, err
+    }
+    record := new(OSU_run_record)
+    record.Benchmark = bench.Name
+    record.Category = bench.Category
+    record.Repetition = job.Repetition
+    record.NP = job.NP
+    record.PPN = job.PPN
+    record.MapBy = job.MapBy
+    record.BindTo = job.BindTo
+    record.Variant = job.Variant
+    record.Primary = job.Primary
+    if job.Pair != nil {
+        record.Hosts = job.Pair.Hosts
+    }
+    if job.Slice != nil {
+        for _, host := range job.Slice.Hosts {
+            record.HostSlice = append(record.HostSlice, host.Name)
+        }
+    }
+    record.Launcher = mpi_cmd_obj.launcher.Name()
+    record.Command = Format_cmdline(argv)
+    record.LauncherArgs = spec.ExtraArgs
+    record.Env = spec.Env
+    record.Options = mpi_cmd_obj.get_osu_options(bench)
+    record.StdoutFile = mpi_cmd_obj.get_job_fileName(job, ".txt")
+    record.StderrFile = mpi_cmd_obj.get_job_fileName(job, ".stderr")
+    record.Timeout = mpi_cmd_obj.configObj.GetTimeout(bench.Name).String()
+    return argv, record, errors.OP_SUCCESS
+}
+
+// Run a single job, the output is streamed to the result writer line by
+// line and the run record is written once the job is done. idx is the
+// index of the job in the progress of the campaign.
+func (mpi_cmd_obj *OSU_MPI_cmds)run_job(ctx context.Context, idx int,
+                                        job *OSU_job) error {
+    var err error
+    var argv []string
+    var status *OSU_exec_status
+    logger := logging.GetLoggerInstance()
+    bench := job.Bench
+
+    if mpi_cmd_obj.IsCmdExists(bench.Path) == false {
+        //Cannot find the command in the system.
+        logger.Error("Failed to run command %s, as its not found", bench.Path)
+        mpi_cmd_obj.progress.Skip_job(idx)
+        return errors.CMD_NOT_FOUND
+    }
+    argv, record, err := mpi_cmd_obj.prepare_job(job)
+    if err != errors.OP_SUCCESS {
+        mpi_cmd_obj.progress.Skip_job(idx)
+        return err
+    }
+    mpi_cmd_obj.progress.Start_job(idx, job.Name())
+    run_cmd := record.Command
+
+    cancel := context.CancelFunc(func() {})
+    if timeout := mpi_cmd_obj.configObj.GetTimeout(bench.Name); timeout > 0 {
+        ctx, cancel = context.WithTimeout(ctx, timeout)
+    }
+    logger.Info(" *** Running test command %s ***\n", run_cmd)
+    // Launchers print the binding report to stdout or stderr.
+    var bindings_mutex sync.Mutex
+    record_binding := func(line string) {
+        if mpi_cmd_obj.launcher.IsBindingReport(line) {
+            bindings_mutex.Lock()
+            record.Bindings = append(record.Bindings, strings.TrimSpace(line))
+            bindings_mutex.Unlock()
+        }
+    }
+    // Rows reach the result file as they are printed, a crash or an
+    // interruption keeps everything up to the last complete row.
+    stdout := mpi_cmd_obj.new_result_stream(record.StdoutFile,
+                                            func(line string) {
+        mpi_cmd_obj.progress.Line(idx, line)
+        record_binding(line)
+    })
+    var stderr bytes.Buffer
+    stderrStream := mpi_cmd_obj.new_result_stream(record.StderrFile,
+                                                  record_binding)
+    var sampler *OSU_telemetry_sampler
+    if mpi_cmd_obj.configObj.Telemetry > 0 {
+        sampler = new_telemetry_sampler(mpi_cmd_obj.configObj.Telemetry,
+                                        mpi_cmd_obj.host_root)
+        mpi_cmd_obj.telemetry_jobs.Start(sampler, job.Name())
+    }
+    record.StartTime = time.Now()
+    status, err = mpi_cmd_obj.executor.Run(ctx, argv, stdout,
+                              io.MultiWriter(stderrStream, &stderr))
+    record.EndTime = time.Now()
+    if sampler != nil {
+        telemetryFile := mpi_cmd_obj.get_job_fileName(job,
+                                                      TELEMETRY_FILE_SUFFIX)
+        telemetry := mpi_cmd_obj.telemetry_jobs.Stop(sampler)
+        if telemetry.Write(telemetryFile) == errors.OP_SUCCESS {
+            record.Telemetry = sampler.Summary()
+            record.Telemetry.File = telemetryFile
+        }
+    }
+    cancel()
+    // The job is checkpointed only after its output is in the result files.
+    writeErr := stdout.Close()
+    if closeErr := stderrStream.Close(); writeErr == errors.OP_SUCCESS {
+        writeErr = closeErr
+    }
+    record.Duration = record.EndTime.Sub(record.StartTime).Seconds()
+    if status != nil {
+        record.ExitCode = status.ExitCode
+        record.Signal = status.Signal
+    }
+    record.Status = RUN_STATUS_SUCCESS
+    if status != nil && status.TimedOut {
+        record.Status = RUN_STATUS_TIMEOUT
+        logger.Error("Test %s timed out after %s, killed\n", job.Name(),
+                     record.Timeout)
+    } else if status != nil && status.Interrupted {
+        record.Status = RUN_STATUS_INTERRUPTED
+        logger.Warning("Test %s is interrupted, killed\n", job.Name())
+    } else if err != errors.OP_SUCCESS {
+        record.Status = RUN_STATUS_FAILED
+        logger.Error("Failed to run test : %s, err : %s, stderr : %s\n",
+                     run_cmd, err, stderr.String())
+    }
+    mpi_cmd_obj.progress.End_job(idx, record.Status, record.Duration)
+    if writeErr != errors.OP_SUCCESS {
+        // A resumed campaign runs it again.
+        logger.Error("Failed to write the output of %s, err : %s",
+                     job.Name(), writeErr)
+        return writeErr
+    }
+    // Output of a failed/timed out test is kept as well for diagnosis.
+    recordErr := Write_run_record(record,
+                     mpi_cmd_obj.get_job_fileName(job, RUN_RECORD_FILE_SUFFIX))
+    if recordErr != errors.OP_SUCCESS {
+        return recordErr
+    }
+    mpi_cmd_obj.campaign.End_job(job, record.Status)
+    return err
 }
 
+// Run all the jobs of the campaign. SIGINT/SIGTERM kills the running
+// benchmark and stops the campaign, the results so far are kept. The
+// campaign state records every job that is done, a resumed campaign runs
+// only the rest.
 func (mpi_cmd_obj *OSU_MPI_cmds)Run_OSU_MPI_Cmds() error {
     var err error
-    var res []byte
     err = errors.OP_SUCCESS
     logger := logging.GetLoggerInstance()
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt,
+                                      syscall.SIGTERM)
+    defer stop()
 
-    for _, cmd := range osu_cmds {
-        if mpi_cmd_obj.IsCmdExists(cmd) == false {
-            //Cannot find the command in the system.
-            logger.Error("Failed to run command %s, as its not found", cmd)
-            continue
+    resumed := mpi_cmd_obj.campaign != nil
+    jobs := mpi_cmd_obj.build_jobs()
+    if !resumed {
+        // The plan to reproduce the run with -plan.
+        plan, err := mpi_cmd_obj.expand_plan(jobs)
+        if err == errors.OP_SUCCESS {
+            err = plan.Write(mpi_cmd_obj.result_dir + config.PLAN_FILE)
         }
-        run_cmd := fmt.Sprintf("%s %s", mpi_cmd_obj.mpirunCmd, cmd)
-        logger.Info(" *** Running test command %s ***\n", run_cmd)
-        res, err = exec.Command("sh","-c", run_cmd).Output()
-        if err != nil {
-            logger.Error("Failed to run test : %s, err : %s\n", run_cmd, err)
-             // Continue with next test set
-             continue
+        if err != errors.OP_SUCCESS {
+            logger.Error("Failed to write the plan of the campaign")
+            return err
+        }
+        // Environment of the run, a resumed campaign keeps the manifest
+        // of its start.
+        err = mpi_cmd_obj.Get_manifest().Write(mpi_cmd_obj.result_dir +
+                                               config.MANIFEST_FILE)
+        if err != errors.OP_SUCCESS {
+            logger.Error("Failed to write the manifest of the campaign")
+            return err
+        }
+    }
+    err = mpi_cmd_obj.start_campaign(jobs)
+    if err != errors.OP_SUCCESS {
+        logger.Error("Failed to start the campaign in %s",
+                     mpi_cmd_obj.result_dir)
+        return err
+    }
+    if resumed {
+        pending := mpi_cmd_obj.get_pending_jobs(jobs)
+        logger.Info("Resuming the campaign in %s, %d of %d jobs are done",
+                    mpi_cmd_obj.result_dir, len(jobs) - len(pending),
+                    len(jobs))
+        jobs = pending
+    }
+    keys := make([]string, len(jobs))
+    for idx, job := range jobs {
+        keys[idx] = mpi_cmd_obj.get_job_history_key(job)
+    }
+    parallel := int(mpi_cmd_obj.configObj.Parallel)
+    if parallel < 1 {
+        parallel = 1
+    }
+    mpi_cmd_obj.progress.Start_campaign(keys, parallel)
+    if resumed {
+        // Output of the runs that were interrupted
+        for _, job := range jobs {
+            mpi_cmd_obj.remove_job_files(job)
+        }
+    }
+    if parallel > 1 {
+        err = mpi_cmd_obj.run_parallel(ctx, jobs, parallel)
+        if ctx.Err() != nil {
+            logger.Warning("Benchmarks are interrupted, continue with " +
+                           "-resume %s", mpi_cmd_obj.result_dir)
+            mpi_cmd_obj.campaign.End(CAMPAIGN_STATUS_INTERRUPTED)
+            return errors.INVALID_OP
+        }
+        mpi_cmd_obj.campaign.End(CAMPAIGN_STATUS_COMPLETED)
+        return err
+    }
+    for idx, job := range jobs {
+        // Continue with next test set on failures, the first failure is
+        // returned.
+        jobErr := mpi_cmd_obj.run_job(ctx, idx, job)
+        if err == errors.OP_SUCCESS {
+            err = jobErr
+        }
+        if ctx.Err() != nil {
+            logger.Warning("Benchmarks are interrupted, %d of %d jobs are " +
+                           "run, continue with -resume %s", idx + 1,
+                           len(jobs), mpi_cmd_obj.result_dir)
+            mpi_cmd_obj.campaign.End(CAMPAIGN_STATUS_INTERRUPTED)
+            return errors.INVALID_OP
         }
-        cmdFileName := mpi_cmd_obj.get_cmd_fileName(cmd)
-        var res_channel osu_result_channel
-        res_channel.SetResultChannel(string(res), cmdFileName)
-        // Push to the channel for write go-routine
-        mpi_cmd_obj.result_channel <- res_channel
     }
+    mpi_cmd_obj.campaign.End(CAMPAIGN_STATUS_COMPLETED)
     return err
 }
 
-func (mpi_cmd_obj *OSU_MPI_cmds)write_to_file(result *osu_result_channel) error{
+// Write a result to its file. The files are kept open by the writer till
+// the job closes them, files is the open files of the writer. Results of a
+// file are dropped after its first error.
+func (mpi_cmd_obj *OSU_MPI_cmds)write_to_file(result *osu_result_channel,
+                                files map[string]*osu_result_file) error {
     var resultData, resultFileName string
+    var err error
     logger := logging.GetLoggerInstance()
     resultData, resultFileName = result.GetResultChannelData()
-    fp, err := os.OpenFile(resultFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-                           0644)
-    if err != nil {
-        logger.Error("Failed to create result file %s", resultFileName)
+    file, ok := files[resultFileName]
+    if result.closeFile {
+        fileErr := errors.OP_SUCCESS
+        err = errors.OP_SUCCESS
+        if ok {
+            delete(files, resultFileName)
+            fileErr = file.err
+            if file.fp != nil {
+                if closeErr := file.fp.Close(); closeErr != nil {
+                    logger.Error("Failed to close result file %s",
+                                 resultFileName)
+                    err = closeErr
+                    if fileErr == errors.OP_SUCCESS {
+                        fileErr = closeErr
+                    }
+                }
+            }
+        }
+        result.done <- fileErr
         return err
     }
-    defer fp.Close()
-    _, err = fp.Write([]byte(resultData))
+    if !ok {
+        file = new(osu_result_file)
+        file.err = errors.OP_SUCCESS
+        files[resultFileName] = file
+        file.fp, err = os.OpenFile(resultFileName,
+                                   os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+        if err != nil {
+            logger.Error("Failed to create result file %s", resultFileName)
+            file.fp = nil
+            file.err = err
+            return err
+        }
+    }
+    if file.err != errors.OP_SUCCESS {
+        return errors.OP_SUCCESS
+    }
+    _, err = file.fp.Write([]byte(resultData))
     if err != nil {
         logger.Error("Failed to write results to file %s", resultFileName)
+        file.err = err
+        return err
     }
-
     return errors.OP_SUCCESS
 }
 
@@ -151,21 +604,72 @@ func (mpi_cmd_obj *OSU_MPI_cmds)Get_OSU_MPI_test_result_path() string {
     return mpi_cmd_obj.result_dir
 }
 
-// Go routine to read command output and write to file stream.
-func (mpi_cmd_obj *OSU_MPI_cmds)WriteCommandOutput() {
-    for mpi_cmd_obj.exit_result_write == false {
-        select {
-            case osu_result := <- mpi_cmd_obj.result_channel:
-                mpi_cmd_obj.write_to_file(&osu_result)
-            default:
-                // Do nothing
+// Remove the result directory of a run that is not started, along with the
+// directories of the result layout it leaves empty. A resumed campaign
+// keeps its result directory.
+func (mpi_cmd_obj *OSU_MPI_cmds)Remove_result_dir() error {
+    logger := logging.GetLoggerInstance()
+    if len(mpi_cmd_obj.configObj.Resume) != 0 ||
+       len(mpi_cmd_obj.result_dir) == 0 {
+        return errors.OP_SUCCESS
+    }
+    err := os.RemoveAll(mpi_cmd_obj.result_dir)
+    if err != nil {
+        logger.Error("Failed to remove result directory %s, err : %s",
+                     mpi_cmd_obj.result_dir, err)
+        return err
+    }
+    root := mpi_cmd_obj.configObj.ResultsRoot
+    if len(root) == 0 {
+        root = config.DEFAULT_PATH
+    }
+    root = filepath.Clean(root)
+    dir := filepath.Dir(filepath.Clean(mpi_cmd_obj.result_dir))
+    for strings.HasPrefix(dir, root + string(filepath.Separator)) {
+        // Stops at the first directory with other runs in it.
+        if os.Remove(dir) != nil {
+            break
         }
+        dir = filepath.Dir(dir)
     }
+    return errors.OP_SUCCESS
+}
+
+// Go routine to read command output and write to file stream. It blocks
+// on the result channel and returns only after the channel is closed and
+// everything buffered in it is written.
+func (mpi_cmd_obj *OSU_MPI_cmds)WriteCommandOutput() {
     // While exiting, make sure to mark the go-routine exit in sync
     syncObj := sys.GetAppSyncObj()
-    syncObj.ExitRoutineInWaitGroup() 
+    defer syncObj.ExitRoutineInWaitGroup()
+    files := make(map[string]*osu_result_file)
+    for osu_result := range mpi_cmd_obj.result_channel {
+        err := mpi_cmd_obj.write_to_file(&osu_result, files)
+        mpi_cmd_obj.write_errors.Add(err)
+    }
+    // Files of the jobs that didn't close them
+    for resultFileName, file := range files {
+        if file.fp == nil {
+            continue
+        }
+        if err := file.fp.Close(); err != nil {
+            logger := logging.GetLoggerInstance()
+            logger.Error("Failed to close result file %s", resultFileName)
+            mpi_cmd_obj.write_errors.Add(err)
+        }
+    }
 }
 
+// Stop the result writer, no results can be pushed after it. The writer
+// drains the pending results before it exits, join the go-routines to
+// wait for it.
 func (mpi_cmd_obj *OSU_MPI_cmds)ExitresultWriteRoutine() {
-    mpi_cmd_obj.exit_result_write = true
+    mpi_cmd_obj.close_result_channel.Do(func() {
+        close(mpi_cmd_obj.result_channel)
+    })
+}
+
+// Errors of the result writer, valid after the writer has exited.
+func (mpi_cmd_obj *OSU_MPI_cmds)Get_result_write_error() error {
+    return mpi_cmd_obj.write_errors.Err()
 }
@@ -0,0 +1,652 @@
+package testRunner_test
+
+import (
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+    "ec2-osu-benchmark/config"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/simulator"
+    "ec2-osu-benchmark/sys"
+    "ec2-osu-benchmark/testRunner"
+)
+
+func testConfig(t *testing.T) *config.AppConfig {
+    t.Helper()
+    configObj := new(config.AppConfig)
+    configObj.MPIcount = 2
+    configObj.HostFile = filepath.Join(t.TempDir(), "hostfile")
+    err := ioutil.WriteFile(configObj.HostFile, []byte("h1\nh2\nh3\n"), 0644)
+    if err != nil {
+        t.Fatal(err)
+    }
+    configObj.Launcher = config.LAUNCHER_AUTO
+    configObj.Repeat = 1
+    configObj.ResultsRoot = t.TempDir()
+    return configObj
+}
+
+// Run the campaign of the configuration in the simulator, the results
+// root of the configuration is removed at the end of the test.
+func runCampaign(t *testing.T, configObj *config.AppConfig,
+                 simConfig *simulator.Config) string {
+    t.Helper()
+    resultDir, _ := runCampaignErr(t, configObj, simConfig)
+    return resultDir
+}
+
+// Run the campaign like runCampaign, with the error of the run.
+func runCampaignErr(t *testing.T, configObj *config.AppConfig,
+                    simConfig *simulator.Config) (string, error) {
+    t.Helper()
+    mpi_cmd_obj := new(testRunner.OSU_MPI_cmds)
+    mpi_cmd_obj.Set_command_executor(simulator.New_executor(simConfig))
+    err := mpi_cmd_obj.Init_OSU_MPI_Cmds(configObj)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Init_OSU_MPI_Cmds failed, err : %s", err)
+    }
+    resultDir := mpi_cmd_obj.Get_OSU_MPI_test_result_path()
+    syncObj := sys.GetAppSyncObj()
+    syncObj.AddRoutineInWaitGroup()
+    go mpi_cmd_obj.WriteCommandOutput()
+    runErr := mpi_cmd_obj.Run_OSU_MPI_Cmds()
+    mpi_cmd_obj.ExitresultWriteRoutine()
+    syncObj.JoinAllRoutines()
+    if err = mpi_cmd_obj.Get_result_write_error(); err != errors.OP_SUCCESS {
+        t.Fatalf("Failed to write results, err : %s", err)
+    }
+    return resultDir, runErr
+}
+
+func readRecords(t *testing.T,
+                 resultDir string) map[string]*testRunner.OSU_run_record {
+    t.Helper()
+    records, err := testRunner.Read_run_records(resultDir)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Read_run_records failed, err : %s", err)
+    }
+    byName := make(map[string]*testRunner.OSU_run_record)
+    for _, record := range records {
+        byName[filepath.Base(record.StdoutFile)] = record
+    }
+    return byName
+}
+
+func TestRunRepeatedSweep(t *testing.T) {
+    configObj := testConfig(t)
+    configObj.Benchmarks = []string{"osu_latency", "osu_allreduce"}
+    configObj.NPSweep = []uint{2, 4}
+    configObj.Repeat = 2
+    resultDir := runCampaign(t, configObj, simulator.Default_config())
+    records := readRecords(t, resultDir)
+    if len(records) != 8 {
+        t.Fatalf("expected 8 run records, got %d", len(records))
+    }
+    record, ok := records["osu_allreduce.np4.rep2.txt"]
+    if !ok {
+        t.Fatalf("missing osu_allreduce.np4.rep2 record")
+    }
+    if record.Status != testRunner.RUN_STATUS_SUCCESS || record.NP != 4 ||
+       !strings.Contains(record.Command, "--np 4") {
+        t.Errorf("unexpected record %+v", record)
+    }
+    output, err := ioutil.ReadFile(record.StdoutFile)
+    if err != nil || !strings.Contains(string(output), "Allreduce") {
+        t.Errorf("unexpected result file, err : %v\n%s", err, output)
+    }
+    if !records["osu_latency.np2.rep1.txt"].Primary ||
+       records["osu_latency.np4.rep1.txt"].Primary {
+        t.Errorf("only the first configuration is primary")
+    }
+}
+
+func TestRunFailureAndTimeout(t *testing.T) {
+    configObj := testConfig(t)
+    configObj.Benchmarks = []string{"osu_latency", "osu_bw", "osu_bibw"}
+    configObj.BenchTimeout = map[string]time.Duration{
+        "osu_bibw": 200 * time.Millisecond}
+    simConfig := simulator.Default_config()
+    simConfig.Fail = []string{"osu_bw"}
+    simConfig.Hang = []string{"osu_bibw"}
+    resultDir, err := runCampaignErr(t, configObj, simConfig)
+    // The first failure is returned, not the timeout after it.
+    if err == errors.OP_SUCCESS || !strings.Contains(err.Error(), "osu_bw") {
+        t.Errorf("campaign returned %v, want the failure of osu_bw", err)
+    }
+    records := readRecords(t, resultDir)
+    want := map[string]string{
+        "osu_latency.txt": testRunner.RUN_STATUS_SUCCESS,
+        "osu_bw.txt": testRunner.RUN_STATUS_FAILED,
+        "osu_bibw.txt": testRunner.RUN_STATUS_TIMEOUT}
+    for name, status := range want {
+        record, ok := records[name]
+        if !ok {
+            t.Errorf("missing record of %s", name)
+            continue
+        }
+        if record.Status != status {
+            t.Errorf("%s status = %s, want %s", name, record.Status, status)
+        }
+    }
+    stderr, _ := ioutil.ReadFile(records["osu_bw.txt"].StderrFile)
+    if !strings.Contains(string(stderr), "simulated failure") {
+        t.Errorf("stderr of the failed run is not kept : %q", stderr)
+    }
+}
+
+func TestPairMatrixJobs(t *testing.T) {
+    configObj := testConfig(t)
+    configObj.PairMatrix = true
+    records := readRecords(t, runCampaign(t, configObj,
+                                          simulator.Default_config()))
+    // 3 hosts, 3 pairs, osu_latency and osu_bw
+    if len(records) != 6 {
+        t.Fatalf("expected 6 run records, got %d", len(records))
+    }
+    record := records["osu_bw.pair1-2.txt"]
+    if record == nil || strings.Join(record.Hosts, ",") != "h2,h3" {
+        t.Errorf("unexpected pair record %+v", record)
+    }
+}
+
+// Hosts of the runs that overlap in time are disjoint.
+func checkDisjointRuns(t *testing.T,
+                       records map[string]*testRunner.OSU_run_record,
+                       hosts func(*testRunner.OSU_run_record) []string) int {
+    t.Helper()
+    overlaps := 0
+    for name, record := range records {
+        for otherName, other := range records {
+            if name >= otherName ||
+               !record.StartTime.Before(other.EndTime) ||
+               !other.StartTime.Before(record.EndTime) {
+                continue
+            }
+            overlaps++
+            for _, host := range hosts(record) {
+                for _, otherHost := range hosts(other) {
+                    if host == otherHost {
+                        t.Errorf("%s and %s run on %s at the same time",
+                                 name, otherName, host)
+                    }
+                }
+            }
+        }
+    }
+    return overlaps
+}
+
+func TestRunParallel(t *testing.T) {
+    simConfig := simulator.Default_config()
+    simConfig.RowDelay = time.Millisecond
+    // 4 hosts, 6 pairs of osu_latency and osu_bw, 2 disjoint pairs at a time
+    configObj := testConfig(t)
+    ioutil.WriteFile(configObj.HostFile, []byte("h1\nh2\nh3\nh4\n"), 0644)
+    configObj.PairMatrix = true
+    configObj.Parallel = 2
+    records := readRecords(t, runCampaign(t, configObj, simConfig))
+    if len(records) != 12 {
+        t.Fatalf("expected 12 run records, got %d", len(records))
+    }
+    overlaps := checkDisjointRuns(t, records,
+        func(record *testRunner.OSU_run_record) []string {
+            return record.Hosts
+        })
+    if overlaps == 0 {
+        t.Errorf("pairs are not run in parallel")
+    }
+
+    // A host for every np=2 ppn=2 job, 3 jobs at a time
+    configObj = testConfig(t)
+    configObj.Benchmarks = []string{"osu_latency", "osu_bw", "osu_bibw"}
+    configObj.PPN = 2
+    configObj.Repeat = 2
+    configObj.Parallel = 3
+    resultDir := runCampaign(t, configObj, simConfig)
+    records = readRecords(t, resultDir)
+    if len(records) != 6 {
+        t.Fatalf("expected 6 run records, got %d", len(records))
+    }
+    for name, record := range records {
+        if record.Status != testRunner.RUN_STATUS_SUCCESS ||
+           len(record.HostSlice) != 1 {
+            t.Errorf("unexpected record of %s %+v", name, record)
+        }
+    }
+    checkDisjointRuns(t, records,
+        func(record *testRunner.OSU_run_record) []string {
+            return record.HostSlice
+        })
+    hostfile, err := ioutil.ReadFile(filepath.Join(resultDir, "hosts",
+                                                   "osu_bw.rep1.hostfile"))
+    if err != nil || len(strings.Fields(string(hostfile))) == 0 {
+        t.Errorf("unexpected hostfile of the job %q, err : %v", hostfile, err)
+    }
+}
+
+func TestRunPolicySweep(t *testing.T) {
+    for _, launcher := range []string{"openmpi", "intel"} {
+        configObj := testConfig(t)
+        configObj.Launcher = launcher
+        configObj.Benchmarks = []string{"osu_latency"}
+        configObj.MapBySweep = []string{"core", "socket"}
+        configObj.BindTo = "core"
+        simConfig := simulator.Default_config()
+        simConfig.Launcher = launcher
+        records := readRecords(t, runCampaign(t, configObj, simConfig))
+        if len(records) != 2 {
+            t.Fatalf("%s : expected 2 run records, got %d", launcher,
+                     len(records))
+        }
+        record := records["osu_latency.mapsocket.bindcore.txt"]
+        if record == nil || record.MapBy != "socket" ||
+           record.BindTo != "core" {
+            t.Fatalf("%s : unexpected record %+v", launcher, record)
+        }
+        // a line per rank and the header of the Intel MPI pinning table
+        if len(record.Bindings) < 2 {
+            t.Errorf("%s : binding report is not recorded, %v", launcher,
+                     record.Bindings)
+        }
+    }
+}
+
+func TestResumeCampaign(t *testing.T) {
+    configObj := testConfig(t)
+    configObj.Benchmarks = []string{"osu_latency", "osu_bw"}
+    configObj.Repeat = 2
+    resultDir := runCampaign(t, configObj, simulator.Default_config())
+    before := readRecords(t, resultDir)
+    info, err := testRunner.Read_run_info(resultDir)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Read_run_info failed, err : %s", err)
+    }
+    // Interrupt the campaign in osu_latency.rep2, osu_bw.rep2 not run.
+    // osu_bw.rep1 failed, ex: a host dropped its ssh connection.
+    state, err := testRunner.Read_campaign_state(resultDir)
+    if err != errors.OP_SUCCESS || len(state.Jobs) != 4 {
+        t.Fatalf("unexpected campaign state %+v, err : %v", state, err)
+    }
+    for _, campaignJob := range state.Jobs {
+        switch campaignJob.Name {
+        case "osu_bw.rep1":
+            campaignJob.Status = testRunner.RUN_STATUS_FAILED
+        case "osu_latency.rep2":
+            campaignJob.Status = testRunner.RUN_STATUS_INTERRUPTED
+            ioutil.WriteFile(filepath.Join(resultDir, "osu_latency.rep2.txt"),
+                             []byte("# partial\n1 2.0\n"), 0644)
+        case "osu_bw.rep2":
+            campaignJob.Status = ""
+            os.Remove(filepath.Join(resultDir, "osu_bw.rep2.txt"))
+            os.Remove(filepath.Join(resultDir, "osu_bw.rep2.meta.json"))
+        }
+    }
+    state.End(testRunner.CAMPAIGN_STATUS_INTERRUPTED)
+
+    resumeConfig := *configObj
+    resumeConfig.Resume = resultDir
+    if dir := runCampaign(t, &resumeConfig, simulator.Default_config());
+       dir != resultDir {
+        t.Fatalf("resumed in %s, want %s", dir, resultDir)
+    }
+    after := readRecords(t, resultDir)
+    if len(after) != 4 {
+        t.Fatalf("expected 4 run records, got %d", len(after))
+    }
+    if !after["osu_latency.rep1.txt"].StartTime.Equal(
+            before["osu_latency.rep1.txt"].StartTime) {
+        t.Errorf("osu_latency.rep1 is run again")
+    }
+    if after["osu_bw.rep1.txt"].StartTime.Equal(
+           before["osu_bw.rep1.txt"].StartTime) {
+        t.Errorf("failed osu_bw.rep1 is not run again")
+    }
+    output, _ := ioutil.ReadFile(after["osu_latency.rep2.txt"].StdoutFile)
+    if strings.Contains(string(output), "partial") {
+        t.Errorf("output of the interrupted run is kept :\n%s", output)
+    }
+    state, _ = testRunner.Read_campaign_state(resultDir)
+    if state.Status != testRunner.CAMPAIGN_STATUS_COMPLETED ||
+       len(state.ResumeTimes) != 1 {
+        t.Errorf("unexpected campaign state %+v", state)
+    }
+    resumed, _ := testRunner.Read_run_info(resultDir)
+    if resumed == nil || resumed.RunID != info.RunID {
+        t.Errorf("resumed run info %+v, want run %s", resumed, info.RunID)
+    }
+}
+
+// A job is checkpointed only after its output is written, and not at all
+// when its run record can't be written.
+func TestCheckpointAfterOutput(t *testing.T) {
+    configObj := testConfig(t)
+    configObj.Benchmarks = []string{"osu_latency", "osu_bw"}
+    mpi_cmd_obj := new(testRunner.OSU_MPI_cmds)
+    mpi_cmd_obj.Set_command_executor(
+        simulator.New_executor(simulator.Default_config()))
+    err := mpi_cmd_obj.Init_OSU_MPI_Cmds(configObj)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Init_OSU_MPI_Cmds failed, err : %s", err)
+    }
+    resultDir := mpi_cmd_obj.Get_OSU_MPI_test_result_path()
+    // The run record of osu_bw can't be written over a directory.
+    err = os.Mkdir(filepath.Join(resultDir, "osu_bw.meta.json"), 0755)
+    if err != nil {
+        t.Fatal(err)
+    }
+    syncObj := sys.GetAppSyncObj()
+    syncObj.AddRoutineInWaitGroup()
+    go mpi_cmd_obj.WriteCommandOutput()
+    mpi_cmd_obj.Run_OSU_MPI_Cmds()
+    // Output of the checkpointed jobs, while the writer still runs
+    state, err := testRunner.Read_campaign_state(resultDir)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Read_campaign_state failed, err : %s", err)
+    }
+    outputs := make(map[string][]byte)
+    for _, campaignJob := range state.Jobs {
+        if campaignJob.Status == testRunner.RUN_STATUS_SUCCESS {
+            outputs[campaignJob.Name], _ = ioutil.ReadFile(
+                filepath.Join(resultDir, campaignJob.Name + ".txt"))
+        }
+    }
+    mpi_cmd_obj.ExitresultWriteRoutine()
+    syncObj.JoinAllRoutines()
+    if len(outputs) != 1 || outputs["osu_latency"] == nil {
+        t.Fatalf("expected only osu_latency to be checkpointed, got %d " +
+                 "jobs", len(outputs))
+    }
+    for name, output := range outputs {
+        final, _ := ioutil.ReadFile(filepath.Join(resultDir, name + ".txt"))
+        if len(output) == 0 || string(output) != string(final) {
+            t.Errorf("%s is checkpointed with %d of %d bytes of output",
+                     name, len(output), len(final))
+        }
+    }
+}
+
+func TestRunID(t *testing.T) {
+    start := time.Date(2026, 10, 18, 9, 30, 0, 123e6, time.UTC)
+    ids := make([]string, 0)
+    for _, offset := range []time.Duration{0, time.Millisecond, time.Hour} {
+        id, err := testRunner.New_run_id(start.Add(offset))
+        if err != errors.OP_SUCCESS || len(id) != testRunner.RUN_ID_LENGTH {
+            t.Fatalf("New_run_id returned %q, err : %v", id, err)
+        }
+        idTime, err := testRunner.Get_run_id_time(id)
+        if err != errors.OP_SUCCESS || !idTime.Equal(start.Add(offset)) {
+            t.Errorf("time of %s is %s, want %s", id, idTime,
+                     start.Add(offset))
+        }
+        ids = append(ids, id)
+    }
+    for idx := 1; idx < len(ids); idx++ {
+        if ids[idx - 1] >= ids[idx] {
+            t.Errorf("run ID %s doesn't sort before %s", ids[idx - 1],
+                     ids[idx])
+        }
+    }
+    if _, err := testRunner.Get_run_id_time("01ARZ3NDEKTSV4RRFFQ69G5FAU");
+       err == errors.OP_SUCCESS {
+        t.Errorf("run ID with 'U' is expected to fail")
+    }
+
+    configObj := testConfig(t)
+    configObj.Benchmarks = []string{"osu_latency"}
+    configObj.ResultLayout = "runs/{runid}"
+    resultDir := runCampaign(t, configObj, simulator.Default_config())
+    info, err := testRunner.Read_run_info(resultDir)
+    if err != errors.OP_SUCCESS {
+        t.Fatalf("Read_run_info failed, err : %s", err)
+    }
+    want := filepath.Join(configObj.ResultsRoot, "runs", info.RunID) + "/"
+    if resultDir != want || info.ResultDir != want {
+        t.Errorf("result directory is %s, want %s", resultDir, want)
+    }
+    idTime, _ := testRunner.Get_run_id_time(info.RunID)
+    if !idTime.Equal(info.StartTime.Truncate(time.Millisecond)) {
+        t.Errorf("run ID %s is not of the start %s", info.RunID,
+                 info.StartTime)
+    }
+}
+
+func TestRunVariantsAndPlan(t *testing.T) {
+    configObj := testConfig(t)
+    configObj.Benchmarks = []string{"osu_latency", "osu_bw"}
+    configObj.EnvVariants = []config.EnvVariant{
+        {Name: "efa", Env: []string{"FI_PROVIDER=efa"}},
+        {Name: "tcp", Env: []string{"FI_PROVIDER=tcp"}}}
+    configObj.ExcludeJobs = []config.JobFilter{
+        {Benchmark: "osu_bw", Variant: "tcp"}}
+    resultDir := runCampaign(t, configObj, simulator.Default_config())
+    records := readRecords(t, resultDir)
+    if len(records) != 3 || records["osu_bw.envtcp.txt"] != nil {
+        t.Fatalf("unexpected run records %v", records)
+    }
+    record := records["osu_latency.envtcp.txt"]
+    if record == nil || record.Variant != "tcp" ||
+       !strings.Contains(record.Command, "-x FI_PROVIDER=tcp") {
+        t.Fatalf("unexpected record %+v", record)
+    }
+    // The same configuration reproduces the plan, another one does not.
+    planConfig := *configObj
+    planConfig.PlanFile = filepath.Join(resultDir, config.PLAN_FILE)
+    planConfig.DryRun = true
+    mpi_cmd_obj := new(testRunner.OSU_MPI_cmds)
+    mpi_cmd_obj.Set_command_executor(
+        simulator.New_executor(simulator.Default_config()))
+    if err := mpi_cmd_obj.Init_OSU_MPI_Cmds(&planConfig);
+       err != errors.OP_SUCCESS {
+        t.Errorf("run does not match its own plan, err : %s", err)
+    }
+    planConfig.ExcludeJobs = nil
+    mpi_cmd_obj = new(testRunner.OSU_MPI_cmds)
+    mpi_cmd_obj.Set_command_executor(
+        simulator.New_executor(simulator.Default_config()))
+    if err := mpi_cmd_obj.Init_OSU_MPI_Cmds(&planConfig);
+       err == errors.OP_SUCCESS {
+        t.Errorf("run with another job list matches the plan")
+    }
+}
+
+func TestRunTelemetry(t *testing.T) {
+    root := t.TempDir()
+    files := map[string]string{
+        "proc/loadavg": "8.00 2.00 1.00 9/300 4242\n",
+        "proc/sys/kernel/hostname": "ip-10-0-0-1\n",
+        "proc/stat": "cpu  100 0 50 800 0 0 0 10 0 0\n" +
+                     "cpu0 50 0 25 400 0 0 0 5 0 0\n" +
+                     "cpu1 50 0 25 400 0 0 0 5 0 0\n" +
+                     "ctxt 12345\n",
+        "proc/interrupts": "           CPU0       CPU1\n" +
+                           "  0:         22          0   IO-APIC   timer\n" +
+                           "NMI:          0          0   Non-maskable\n",
+        "proc/net/dev": "Inter-|   Receive\n face |bytes\n" +
+                        "    lo: 10 1 0 0 0 0 0 0 10 1 0 0 0 0 0 0\n" +
+                        "  eth0: 2000 2 0 0 0 0 0 0 1000 1 0 0 0 0 0 0\n",
+        "sys/devices/system/cpu/cpu0/cpufreq/scaling_cur_freq": "3000000\n",
+        "sys/devices/system/cpu/cpu1/cpufreq/scaling_cur_freq": "1500000\n",
+    }
+    for name, content := range files {
+        fileName := filepath.Join(root, name)
+        os.MkdirAll(filepath.Dir(fileName), 0755)
+        if err := ioutil.WriteFile(fileName, []byte(content), 0644);
+           err != nil {
+            t.Fatal(err)
+        }
+    }
+    configObj := testConfig(t)
+    configObj.Benchmarks = []string{"osu_latency"}
+    configObj.Telemetry = 5 * time.Millisecond
+    simConfig := simulator.Default_config()
+    simConfig.RowDelay = time.Millisecond
+    mpi_cmd_obj := new(testRunner.OSU_MPI_cmds)
+    mpi_cmd_obj.Set_host_root(root)
+    mpi_cmd_obj.Set_command_executor(simulator.New_executor(simConfig))
+    if err := mpi_cmd_obj.Init_OSU_MPI_Cmds(configObj);
+       err != errors.OP_SUCCESS {
+        t.Fatalf("Init_OSU_MPI_Cmds failed, err : %s", err)
+    }
+    resultDir := mpi_cmd_obj.Get_OSU_MPI_test_result_path()
+    syncObj := sys.GetAppSyncObj()
+    syncObj.AddRoutineInWaitGroup()
+    go mpi_cmd_obj.WriteCommandOutput()
+    mpi_cmd_obj.Run_OSU_MPI_Cmds()
+    mpi_cmd_obj.ExitresultWriteRoutine()
+    syncObj.JoinAllRoutines()
+
+    record := readRecords(t, resultDir)["osu_latency.txt"]
+    if record == nil || record.Telemetry == nil {
+        t.Fatalf("telemetry is not recorded %+v", record)
+    }
+    summary := record.Telemetry
+    if summary.Host != "ip-10-0-0-1" || summary.Load1Max != 8 ||
+       summary.FreqMin != 1500 || summary.FreqMax != 3000 ||
+       len(summary.Warnings) != 2 || len(summary.ConcurrentJobs) != 0 {
+        t.Errorf("unexpected telemetry summary %+v", summary)
+    }
+    telemetry, err := testRunner.Read_telemetry(summary.File)
+    if err != errors.OP_SUCCESS || len(telemetry.Samples) == 0 ||
+       len(telemetry.Samples) != summary.Samples {
+        t.Fatalf("unexpected telemetry %+v, err : %v", telemetry, err)
+    }
+    if sample := telemetry.Samples[0]; sample.Running != 9 ||
+       sample.FreqMean != 2250 {
+        t.Errorf("unexpected sample %+v", sample)
+    }
+
+    // Parallel jobs sample the same host, each lists the other.
+    configObj = testConfig(t)
+    ioutil.WriteFile(configObj.HostFile, []byte("h1\nh2\nh3\nh4\n"), 0644)
+    configObj.Benchmarks = []string{"osu_latency", "osu_bw"}
+    configObj.Telemetry = 5 * time.Millisecond
+    configObj.Parallel = 2
+    records := readRecords(t, runCampaign(t, configObj, simConfig))
+    for name, other := range map[string]string{"osu_latency.txt": "osu_bw",
+                                                "osu_bw.txt": "osu_latency"} {
+        summary := records[name].Telemetry
+        if summary == nil || len(summary.ConcurrentJobs) != 1 ||
+           summary.ConcurrentJobs[0] != other {
+            t.Errorf("%s telemetry doesn't list %s, %+v", name, other,
+                     summary)
+        }
+    }
+}
+
+func TestManifestHost(t *testing.T) {
+    root := t.TempDir()
+    cpuinfo := ""
+    for cpu := 0; cpu < 4; cpu++ {
+        cpuinfo += fmt.Sprintf("processor\t: %d\nmodel name\t: Intel(R) " +
+                               "Xeon(R) Platinum 8259CL CPU @ 2.50GHz\n" +
+                               "physical id\t: 0\ncore id\t\t: %d\n\n",
+                               cpu, cpu % 2)
+    }
+    files := map[string]string{
+        "proc/cpuinfo": cpuinfo,
+        "proc/meminfo": "MemTotal:       16000000 kB\nMemFree: 1 kB\n",
+        "proc/sys/kernel/osrelease": "5.10.186-179.751.amzn2.x86_64\n",
+        "etc/os-release": "NAME=\"Amazon Linux\"\n" +
+                          "PRETTY_NAME=\"Amazon Linux 2\"\n",
+        "sys/class/net/eth0/mtu": "9001\n",
+        "sys/class/net/lo/mtu": "65536\n",
+        "sys/bus/pci/drivers/ena/bind": "",
+    }
+    for name, content := range files {
+        fileName := filepath.Join(root, name)
+        os.MkdirAll(filepath.Dir(fileName), 0755)
+        if err := ioutil.WriteFile(fileName, []byte(content), 0644);
+           err != nil {
+            t.Fatal(err)
+        }
+    }
+    os.MkdirAll(filepath.Join(root, "sys/class/net/eth0/device"), 0755)
+    os.Symlink(filepath.Join(root, "sys/bus/pci/drivers/ena"),
+               filepath.Join(root, "sys/class/net/eth0/device/driver"))
+
+    mpi_cmd_obj := new(testRunner.OSU_MPI_cmds)
+    mpi_cmd_obj.Set_host_root(root)
+    mpi_cmd_obj.Set_command_executor(
+        simulator.New_executor(simulator.Default_config()))
+    configObj := testConfig(t)
+    configObj.DryRun = true
+    if err := mpi_cmd_obj.Init_OSU_MPI_Cmds(configObj);
+       err != errors.OP_SUCCESS {
+        t.Fatalf("Init_OSU_MPI_Cmds failed, err : %s", err)
+    }
+    host := mpi_cmd_obj.Get_manifest().Host
+    if !strings.Contains(host.CPUModel, "8259CL") || host.CPUs != 4 ||
+       host.Cores != 2 || host.Sockets != 1 ||
+       host.MemoryBytes != 16000000 * 1024 ||
+       host.Kernel != "5.10.186-179.751.amzn2.x86_64" ||
+       host.OS != "Amazon Linux 2" {
+        t.Errorf("unexpected host %+v", host)
+    }
+    if len(host.NICs) != 1 || host.NICs[0].Name != "eth0" ||
+       host.NICs[0].Driver != "ena" || host.NICs[0].MTU != 9001 {
+        t.Errorf("unexpected NICs %+v", host.NICs)
+    }
+}
+
+func TestDetectLauncher(t *testing.T) {
+    simConfig := simulator.Default_config()
+    simConfig.Launcher = "intel"
+    mpi_cmd_obj := new(testRunner.OSU_MPI_cmds)
+    mpi_cmd_obj.Set_command_executor(simulator.New_executor(simConfig))
+    launcher, err := mpi_cmd_obj.Detect_launcher()
+    if err != errors.OP_SUCCESS || launcher.Name() != config.LAUNCHER_INTELMPI {
+        t.Errorf("detected %v, err : %v", launcher, err)
+    }
+    // Open MPI can't take the mapping with the processes per node.
+    configObj := testConfig(t)
+    configObj.MPIcount = 4
+    configObj.PPN = 2
+    configObj.MapBy = "socket"
+    mpi_cmd_obj = new(testRunner.OSU_MPI_cmds)
+    mpi_cmd_obj.Set_command_executor(
+        simulator.New_executor(simulator.Default_config()))
+    if mpi_cmd_obj.Init_OSU_MPI_Cmds(configObj) == errors.OP_SUCCESS {
+        t.Errorf("-map-by with -ppn is expected to fail for detected " +
+                 "Open MPI")
+    }
+}
+
+// Policies the launcher can't run are rejected before the campaign starts,
+// in a dry run as well.
+func TestInitRejectsPolicy(t *testing.T) {
+    tests := []struct {
+        launcher string
+        mapBySweep []string
+        bindTo string
+    }{
+        {"mpich", []string{"core", "ppr:1:socket"}, ""},
+        {"intel", nil, "l3cache"},
+        {"slurm", []string{"ppr:2:node"}, "core"},
+    }
+    for _, test := range tests {
+        for _, dryRun := range []bool{false, true} {
+            configObj := testConfig(t)
+            configObj.Launcher = test.launcher
+            configObj.MapBySweep = test.mapBySweep
+            configObj.BindTo = test.bindTo
+            configObj.DryRun = dryRun
+            simConfig := simulator.Default_config()
+            simConfig.Launcher = test.launcher
+            mpi_cmd_obj := new(testRunner.OSU_MPI_cmds)
+            mpi_cmd_obj.Set_command_executor(simulator.New_executor(simConfig))
+            if mpi_cmd_obj.Init_OSU_MPI_Cmds(configObj) == errors.OP_SUCCESS {
+                t.Errorf("%s with mappings %v and binding %q is expected " +
+                         "to fail", test.launcher, test.mapBySweep,
+                         test.bindTo)
+            }
+            if entries, _ := ioutil.ReadDir(configObj.ResultsRoot);
+               len(entries) != 0 {
+                t.Errorf("result directory of a rejected campaign is created")
+            }
+        }
+    }
+}
@@ -0,0 +1,74 @@
+package text2json
+
+import (
+    "ec2-osu-benchmark/errors"
+)
+
+// Collective results are reported per benchmark,
+// {
+//     "OsuCollective": {
+//         "osu_allreduce": [
+//             {"pktsize": 4, "avglatency": 1.98, "minlatency": 1.85,
+//              "maxlatency": 2.11, "iterations": 1000},
+//             ...
+//         ],
+//         "osu_barrier": [{"pktsize": 0, "avglatency": 2.34}]
+//     }
+// }
+// min/max latency and iterations are present only when the benchmark
+// is run with full statistics.
+
+//OsuCollectiveTuple :- Tuple for collective latency results
+type OsuCollectiveTuple struct {
+    Pktsize    int     `json:"pktsize"`
+    AvgLatency float64 `json:"avglatency"`
+    MinLatency float64 `json:"minlatency,omitempty"`
+    MaxLatency float64 `json:"maxlatency,omitempty"`
+    Iterations int     `json:"iterations,omitempty"`
+}
+
+//OsuCollective :- Array of collective latency tuples
+type OsuCollective []OsuCollectiveTuple
+
+//ReadOSUCollectiveFile :- Read result set of any OSU collective
+// benchmark. Barrier doesn't report message size, pktsize is 0 for it.
+// Columns are taken from the result header, the run record tells
+// whether min/max/iterations are expected when the header is missing.
+func (txt2jsonObj *Text2Json) ReadOSUCollectiveFile(fileName string) (
+    OsuCollective, error) {
+    table, err := ReadOSUTable(fileName)
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    // Column order: size, avg, min, max, iterations
+    columns := []int{table.ColumnIndex("Size"),
+        table.ColumnIndex("Avg Latency", "Latency"),
+        table.ColumnIndex("Min Latency"),
+        table.ColumnIndex("Max Latency"),
+        table.ColumnIndex("Iterations")}
+    // Without the run record, assume full statistics for 4 columns.
+    fullStats := true
+    if record := txt2jsonObj.GetRunRecord(fileName); record != nil {
+        fullStats = record.Options.FullStats
+    }
+    results := make(OsuCollective, 0, len(table.Rows))
+    for _, row := range table.Rows {
+        idx := columns
+        if table.Columns == nil {
+            // No header line, use the OSU column order. Barrier results
+            // don't have the size column.
+            idx = []int{0, 1, 2, 3, 4}
+            if (fullStats && len(row) == 4) || len(row) == 1 {
+                idx = []int{-1, 0, 1, 2, 3}
+            }
+        }
+        var tuple OsuCollectiveTuple
+        tuple.Pktsize = int(table.Value(row, idx[0]))
+        tuple.AvgLatency = table.Value(row, idx[1])
+        tuple.MinLatency = table.Value(row, idx[2])
+        tuple.MaxLatency = table.Value(row, idx[3])
+        tuple.Iterations = int(table.Value(row, idx[4]))
+        results = append(results, tuple)
+    }
+    return results, errors.OP_SUCCESS
+}
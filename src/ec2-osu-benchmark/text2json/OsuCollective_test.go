@@ -0,0 +1,121 @@
+package text2json
+
+import (
+    "reflect"
+    "testing"
+    "ec2-osu-benchmark/config"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/testRunner"
+)
+
+func TestReadOSUCollectiveFile(t *testing.T) {
+    tests := []struct {
+        name      string
+        bench     string
+        content   string
+        // Run record with the full statistics option, none when nil
+        fullStats *bool
+        want      OsuCollective
+    }{
+        {"header", "osu_allreduce",
+            "# OSU MPI Allreduce Latency Test v5.6.2\n" +
+            "# Size       Avg Latency(us)\n" +
+            "4                       1.98\n" +
+            "1048576               352.41\n",
+            newBool(false),
+            OsuCollective{{Pktsize: 4, AvgLatency: 1.98},
+                {Pktsize: 1048576, AvgLatency: 352.41}}},
+        {"full stats header", "osu_allreduce",
+            "# OSU MPI Allreduce Latency Test v5.6.2\n" +
+            "# Size       Avg Latency(us)   Min Latency(us)   " +
+            "Max Latency(us)  Iterations\n" +
+            "4                       1.98              1.85" +
+            "              2.11        1000\n" +
+            "1048576               352.41            340.02" +
+            "            365.77          20\n",
+            newBool(true),
+            OsuCollective{{4, 1.98, 1.85, 2.11, 1000},
+                {1048576, 352.41, 340.02, 365.77, 20}}},
+        {"barrier header", "osu_barrier",
+            "# OSU MPI Barrier Latency Test v5.6.2\n" +
+            "# Avg Latency(us)\n" +
+            "             2.34\n",
+            newBool(false),
+            OsuCollective{{AvgLatency: 2.34}}},
+        {"barrier full stats header", "osu_barrier",
+            "# OSU MPI Barrier Latency Test v5.6.2\n" +
+            "# Avg Latency(us)   Min Latency(us)   Max Latency(us)  " +
+            "Iterations\n" +
+            "             2.34              2.21              2.50" +
+            "        1000\n",
+            newBool(true),
+            OsuCollective{{0, 2.34, 2.21, 2.50, 1000}}},
+        {"no header", "osu_allreduce",
+            "4                       1.98\n",
+            newBool(false),
+            OsuCollective{{Pktsize: 4, AvgLatency: 1.98}}},
+        {"full stats no header", "osu_allreduce",
+            "4                       1.98              1.85" +
+            "              2.11        1000\n",
+            newBool(true),
+            OsuCollective{{4, 1.98, 1.85, 2.11, 1000}}},
+        {"barrier no header", "osu_barrier",
+            "             2.34\n",
+            newBool(false),
+            OsuCollective{{AvgLatency: 2.34}}},
+        {"barrier full stats no header", "osu_barrier",
+            "             2.34              2.21              2.50" +
+            "        1000\n",
+            newBool(true),
+            OsuCollective{{0, 2.34, 2.21, 2.50, 1000}}},
+        // Without the run record 4 columns are taken for full statistics
+        // of barrier.
+        {"barrier no header or record", "osu_barrier",
+            "             2.34              2.21              2.50" +
+            "        1000\n",
+            nil,
+            OsuCollective{{0, 2.34, 2.21, 2.50, 1000}}},
+        {"malformed lines", "osu_bcast",
+            "# OSU MPI Broadcast Latency Test v5.6.2\n" +
+            "# Size       Avg Latency(us)\n" +
+            "1                       1.21\n" +
+            "--------------------------------------------------------" +
+            "------------------\n" +
+            "Primary job  terminated normally, but 1 process returned\n" +
+            "2                       1.\n" +
+            "4\n",
+            newBool(false),
+            OsuCollective{{Pktsize: 1, AvgLatency: 1.21},
+                {Pktsize: 2, AvgLatency: 1}}},
+        {"empty", "osu_alltoall", "", newBool(false), OsuCollective{}},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            fileName := test.bench + ".txt"
+            records := []*testRunner.OSU_run_record{}
+            if test.fullStats != nil {
+                records = append(records, &testRunner.OSU_run_record{
+                    Benchmark: test.bench, StdoutFile: fileName,
+                    Category: testRunner.OSU_CATEGORY_COLLECTIVE,
+                    Options: config.OSUOptions{FullStats: *test.fullStats}})
+            }
+            txt2jsonObj := newTestText2Json(t,
+                map[string]string{fileName: test.content}, records)
+            fileName = txt2jsonObj.resPath + fileName
+            if !txt2jsonObj.IsCollectiveFile(fileName) {
+                t.Errorf("%s is not a collective file", fileName)
+            }
+            results, err := txt2jsonObj.ReadOSUCollectiveFile(fileName)
+            if err != errors.OP_SUCCESS {
+                t.Fatalf("ReadOSUCollectiveFile failed, err : %s", err)
+            }
+            if !reflect.DeepEqual(results, test.want) {
+                t.Errorf("results %+v, want %+v", results, test.want)
+            }
+        })
+    }
+}
+
+func newBool(value bool) *bool {
+    return &value
+}
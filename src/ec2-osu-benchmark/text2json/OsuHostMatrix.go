@@ -0,0 +1,279 @@
+package text2json
+
+import (
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/testRunner"
+    "fmt"
+    "io/ioutil"
+    "math"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// Host pair matrix(-pair-matrix), osu_latency and osu_bw between every
+// pair of hosts. Latency is taken at the smallest and bandwidth at the
+// largest message size, the median across the repetitions.
+// {
+//     "HostMatrix": {
+//         "hosts": ["ip-10-0-0-1", "ip-10-0-0-2", "ip-10-0-0-3"],
+//         "metrics": [
+//             {
+//                 "benchmark": "osu_latency",
+//                 "metric": "Latency (us)",
+//                 "pktsize": 0,
+//                 "values": [[null, 15.2, 15.1],
+//                            [15.2, null, 42.7],
+//                            [15.1, 42.7, null]],
+//                 "median": 15.2,
+//                 "mad": 0.14,
+//                 "outliers": [{"hosts": ["ip-10-0-0-2", "ip-10-0-0-3"],
+//                               "value": 42.7, "deviation": 185.7}]
+//             }, ...
+//         ],
+//         "hostoutliers": {"ip-10-0-0-2": 1, "ip-10-0-0-3": 1}
+//     }
+// }
+//
+// The matrix is also written as host-matrix-<benchmark>.csv in the result
+// directory, the first row and column have the hosts.
+
+// Pairs farther than this many scaled MADs from the median are outliers.
+const pairOutlierMADs = 3.0
+
+// Scale of the MAD to estimate the standard deviation of normal data.
+const madScale = 1.4826
+
+// Minimum outlier distance as a fraction of the median, keeps identical
+// pairs(MAD 0) from flagging tiny differences.
+const pairOutlierMinFraction = 0.05
+
+//OsuPairOutlier :- Host pair with a value far off the other pairs.
+// Deviation is the distance from the median in scaled MADs.
+type OsuPairOutlier struct {
+    Hosts     []string `json:"hosts"`
+    Value     float64  `json:"value"`
+    Deviation float64  `json:"deviation"`
+}
+
+//OsuHostMatrixMetric :- Metric of a benchmark between every host pair,
+// Values[i][j] is the value between the hosts i and j. The diagonal and
+// pairs without results are null.
+type OsuHostMatrixMetric struct {
+    Benchmark string           `json:"benchmark"`
+    Metric    string           `json:"metric"`
+    Pktsize   int              `json:"pktsize"`
+    Values    [][]*float64     `json:"values"`
+    Median    float64          `json:"median"`
+    MAD       float64          `json:"mad"`
+    Outliers  []OsuPairOutlier `json:"outliers"`
+}
+
+//OsuHostMatrix :- Results of the pair matrix mode. HostOutliers has the
+// number of outlier pairs of every host, a bad host shows up in most of
+// its pairs.
+type OsuHostMatrix struct {
+    Hosts        []string               `json:"hosts"`
+    Metrics      []*OsuHostMatrixMetric `json:"metrics"`
+    HostOutliers map[string]int         `json:"hostoutliers"`
+}
+
+// Value of a pair matrix result file, the first row for latency and the
+// last row for bandwidth.
+func (txt2jsonObj *Text2Json) readPairValue(
+    record *testRunner.OSU_run_record) (string, int, float64, error) {
+    table, err := ReadOSUTable(txt2jsonObj.ResultFilePath(record.StdoutFile))
+    if err != errors.OP_SUCCESS {
+        return "", 0, 0, err
+    }
+    if len(table.Rows) == 0 {
+        return "", 0, 0, errors.DATA_NOT_FOUND
+    }
+    sizeIdx := table.ColumnIndex("Size")
+    if sizeIdx < 0 {
+        sizeIdx = 0
+    }
+    valueIdx := table.ColumnIndex("Latency", "Bandwidth")
+    if valueIdx < 0 {
+        valueIdx = sizeIdx + 1
+    }
+    row := table.Rows[len(table.Rows)-1]
+    if record.Benchmark == "osu_latency" {
+        row = table.Rows[0]
+    }
+    return statsColumnName(table, valueIdx), int(table.Value(row, sizeIdx)),
+        table.Value(row, valueIdx), errors.OP_SUCCESS
+}
+
+// Median of the values, the values are sorted in place.
+func median(values []float64) float64 {
+    sort.Float64s(values)
+    return Percentile(values, 50)
+}
+
+// Hosts of the matrix in the hostfile order, hosts only in the records
+// are added in sorted order.
+func (txt2jsonObj *Text2Json) pairMatrixHosts(
+    records []*testRunner.OSU_run_record) []string {
+    hosts := make([]string, 0)
+    seen := make(map[string]bool)
+    hostfile, err := testRunner.Read_hostfile(txt2jsonObj.configObj.HostFile)
+    if err == errors.OP_SUCCESS {
+        for _, host := range testRunner.Get_host_names(hostfile) {
+            if !seen[host] {
+                seen[host] = true
+                hosts = append(hosts, host)
+            }
+        }
+    }
+    extra := make([]string, 0)
+    for _, record := range records {
+        for _, host := range record.Hosts {
+            if !seen[host] {
+                seen[host] = true
+                extra = append(extra, host)
+            }
+        }
+    }
+    sort.Strings(extra)
+    return append(hosts, extra...)
+}
+
+//ComputeHostMatrix :- Host pair matrix of the pair matrix runs in the
+// records, nil when there are none.
+func (txt2jsonObj *Text2Json) ComputeHostMatrix(
+    records []*testRunner.OSU_run_record) *OsuHostMatrix {
+    pairRecords := make([]*testRunner.OSU_run_record, 0)
+    for _, record := range records {
+        if len(record.Hosts) == 2 &&
+            record.Status == testRunner.RUN_STATUS_SUCCESS {
+            pairRecords = append(pairRecords, record)
+        }
+    }
+    if len(pairRecords) == 0 {
+        return nil
+    }
+    matrix := new(OsuHostMatrix)
+    matrix.Hosts = txt2jsonObj.pairMatrixHosts(pairRecords)
+    matrix.HostOutliers = make(map[string]int)
+    hostIdx := make(map[string]int)
+    for idx, host := range matrix.Hosts {
+        hostIdx[host] = idx
+    }
+    for _, benchName := range testRunner.PAIR_MATRIX_BENCHMARKS {
+        // samples of every pair, [i][j] with i < j
+        samples := make(map[[2]int][]float64)
+        metric := new(OsuHostMatrixMetric)
+        metric.Benchmark = benchName
+        for _, record := range pairRecords {
+            if record.Benchmark != benchName {
+                continue
+            }
+            name, pktsize, value, err := txt2jsonObj.readPairValue(record)
+            if err != errors.OP_SUCCESS {
+                continue
+            }
+            metric.Metric, metric.Pktsize = name, pktsize
+            i, j := hostIdx[record.Hosts[0]], hostIdx[record.Hosts[1]]
+            if i > j {
+                i, j = j, i
+            }
+            samples[[2]int{i, j}] = append(samples[[2]int{i, j}], value)
+        }
+        if len(samples) == 0 {
+            continue
+        }
+        metric.Values = make([][]*float64, len(matrix.Hosts))
+        for idx := range metric.Values {
+            metric.Values[idx] = make([]*float64, len(matrix.Hosts))
+        }
+        pairValues := make([]float64, 0, len(samples))
+        for pair, values := range samples {
+            value := median(values)
+            metric.Values[pair[0]][pair[1]] = &value
+            metric.Values[pair[1]][pair[0]] = &value
+            pairValues = append(pairValues, value)
+        }
+        metric.Median = median(pairValues)
+        deviations := make([]float64, len(pairValues))
+        for idx, value := range pairValues {
+            deviations[idx] = math.Abs(value - metric.Median)
+        }
+        metric.MAD = madScale * median(deviations)
+        metric.Outliers = txt2jsonObj.findPairOutliers(metric, matrix.Hosts)
+        for _, outlier := range metric.Outliers {
+            for _, host := range outlier.Hosts {
+                matrix.HostOutliers[host]++
+            }
+        }
+        matrix.Metrics = append(matrix.Metrics, metric)
+    }
+    return matrix
+}
+
+// Outlier pairs of a metric, pairs with higher latency or lower bandwidth
+// than the median by pairOutlierMADs scaled MADs.
+func (txt2jsonObj *Text2Json) findPairOutliers(metric *OsuHostMatrixMetric,
+    hosts []string) []OsuPairOutlier {
+    outliers := make([]OsuPairOutlier, 0)
+    threshold := math.Max(pairOutlierMADs*metric.MAD,
+        pairOutlierMinFraction*metric.Median)
+    higherIsWorse := metric.Benchmark == "osu_latency"
+    for i := range metric.Values {
+        for j := i + 1; j < len(metric.Values[i]); j++ {
+            if metric.Values[i][j] == nil {
+                continue
+            }
+            value := *metric.Values[i][j]
+            distance := metric.Median - value
+            if higherIsWorse {
+                distance = value - metric.Median
+            }
+            if distance <= threshold {
+                continue
+            }
+            var outlier OsuPairOutlier
+            outlier.Hosts = []string{hosts[i], hosts[j]}
+            outlier.Value = value
+            if metric.MAD != 0 {
+                outlier.Deviation = distance / metric.MAD
+            }
+            outliers = append(outliers, outlier)
+        }
+    }
+    return outliers
+}
+
+//WriteHostMatrixCSV :- Write every metric of the host matrix to
+// host-matrix-<benchmark>.csv in the result directory.
+func (txt2jsonObj *Text2Json) WriteHostMatrixCSV() error {
+    logger := logging.GetLoggerInstance()
+    matrix := txt2jsonObj.jsonResults.HostMatrix
+    if matrix == nil {
+        return errors.OP_SUCCESS
+    }
+    for _, metric := range matrix.Metrics {
+        var content strings.Builder
+        content.WriteString("host," + strings.Join(matrix.Hosts, ",") + "\n")
+        for i, host := range matrix.Hosts {
+            content.WriteString(host)
+            for _, value := range metric.Values[i] {
+                content.WriteString(",")
+                if value != nil {
+                    content.WriteString(fmt.Sprintf("%g", *value))
+                }
+            }
+            content.WriteString("\n")
+        }
+        fileName := filepath.Join(txt2jsonObj.resPath,
+            "host-matrix-"+metric.Benchmark+".csv")
+        err := ioutil.WriteFile(fileName, []byte(content.String()), 0644)
+        if err != nil {
+            logger.Error("Failed to write host matrix %s, err : %s",
+                fileName, err)
+            return err
+        }
+    }
+    return errors.OP_SUCCESS
+}
@@ -0,0 +1,122 @@
+package text2json
+
+import (
+    "fmt"
+    "io/ioutil"
+    "math"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/testRunner"
+)
+
+// Pair matrix of 4 hosts, osu_latency at the first row and osu_bw at the
+// last row of every pair.
+func newPairMatrix(t *testing.T, latency map[[2]int]float64,
+    bw map[[2]int]float64) *Text2Json {
+    t.Helper()
+    files := make(map[string]string)
+    records := make([]*testRunner.OSU_run_record, 0)
+    add := func(bench string, pair [2]int, content string) {
+        name := fmt.Sprintf("%s.pair%d-%d.txt", bench, pair[0], pair[1])
+        files[name] = content
+        records = append(records, &testRunner.OSU_run_record{
+            Benchmark: bench, StdoutFile: name,
+            Status: testRunner.RUN_STATUS_SUCCESS,
+            Hosts: []string{fmt.Sprintf("h%d", pair[0]),
+                fmt.Sprintf("h%d", pair[1])}})
+    }
+    for pair, value := range latency {
+        add("osu_latency", pair, fmt.Sprintf("# OSU MPI Latency Test v5.6.2\n"+
+            "# Size          Latency (us)\n0  %g\n4194304  999.0\n", value))
+    }
+    for pair, value := range bw {
+        add("osu_bw", pair, fmt.Sprintf("# OSU MPI Bandwidth Test v5.6.2\n"+
+            "# Size      Bandwidth (MB/s)\n1  0.48\n4194304  %g\n", value))
+    }
+    txt2jsonObj := newTestText2Json(t, files, records)
+    txt2jsonObj.configObj.HostFile = filepath.Join(t.TempDir(), "hostfile")
+    err := ioutil.WriteFile(txt2jsonObj.configObj.HostFile,
+        []byte("h1\nh2\nh3\nh4\n"), 0644)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return txt2jsonObj
+}
+
+func TestComputeHostMatrix(t *testing.T) {
+    latency := map[[2]int]float64{{1, 2}: 10, {1, 3}: 10.2, {1, 4}: 9.8,
+        {2, 3}: 10.4, {2, 4}: 10, {3, 4}: 40}
+    bw := map[[2]int]float64{{1, 2}: 500, {1, 3}: 1000, {1, 4}: 1000,
+        {2, 3}: 1000, {2, 4}: 1000, {3, 4}: 1500}
+    txt2jsonObj := newPairMatrix(t, latency, bw)
+    matrix := txt2jsonObj.ComputeHostMatrix(txt2jsonObj.jsonResults.Runs)
+    if matrix == nil || len(matrix.Metrics) != 2 ||
+        !reflect.DeepEqual(matrix.Hosts, []string{"h1", "h2", "h3", "h4"}) {
+        t.Fatalf("unexpected host matrix %+v", matrix)
+    }
+
+    // 9.8 10 10 10.2 10.4 40, median 10.1, deviations 0.1 0.1 0.1 0.3 0.3
+    // 29.9, MAD 1.4826 * 0.2
+    lat := matrix.Metrics[0]
+    if lat.Benchmark != "osu_latency" || lat.Pktsize != 0 ||
+        math.Abs(lat.Median-10.1) > 1e-9 ||
+        math.Abs(lat.MAD-0.29652) > 1e-9 {
+        t.Errorf("unexpected latency metric %+v", lat)
+    }
+    if *lat.Values[2][3] != 40 || *lat.Values[3][2] != 40 ||
+        lat.Values[0][0] != nil {
+        t.Errorf("unexpected latency values %v", lat.Values)
+    }
+    if len(lat.Outliers) != 1 ||
+        !reflect.DeepEqual(lat.Outliers[0].Hosts, []string{"h3", "h4"}) ||
+        math.Abs(lat.Outliers[0].Deviation-29.9/0.29652) > 1e-6 {
+        t.Errorf("unexpected latency outliers %+v", lat.Outliers)
+    }
+
+    // Median 1000 and MAD 0, the 5% floor of the median flags the slow
+    // pair only, a faster pair is not an outlier.
+    bwMetric := matrix.Metrics[1]
+    if bwMetric.Benchmark != "osu_bw" || bwMetric.Pktsize != 4194304 ||
+        bwMetric.Median != 1000 || bwMetric.MAD != 0 {
+        t.Errorf("unexpected bandwidth metric %+v", bwMetric)
+    }
+    if len(bwMetric.Outliers) != 1 ||
+        !reflect.DeepEqual(bwMetric.Outliers[0].Hosts, []string{"h1", "h2"}) ||
+        bwMetric.Outliers[0].Deviation != 0 {
+        t.Errorf("unexpected bandwidth outliers %+v", bwMetric.Outliers)
+    }
+    want := map[string]int{"h1": 1, "h2": 1, "h3": 1, "h4": 1}
+    if !reflect.DeepEqual(matrix.HostOutliers, want) {
+        t.Errorf("host outliers %v, want %v", matrix.HostOutliers, want)
+    }
+}
+
+func TestWriteHostMatrixCSV(t *testing.T) {
+    latency := map[[2]int]float64{{1, 2}: 10, {1, 3}: 10.5, {2, 3}: 11}
+    txt2jsonObj := newPairMatrix(t, latency, nil)
+    txt2jsonObj.jsonResults.HostMatrix =
+        txt2jsonObj.ComputeHostMatrix(txt2jsonObj.jsonResults.Runs)
+    if err := txt2jsonObj.WriteHostMatrixCSV(); err != errors.OP_SUCCESS {
+        t.Fatalf("WriteHostMatrixCSV failed, err : %s", err)
+    }
+    csv, err := ioutil.ReadFile(filepath.Join(txt2jsonObj.resPath,
+        "host-matrix-osu_latency.csv"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    // h4 is in the hostfile without any pair
+    want := "host,h1,h2,h3,h4\n" +
+        "h1,,10,10.5,\n" +
+        "h2,10,,11,\n" +
+        "h3,10.5,11,,\n" +
+        "h4,,,,\n"
+    if string(csv) != want {
+        t.Errorf("host matrix csv :\n%s\nwant :\n%s", csv, want)
+    }
+    if _, err = ioutil.ReadFile(filepath.Join(txt2jsonObj.resPath,
+        "host-matrix-osu_bw.csv")); err == nil {
+        t.Errorf("host matrix of osu_bw without results")
+    }
+}
@@ -0,0 +1,91 @@
+package text2json
+
+import (
+    "ec2-osu-benchmark/errors"
+    "regexp"
+    "strconv"
+)
+
+// osu_mbw_mr reports bandwidth and message rate for each size,
+// # OSU MPI Multiple Bandwidth / Message Rate Test v5.6.2
+// # [ pairs: 1 ] [ window size: 64 ]
+// # Size                  MB/s        Messages/s
+// 1                       3.21        3210000.00
+//
+// JSON format would be
+// {
+//     "OsuMultiBW": {
+//         "pairs": 1,
+//         "windowsize": 64,
+//         "values": [{"pktsize": 1, "bw": 3.21, "msgrate": 3210000}, ...]
+//     }
+// }
+// osu_multi_lat has the same format as osu_latency and is reported as
+// "OsuMultiLatency".
+
+var osuPairsPattern = regexp.MustCompile(`pairs:\s*(\d+)`)
+var osuWindowSizePattern = regexp.MustCompile(`window size:\s*(\d+)`)
+
+//OsuMultiBWTuple :- Tuple for multi-pair bandwidth/message rate results
+type OsuMultiBWTuple struct {
+    Pktsize int     `json:"pktsize"`
+    Bw      float64 `json:"bw"`
+    MsgRate float64 `json:"msgrate"`
+}
+
+//OsuMultiBW :- Result set of osu_mbw_mr with the run parameters
+type OsuMultiBW struct {
+    Pairs      int               `json:"pairs"`
+    WindowSize int               `json:"windowsize"`
+    Values     []OsuMultiBWTuple `json:"values"`
+}
+
+//IsMultiBWFile :- Check if OSU result file is a multi-pair
+// bandwidth/message rate file.
+func (txt2jsonObj *Text2Json) IsMultiBWFile(fileName string) bool {
+    return txt2jsonObj.BenchmarkName(fileName) == "osu_mbw_mr"
+}
+
+//IsMultiLatencyFile :- Check if OSU result file is a multi-pair
+// latency file.
+func (txt2jsonObj *Text2Json) IsMultiLatencyFile(fileName string) bool {
+    return txt2jsonObj.BenchmarkName(fileName) == "osu_multi_lat"
+}
+
+//ReadOSUMultiBWFile :- Read osu_mbw_mr result set, all the columns
+// are kept. Pairs and window size are taken from the result header.
+func (txt2jsonObj *Text2Json) ReadOSUMultiBWFile(fileName string) (
+    *OsuMultiBW, error) {
+    table, err := ReadOSUTable(fileName)
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    result := new(OsuMultiBW)
+    result.Pairs = int(txt2jsonObj.configObj.Pairs)
+    result.WindowSize = int(txt2jsonObj.configObj.WindowSize)
+    for _, comment := range table.Comments {
+        if match := osuPairsPattern.FindStringSubmatch(comment); match != nil {
+            result.Pairs, _ = strconv.Atoi(match[1])
+        }
+        match := osuWindowSizePattern.FindStringSubmatch(comment)
+        if match != nil {
+            result.WindowSize, _ = strconv.Atoi(match[1])
+        }
+    }
+    // Column order: size, MB/s, Messages/s
+    columns := []int{0, 1, 2}
+    if table.Columns != nil {
+        columns = []int{table.ColumnIndex("Size"),
+            table.ColumnIndex("MB/s"),
+            table.ColumnIndex("Messages/s")}
+    }
+    result.Values = make([]OsuMultiBWTuple, 0, len(table.Rows))
+    for _, row := range table.Rows {
+        var tuple OsuMultiBWTuple
+        tuple.Pktsize = int(table.Value(row, columns[0]))
+        tuple.Bw = table.Value(row, columns[1])
+        tuple.MsgRate = table.Value(row, columns[2])
+        result.Values = append(result.Values, tuple)
+    }
+    return result, errors.OP_SUCCESS
+}
@@ -0,0 +1,71 @@
+package text2json
+
+import (
+    "reflect"
+    "testing"
+    "ec2-osu-benchmark/errors"
+)
+
+func TestReadOSUMultiBWFile(t *testing.T) {
+    tests := []struct {
+        name    string
+        content string
+        want    OsuMultiBW
+    }{
+        {"header",
+            "# OSU MPI Multiple Bandwidth / Message Rate Test v5.6.2\n" +
+            "# [ pairs: 2 ] [ window size: 64 ]\n" +
+            "# Size                  MB/s        Messages/s\n" +
+            "1                       6.42        6420000.00\n" +
+            "4194304             23519.06           5607.38\n",
+            OsuMultiBW{2, 64, []OsuMultiBWTuple{{1, 6.42, 6420000},
+                {4194304, 23519.06, 5607.38}}}},
+        // Without the header the configured pairs and window size are
+        // used.
+        {"no header",
+            "1                       3.21        3210000.00\n",
+            OsuMultiBW{4, 32, []OsuMultiBWTuple{{1, 3.21, 3210000}}}},
+        {"malformed lines",
+            "# OSU MPI Multiple Bandwidth / Message Rate Test v5.6.2\n" +
+            "# [ pairs: 1 ] [ window size: 128 ]\n" +
+            "# Size                  MB/s        Messages/s\n" +
+            "1                       3.21        3210000.00\n" +
+            "mpirun noticed that process rank 1 with PID 0 on node " +
+            "ip-10-0-0-2 exited on signal 9 (Killed).\n" +
+            "2                       6.40\n",
+            OsuMultiBW{1, 128, []OsuMultiBWTuple{{1, 3.21, 3210000}}}},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            txt2jsonObj := newTestText2Json(t,
+                map[string]string{"osu_mbw_mr.txt": test.content}, nil)
+            txt2jsonObj.configObj.Pairs = 4
+            txt2jsonObj.configObj.WindowSize = 32
+            fileName := txt2jsonObj.resPath + "osu_mbw_mr.txt"
+            if !txt2jsonObj.IsMultiBWFile(fileName) ||
+                txt2jsonObj.IsMultiLatencyFile(fileName) {
+                t.Errorf("%s is misclassified", fileName)
+            }
+            result, err := txt2jsonObj.ReadOSUMultiBWFile(fileName)
+            if err != errors.OP_SUCCESS {
+                t.Fatalf("ReadOSUMultiBWFile failed, err : %s", err)
+            }
+            if !reflect.DeepEqual(*result, test.want) {
+                t.Errorf("result %+v, want %+v", *result, test.want)
+            }
+        })
+    }
+}
+
+func TestMultiLatencyFile(t *testing.T) {
+    txt2jsonObj := newTestText2Json(t, map[string]string{
+        "osu_multi_lat.txt": "# OSU MPI Multi Latency Test v5.6.2\n" +
+            "# Size          Latency (us)\n" +
+            "0                       1.72\n"}, nil)
+    fileName := txt2jsonObj.resPath + "osu_multi_lat.txt"
+    if !txt2jsonObj.IsMultiLatencyFile(fileName) ||
+        txt2jsonObj.IsMultiBWFile(fileName) ||
+        txt2jsonObj.IsLatencyFile(fileName) {
+        t.Errorf("%s is misclassified", fileName)
+    }
+}
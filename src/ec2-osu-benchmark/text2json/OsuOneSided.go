@@ -0,0 +1,96 @@
+package text2json
+
+import (
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/testRunner"
+    "strings"
+)
+
+// One-sided(RMA) results are reported per benchmark along with the
+// window creation and synchronization used for the run,
+// {
+//     "OsuOneSided": {
+//         "osu_put_latency": {
+//             "window": "MPI_Win_allocate",
+//             "sync": "MPI_Win_flush",
+//             "metric": "latency",
+//             "unit": "us",
+//             "values": [{"pktsize": 1, "value": 2.11}, ...]
+//         }
+//     }
+// }
+
+//OsuOneSidedTuple :- Tuple for one-sided results
+type OsuOneSidedTuple struct {
+    Pktsize int     `json:"pktsize"`
+    Value   float64 `json:"value"`
+}
+
+//OsuOneSidedResult :- Result set of a one-sided benchmark with the
+// run parameters.
+type OsuOneSidedResult struct {
+    Window string             `json:"window"`
+    Sync   string             `json:"sync"`
+    Metric string             `json:"metric"`
+    Unit   string             `json:"unit"`
+    Values []OsuOneSidedTuple `json:"values"`
+}
+
+//IsOneSidedFile :- Check if OSU result file is produced by a
+// one-sided benchmark.
+func (txt2jsonObj *Text2Json) IsOneSidedFile(fileName string) bool {
+    return txt2jsonObj.BenchmarkCategory(fileName) ==
+        testRunner.OSU_CATEGORY_ONE_SIDED
+}
+
+//ReadOSUOneSidedFile :- Read result set of a one-sided benchmark.
+// OSU prints the window creation and synchronization in the header,
+// # Window creation: MPI_Win_allocate
+// # Synchronization: MPI_Win_flush
+// The configured options are used when the header doesn't have them.
+func (txt2jsonObj *Text2Json) ReadOSUOneSidedFile(fileName string) (
+    *OsuOneSidedResult, error) {
+    table, err := ReadOSUTable(fileName)
+    if err != errors.OP_SUCCESS {
+        return nil, err
+    }
+    result := new(OsuOneSidedResult)
+    result.Window = txt2jsonObj.configObj.RMAWindow
+    result.Sync = txt2jsonObj.configObj.RMASync
+    for _, comment := range table.Comments {
+        if strings.HasPrefix(comment, "Window creation:") {
+            result.Window = strings.TrimSpace(
+                strings.TrimPrefix(comment, "Window creation:"))
+        }
+        if strings.HasPrefix(comment, "Synchronization:") {
+            result.Sync = strings.TrimSpace(
+                strings.TrimPrefix(comment, "Synchronization:"))
+        }
+    }
+    valueIdx := table.ColumnIndex("Latency")
+    result.Metric, result.Unit = "latency", "us"
+    if valueIdx < 0 {
+        valueIdx = table.ColumnIndex("Bandwidth")
+        result.Metric, result.Unit = "bw", "MB/s"
+    }
+    if valueIdx < 0 {
+        // No header, bandwidth benchmarks have '_bw'/'_bibw' in the name.
+        valueIdx = 1
+        result.Metric, result.Unit = "latency", "us"
+        if strings.Contains(txt2jsonObj.BenchmarkName(fileName), "bw") {
+            result.Metric, result.Unit = "bw", "MB/s"
+        }
+    }
+    sizeIdx := table.ColumnIndex("Size")
+    if sizeIdx < 0 {
+        sizeIdx = 0
+    }
+    result.Values = make([]OsuOneSidedTuple, 0, len(table.Rows))
+    for _, row := range table.Rows {
+        var tuple OsuOneSidedTuple
+        tuple.Pktsize = int(table.Value(row, sizeIdx))
+        tuple.Value = table.Value(row, valueIdx)
+        result.Values = append(result.Values, tuple)
+    }
+    return result, errors.OP_SUCCESS
+}
@@ -0,0 +1,78 @@
+package text2json
+
+import (
+    "reflect"
+    "testing"
+    "ec2-osu-benchmark/errors"
+)
+
+func TestReadOSUOneSidedFile(t *testing.T) {
+    tests := []struct {
+        name    string
+        bench   string
+        content string
+        want    OsuOneSidedResult
+    }{
+        {"latency header", "osu_put_latency",
+            "# OSU MPI_Put Latency Test v5.6.2\n" +
+            "# Window creation: MPI_Win_allocate\n" +
+            "# Synchronization: MPI_Win_flush\n" +
+            "# Size          Latency (us)\n" +
+            "0                       0.08\n" +
+            "1                       2.11\n" +
+            "4194304               342.67\n",
+            OsuOneSidedResult{"MPI_Win_allocate", "MPI_Win_flush",
+                "latency", "us", []OsuOneSidedTuple{{0, 0.08}, {1, 2.11},
+                    {4194304, 342.67}}}},
+        {"bandwidth header", "osu_get_bw",
+            "# OSU MPI_Get Bandwidth Test v5.6.2\n" +
+            "# Window creation: MPI_Win_create\n" +
+            "# Synchronization: MPI_Win_lock/unlock\n" +
+            "# Size      Bandwidth (MB/s)\n" +
+            "1                       0.71\n" +
+            "4194304             11802.29\n",
+            OsuOneSidedResult{"MPI_Win_create", "MPI_Win_lock/unlock",
+                "bw", "MB/s", []OsuOneSidedTuple{{1, 0.71},
+                    {4194304, 11802.29}}}},
+        // Without the header the configured options are used, the metric
+        // is taken from the benchmark name.
+        {"bandwidth no header", "osu_put_bibw",
+            "1                       1.42\n",
+            OsuOneSidedResult{"dynamic", "lock_all", "bw", "MB/s",
+                []OsuOneSidedTuple{{1, 1.42}}}},
+        {"latency no header", "osu_acc_latency",
+            "1                       3.02\n",
+            OsuOneSidedResult{"dynamic", "lock_all", "latency", "us",
+                []OsuOneSidedTuple{{1, 3.02}}}},
+        {"malformed lines", "osu_get_latency",
+            "# OSU MPI_Get latency Test v5.6.2\n" +
+            "# Window creation: MPI_Win_allocate\n" +
+            "# Synchronization: MPI_Win_flush\n" +
+            "# Size          Latency (us)\n" +
+            "1                       2.30\n" +
+            "[ip-10-0-0-2:05678] *** Process received signal ***\n" +
+            "2\n",
+            OsuOneSidedResult{"MPI_Win_allocate", "MPI_Win_flush",
+                "latency", "us", []OsuOneSidedTuple{{1, 2.30}}}},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            fileName := test.bench + ".txt"
+            txt2jsonObj := newTestText2Json(t,
+                map[string]string{fileName: test.content}, nil)
+            txt2jsonObj.configObj.RMAWindow = "dynamic"
+            txt2jsonObj.configObj.RMASync = "lock_all"
+            fileName = txt2jsonObj.resPath + fileName
+            if !txt2jsonObj.IsOneSidedFile(fileName) {
+                t.Errorf("%s is not a one-sided file", fileName)
+            }
+            result, err := txt2jsonObj.ReadOSUOneSidedFile(fileName)
+            if err != errors.OP_SUCCESS {
+                t.Fatalf("ReadOSUOneSidedFile failed, err : %s", err)
+            }
+            if !reflect.DeepEqual(*result, test.want) {
+                t.Errorf("result %+v, want %+v", *result, test.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,239 @@
+package text2json
+
+import (
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/testRunner"
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+)
+
+// Statistics of every benchmark and np/ppn/mapping/binding configuration
+// across the repetitions(-repeat), computed per message size for all the
+// value columns of the result.
+// {
+//     "Statistics": [
+//         {
+//             "benchmark": "osu_latency",
+//             "np": 2,
+//             "ppn": 1,
+//             "mapby": "socket",
+//             "bindto": "core",
+//             "metric": "Latency (us)",
+//             "repetitions": 5,
+//             "values": [
+//                 {"pktsize": 1, "samples": [2.11, 2.15, ...],
+//                  "mean": 2.13, "median": 2.12, "min": 2.11, "max": 2.2,
+//                  "stddev": 0.03, "p95": 2.19,
+//                  "cilow": 2.09, "cihigh": 2.17}, ...
+//             ]
+//         }
+//     ]
+// }
+
+// Two sided 95% critical values of the t-distribution, indexed by the
+// degrees of freedom. Normal approximation is used beyond the table.
+var tCritical95 = []float64{0, 12.706, 4.303, 3.182, 2.776, 2.571, 2.447,
+    2.365, 2.306, 2.262, 2.228, 2.201, 2.179, 2.160, 2.145, 2.131, 2.120,
+    2.110, 2.101, 2.093, 2.086, 2.080, 2.074, 2.069, 2.064, 2.060, 2.056,
+    2.052, 2.048, 2.045, 2.042}
+
+const zCritical95 = 1.960
+
+//OsuSizeStats :- Statistics of a metric for a message size. CILow and
+// CIHigh are the 95% confidence interval of the mean.
+type OsuSizeStats struct {
+    Pktsize int       `json:"pktsize"`
+    Samples []float64 `json:"samples"`
+    Mean    float64   `json:"mean"`
+    Median  float64   `json:"median"`
+    Min     float64   `json:"min"`
+    Max     float64   `json:"max"`
+    Stddev  float64   `json:"stddev"`
+    P95     float64   `json:"p95"`
+    CILow   float64   `json:"cilow"`
+    CIHigh  float64   `json:"cihigh"`
+}
+
+//OsuBenchStats :- Statistics of a metric(column) of a benchmark run
+// with np processes, ppn per node(0 for the launcher default), the
+// mapping/binding policy(empty for the launcher default) and the
+// environment variant.
+type OsuBenchStats struct {
+    Benchmark   string         `json:"benchmark"`
+    NP          uint           `json:"np"`
+    PPN         uint           `json:"ppn"`
+    MapBy       string         `json:"mapby,omitempty"`
+    BindTo      string         `json:"bindto,omitempty"`
+    Variant     string         `json:"variant,omitempty"`
+    Hosts       []string       `json:"hosts,omitempty"`
+    Metric      string         `json:"metric"`
+    Repetitions int            `json:"repetitions"`
+    Values      []OsuSizeStats `json:"values"`
+}
+
+//Percentile :- p'th percentile of sorted samples, linear interpolation
+// between the closest ranks.
+func Percentile(sorted []float64, p float64) float64 {
+    if len(sorted) == 0 {
+        return 0
+    }
+    rank := p / 100 * float64(len(sorted)-1)
+    lower := int(math.Floor(rank))
+    upper := int(math.Ceil(rank))
+    fraction := rank - float64(lower)
+    return sorted[lower] + fraction*(sorted[upper]-sorted[lower])
+}
+
+//ComputeSizeStats :- Aggregate the samples of a message size.
+func ComputeSizeStats(pktsize int, samples []float64) OsuSizeStats {
+    var stats OsuSizeStats
+    stats.Pktsize = pktsize
+    stats.Samples = samples
+    if len(samples) == 0 {
+        return stats
+    }
+    sorted := make([]float64, len(samples))
+    copy(sorted, samples)
+    sort.Float64s(sorted)
+    sum := 0.0
+    for _, sample := range sorted {
+        sum += sample
+    }
+    count := float64(len(sorted))
+    stats.Mean = sum / count
+    stats.Min = sorted[0]
+    stats.Max = sorted[len(sorted)-1]
+    stats.Median = Percentile(sorted, 50)
+    stats.P95 = Percentile(sorted, 95)
+    stats.CILow, stats.CIHigh = stats.Mean, stats.Mean
+    if len(sorted) < 2 {
+        return stats
+    }
+    variance := 0.0
+    for _, sample := range sorted {
+        variance += (sample - stats.Mean) * (sample - stats.Mean)
+    }
+    stats.Stddev = math.Sqrt(variance / (count - 1))
+    critical := zCritical95
+    if len(sorted)-1 < len(tCritical95) {
+        critical = tCritical95[len(sorted)-1]
+    }
+    margin := critical * stats.Stddev / math.Sqrt(count)
+    stats.CILow, stats.CIHigh = stats.Mean-margin, stats.Mean+margin
+    return stats
+}
+
+// Metric name of a column, OSU header name or position when the
+// result doesn't have a header.
+func statsColumnName(table *OsuTable, idx int) string {
+    if idx < len(table.Columns) {
+        return table.Columns[idx]
+    }
+    return fmt.Sprintf("column%d", idx+1)
+}
+
+// Key of the statistics group of a run.
+type statsGroupKey struct {
+    Benchmark string
+    NP        uint
+    PPN       uint
+    MapBy     string
+    BindTo    string
+    Variant   string
+    Hosts     string
+}
+
+//ComputeStatistics :- Statistics of all the successful runs of the
+// records, records are grouped by (benchmark, np, ppn, mapping, binding,
+// variant) and by the host pair in the pair matrix mode.
+func (txt2jsonObj *Text2Json) ComputeStatistics(
+    records []*testRunner.OSU_run_record) []OsuBenchStats {
+    results := make([]OsuBenchStats, 0)
+    groups := make(map[statsGroupKey][]*testRunner.OSU_run_record)
+    groupOrder := make([]statsGroupKey, 0)
+    groupHosts := make(map[statsGroupKey][]string)
+    for _, record := range records {
+        if record.Status != testRunner.RUN_STATUS_SUCCESS {
+            continue
+        }
+        key := statsGroupKey{record.Benchmark, record.NP, record.PPN,
+            record.MapBy, record.BindTo, record.Variant,
+            strings.Join(record.Hosts, ",")}
+        if _, ok := groups[key]; !ok {
+            groupOrder = append(groupOrder, key)
+            groupHosts[key] = record.Hosts
+        }
+        groups[key] = append(groups[key], record)
+    }
+    for _, key := range groupOrder {
+        benchStats := txt2jsonObj.ComputeBenchStatistics(key.Benchmark,
+            groups[key])
+        for idx := range benchStats {
+            benchStats[idx].NP = key.NP
+            benchStats[idx].PPN = key.PPN
+            benchStats[idx].MapBy = key.MapBy
+            benchStats[idx].BindTo = key.BindTo
+            benchStats[idx].Variant = key.Variant
+            benchStats[idx].Hosts = groupHosts[key]
+        }
+        results = append(results, benchStats...)
+    }
+    return results
+}
+
+//ComputeBenchStatistics :- Statistics of every value column of a
+// benchmark across its runs. Size and iteration columns are not metrics.
+func (txt2jsonObj *Text2Json) ComputeBenchStatistics(benchName string,
+    records []*testRunner.OSU_run_record) []OsuBenchStats {
+    metrics := make([]string, 0)
+    // metric -> pktsize -> samples
+    samples := make(map[string]map[int][]float64)
+    sizeOrder := make(map[string][]int)
+    repetitions := 0
+    for _, record := range records {
+        table, err := ReadOSUTable(
+            txt2jsonObj.ResultFilePath(record.StdoutFile))
+        if err != errors.OP_SUCCESS || len(table.Rows) == 0 {
+            continue
+        }
+        repetitions++
+        sizeIdx := table.ColumnIndex("Size")
+        iterIdx := table.ColumnIndex("Iterations")
+        if table.Columns == nil && len(table.Rows[0]) > 1 {
+            sizeIdx = 0
+        }
+        for _, row := range table.Rows {
+            pktsize := int(table.Value(row, sizeIdx))
+            for idx, value := range row {
+                if idx == sizeIdx || idx == iterIdx {
+                    continue
+                }
+                metric := statsColumnName(table, idx)
+                if _, ok := samples[metric]; !ok {
+                    metrics = append(metrics, metric)
+                    samples[metric] = make(map[int][]float64)
+                }
+                if _, ok := samples[metric][pktsize]; !ok {
+                    sizeOrder[metric] = append(sizeOrder[metric], pktsize)
+                }
+                samples[metric][pktsize] =
+                    append(samples[metric][pktsize], value)
+            }
+        }
+    }
+    results := make([]OsuBenchStats, 0, len(metrics))
+    for _, metric := range metrics {
+        var benchStats OsuBenchStats
+        benchStats.Benchmark = benchName
+        benchStats.Metric = metric
+        benchStats.Repetitions = repetitions
+        for _, pktsize := range sizeOrder[metric] {
+            benchStats.Values = append(benchStats.Values,
+                ComputeSizeStats(pktsize, samples[metric][pktsize]))
+        }
+        results = append(results, benchStats)
+    }
+    return results
+}
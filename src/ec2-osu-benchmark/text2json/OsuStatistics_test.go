@@ -0,0 +1,153 @@
+package text2json
+
+import (
+    "math"
+    "reflect"
+    "testing"
+    "ec2-osu-benchmark/testRunner"
+)
+
+func floatsEqual(a float64, b float64) bool {
+    return math.Abs(a-b) < 1e-6
+}
+
+func TestPercentile(t *testing.T) {
+    sorted := []float64{1, 2, 3, 4}
+    tests := []struct {
+        sorted []float64
+        p      float64
+        want   float64
+    }{
+        {nil, 50, 0},
+        {[]float64{7}, 95, 7},
+        {sorted, 0, 1},
+        {sorted, 100, 4},
+        // rank 1.5, between 2 and 3
+        {sorted, 50, 2.5},
+        // rank 2.85, 3 + 0.85 * (4 - 3)
+        {sorted, 95, 3.85},
+    }
+    for _, test := range tests {
+        if value := Percentile(test.sorted, test.p); !floatsEqual(value,
+            test.want) {
+            t.Errorf("p%g of %v is %g, want %g", test.p, test.sorted, value,
+                test.want)
+        }
+    }
+}
+
+func TestComputeSizeStats(t *testing.T) {
+    sequence := func(n int) []float64 {
+        samples := make([]float64, n)
+        for idx := range samples {
+            samples[idx] = float64(idx + 1)
+        }
+        return samples
+    }
+    tests := []struct {
+        name    string
+        samples []float64
+        want    OsuSizeStats
+    }{
+        {"none", []float64{}, OsuSizeStats{}},
+        // No spread with a single sample, the interval is the mean.
+        {"n=1", []float64{5}, OsuSizeStats{Mean: 5, Median: 5, Min: 5,
+            Max: 5, P95: 5, CILow: 5, CIHigh: 5}},
+        // stddev sqrt(2), margin 12.706 * sqrt(2) / sqrt(2)
+        {"n=2", []float64{4, 2}, OsuSizeStats{Mean: 3, Median: 3, Min: 2,
+            Max: 4, Stddev: math.Sqrt2, P95: 3.9, CILow: 3 - 12.706,
+            CIHigh: 3 + 12.706}},
+        {"odd count", []float64{3, 1, 2}, OsuSizeStats{Mean: 2, Median: 2,
+            Min: 1, Max: 3, Stddev: 1, P95: 2.9, CILow: 2 - 4.303/math.Sqrt(3),
+            CIHigh: 2 + 4.303/math.Sqrt(3)}},
+        // stddev sqrt(5/3), margin 3.182 * 1.290994 / 2
+        {"even count", []float64{4, 1, 3, 2}, OsuSizeStats{Mean: 2.5,
+            Median: 2.5, Min: 1, Max: 4, Stddev: 1.290994, P95: 3.85,
+            CILow: 0.446028, CIHigh: 4.553972}},
+        // 30 degrees of freedom, the last row of the table(2.042).
+        // variance n(n+1)/12
+        {"n=31", sequence(31), OsuSizeStats{Mean: 16, Median: 16, Min: 1,
+            Max: 31, Stddev: 9.092121, P95: 29.5, CILow: 12.665428,
+            CIHigh: 19.334572}},
+        // 31 degrees of freedom, past the table, z = 1.960
+        {"n=32", sequence(32), OsuSizeStats{Mean: 16.5, Median: 16.5,
+            Min: 1, Max: 32, Stddev: 9.380832, P95: 30.45,
+            CILow: 13.249708, CIHigh: 19.750292}},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            stats := ComputeSizeStats(64, test.samples)
+            if stats.Pktsize != 64 ||
+                !reflect.DeepEqual(stats.Samples, test.samples) {
+                t.Errorf("unexpected size %d or samples %v", stats.Pktsize,
+                    stats.Samples)
+            }
+            got := []float64{stats.Mean, stats.Median, stats.Min, stats.Max,
+                stats.Stddev, stats.P95, stats.CILow, stats.CIHigh}
+            want := []float64{test.want.Mean, test.want.Median,
+                test.want.Min, test.want.Max, test.want.Stddev,
+                test.want.P95, test.want.CILow, test.want.CIHigh}
+            for idx := range got {
+                if !floatsEqual(got[idx], want[idx]) {
+                    t.Errorf("mean/median/min/max/stddev/p95/cilow/cihigh "+
+                        "%v, want %v", got, want)
+                    break
+                }
+            }
+        })
+    }
+    // The samples are reported in the run order.
+    samples := []float64{4, 1, 3, 2}
+    ComputeSizeStats(1, samples)
+    if !reflect.DeepEqual(samples, []float64{4, 1, 3, 2}) {
+        t.Errorf("samples are reordered %v", samples)
+    }
+}
+
+func TestComputeBenchStatistics(t *testing.T) {
+    header := "# OSU MPI Allreduce Latency Test v5.6.2\n" +
+        "# Size       Avg Latency(us)   Min Latency(us)   " +
+        "Max Latency(us)  Iterations\n"
+    files := map[string]string{
+        "osu_allreduce.rep1.txt": header +
+            "4                       2.00              1.80" +
+            "              2.20        1000\n" +
+            "8                       3.00              2.80" +
+            "              3.20        1000\n",
+        "osu_allreduce.rep2.txt": header +
+            "4                       4.00              3.80" +
+            "              4.20        1000\n" +
+            "8                       5.00              4.80" +
+            "              5.20        1000\n"}
+    records := []*testRunner.OSU_run_record{
+        {Benchmark: "osu_allreduce", StdoutFile: "osu_allreduce.rep1.txt"},
+        {Benchmark: "osu_allreduce", StdoutFile: "osu_allreduce.rep2.txt"},
+        // The output of a failed repetition is missing
+        {Benchmark: "osu_allreduce", StdoutFile: "osu_allreduce.rep3.txt"}}
+    txt2jsonObj := newTestText2Json(t, files, records)
+    results := txt2jsonObj.ComputeBenchStatistics("osu_allreduce",
+        txt2jsonObj.jsonResults.Runs)
+    metrics := []string{"Avg Latency(us)", "Min Latency(us)",
+        "Max Latency(us)"}
+    if len(results) != len(metrics) {
+        t.Fatalf("statistics of %d metrics, want %d", len(results),
+            len(metrics))
+    }
+    // Means of the two repetitions, n=2
+    means := [][]float64{{3, 4}, {2.8, 3.8}, {3.2, 4.2}}
+    for idx, benchStats := range results {
+        if benchStats.Benchmark != "osu_allreduce" ||
+            benchStats.Metric != metrics[idx] ||
+            benchStats.Repetitions != 2 || len(benchStats.Values) != 2 {
+            t.Fatalf("unexpected statistics %+v", benchStats)
+        }
+        for sizeIdx, pktsize := range []int{4, 8} {
+            stats := benchStats.Values[sizeIdx]
+            if stats.Pktsize != pktsize || len(stats.Samples) != 2 ||
+                !floatsEqual(stats.Mean, means[idx][sizeIdx]) ||
+                !floatsEqual(stats.Stddev, math.Sqrt2) {
+                t.Errorf("unexpected %s statistics %+v", metrics[idx], stats)
+            }
+        }
+    }
+}
@@ -0,0 +1,118 @@
+package text2json
+
+import (
+    "bufio"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/logging"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// Columns in the OSU header line are separated by two or more spaces,
+// a single space is part of the column name. ex: "Avg Latency(us)"
+var osuColumnSeparator = regexp.MustCompile(`\s{2,}`)
+
+// Title line of the OSU benchmarks, the version is the last word.
+// ex: "OSU MPI Allreduce Latency Test v5.6.2"
+var osuHeader = regexp.MustCompile(`^OSU\s.*\sTest\s+(v?[0-9][0-9A-Za-z.\-]*)$`)
+
+//OsuTable :- Generic representation of an OSU result file.
+// # OSU MPI Allreduce Latency Test v5.6.2
+// # Size       Avg Latency(us)   Min Latency(us)   Max Latency(us)  Iterations
+// 4                       1.98              1.85              2.11        1000
+//
+// Comments :- all the '#' lines, without the leading '#'
+// Columns  :- column names from the header line right above the data rows
+// Rows     :- numeric data rows
+type OsuTable struct {
+    Comments []string
+    Columns  []string
+    Rows     [][]float64
+}
+
+//ReadOSUTable :- Read any OSU result file to a OsuTable. Lines that
+// are not numeric(mpirun warnings, partial lines) are skipped.
+func ReadOSUTable(fileName string) (*OsuTable, error) {
+    logger := logging.GetLoggerInstance()
+    fileName = strings.Trim(fileName, "\n")
+
+    file, err := os.Open(fileName)
+    if err != nil {
+        logger.Error("Failed to open file %s", fileName)
+        return nil, err
+    }
+    defer file.Close()
+
+    table := new(OsuTable)
+    table.Comments = make([]string, 0)
+    table.Rows = make([][]float64, 0)
+    lastComment := ""
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if strings.HasPrefix(line, "#") {
+            lastComment = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+            table.Comments = append(table.Comments, lastComment)
+            continue
+        }
+        fields := strings.Fields(line)
+        if len(fields) == 0 {
+            continue
+        }
+        row := make([]float64, len(fields))
+        valid := true
+        for idx, field := range fields {
+            row[idx], err = strconv.ParseFloat(field, 64)
+            if err != nil {
+                valid = false
+                break
+            }
+        }
+        if !valid {
+            continue
+        }
+        if table.Columns == nil && len(lastComment) != 0 {
+            table.Columns = osuColumnSeparator.Split(lastComment, -1)
+        }
+        // Partial line, the job was killed while writing it.
+        if table.Columns != nil && len(row) < len(table.Columns) {
+            continue
+        }
+        table.Rows = append(table.Rows, row)
+    }
+    return table, errors.OP_SUCCESS
+}
+
+//ColumnIndex :- Index of the first column whose name contains any of
+// the given names, -1 if the table doesn't have such column.
+func (table *OsuTable) ColumnIndex(names ...string) int {
+    for idx, column := range table.Columns {
+        for _, name := range names {
+            if strings.Contains(column, name) {
+                return idx
+            }
+        }
+    }
+    return -1
+}
+
+//Version :- OSU version from the title line of the result, empty when
+// the result doesn't have it.
+func (table *OsuTable) Version() string {
+    for _, comment := range table.Comments {
+        if match := osuHeader.FindStringSubmatch(comment); match != nil {
+            return match[1]
+        }
+    }
+    return ""
+}
+
+//Value :- Value of the column 'idx' in a row, 0 when not present.
+func (table *OsuTable) Value(row []float64, idx int) float64 {
+    if idx < 0 || idx >= len(row) {
+        return 0
+    }
+    return row[idx]
+}
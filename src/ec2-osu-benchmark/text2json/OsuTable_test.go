@@ -0,0 +1,116 @@
+package text2json
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "ec2-osu-benchmark/errors"
+)
+
+// Result file with the content in a test directory.
+func writeResultFile(t *testing.T, name string, content string) string {
+    t.Helper()
+    fileName := filepath.Join(t.TempDir(), name)
+    if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+    return fileName
+}
+
+func TestReadOSUTable(t *testing.T) {
+    tests := []struct {
+        name     string
+        content  string
+        version  string
+        columns  []string
+        rows     [][]float64
+    }{
+        {"header",
+            "# OSU MPI Latency Test v5.6.2\n" +
+            "# Size          Latency (us)\n" +
+            "0                       1.62\n" +
+            "1                       1.64\n" +
+            "4194304              1163.48\n",
+            "v5.6.2", []string{"Size", "Latency (us)"},
+            [][]float64{{0, 1.62}, {1, 1.64}, {4194304, 1163.48}}},
+        {"full stats header",
+            "\n# OSU MPI Allreduce Latency Test v7.1\n" +
+            "# Size       Avg Latency(us)   Min Latency(us)   " +
+            "Max Latency(us)  Iterations\n" +
+            "4                       1.98              1.85" +
+            "              2.11        1000\n",
+            "v7.1", []string{"Size", "Avg Latency(us)", "Min Latency(us)",
+                "Max Latency(us)", "Iterations"},
+            [][]float64{{4, 1.98, 1.85, 2.11, 1000}}},
+        {"no header",
+            "0                       1.62\n1                       1.64\n",
+            "", nil, [][]float64{{0, 1.62}, {1, 1.64}}},
+        // mpirun warnings, a job killed in the middle of a line and
+        // a non-numeric value are skipped.
+        {"malformed lines",
+            "# OSU MPI Bandwidth Test v5.6.2\n" +
+            "# Size      Bandwidth (MB/s)\n" +
+            "[ip-10-0-0-1:01234] 1 more process has sent help message " +
+            "help-mpi-btl-openib.txt / no device params found\n" +
+            "1                       0.48\n" +
+            "2                       nan(0x8)x\n" +
+            "4                       1.9\n" +
+            "8       \n" +
+            "16   3.8   mpirun: Forwarding signal 15 to job\n",
+            "v5.6.2", []string{"Size", "Bandwidth (MB/s)"},
+            [][]float64{{1, 0.48}, {4, 1.9}}},
+        {"empty", "", "", nil, [][]float64{}},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            table, err := ReadOSUTable(
+                writeResultFile(t, "osu_test.txt", test.content) + "\n")
+            if err != errors.OP_SUCCESS {
+                t.Fatalf("ReadOSUTable failed, err : %s", err)
+            }
+            if table.Version() != test.version {
+                t.Errorf("version %q, want %q", table.Version(), test.version)
+            }
+            if !reflect.DeepEqual(table.Columns, test.columns) {
+                t.Errorf("columns %q, want %q", table.Columns, test.columns)
+            }
+            if !reflect.DeepEqual(table.Rows, test.rows) {
+                t.Errorf("rows %v, want %v", table.Rows, test.rows)
+            }
+        })
+    }
+}
+
+func TestReadOSUTableMissingFile(t *testing.T) {
+    _, err := ReadOSUTable(filepath.Join(t.TempDir(), "osu_latency.txt"))
+    if err == errors.OP_SUCCESS || err == nil {
+        t.Errorf("missing file is read")
+    }
+}
+
+func TestOsuTableColumns(t *testing.T) {
+    table := &OsuTable{Columns: []string{"Size", "Avg Latency(us)",
+        "Min Latency(us)", "Max Latency(us)", "Iterations"}}
+    tests := []struct {
+        names []string
+        idx   int
+    }{
+        {[]string{"Size"}, 0},
+        {[]string{"Avg Latency", "Latency"}, 1},
+        // First column with any of the names
+        {[]string{"Max Latency", "Min Latency"}, 2},
+        {[]string{"Latency"}, 1},
+        {[]string{"Bandwidth"}, -1},
+    }
+    for _, test := range tests {
+        if idx := table.ColumnIndex(test.names...); idx != test.idx {
+            t.Errorf("column of %q is %d, want %d", test.names, idx, test.idx)
+        }
+    }
+    row := []float64{4, 1.98}
+    if table.Value(row, 1) != 1.98 || table.Value(row, 2) != 0 ||
+        table.Value(row, -1) != 0 {
+        t.Errorf("unexpected values of %v", row)
+    }
+}
@@ -0,0 +1,48 @@
+package text2json
+
+import (
+    "ec2-osu-benchmark/testRunner"
+    "path/filepath"
+    "strings"
+)
+
+// Runs with host noise during the benchmark(-telemetry), the warnings of
+// their telemetry summaries. The summaries of all the runs are in "Runs".
+// {
+//     "NoisyRuns": [
+//         {
+//             "run": "osu_latency.rep3",
+//             "benchmark": "osu_latency",
+//             "host": "ip-10-0-0-1",
+//             "warnings": ["steal time 4.2%, peak 11.0%"]
+//         }
+//     ]
+// }
+
+//OsuNoisyRun :- Run of a benchmark with host noise.
+type OsuNoisyRun struct {
+    Run       string   `json:"run"`
+    Benchmark string   `json:"benchmark"`
+    // Host of the telemetry, the launcher host
+    Host      string   `json:"host"`
+    Warnings  []string `json:"warnings"`
+}
+
+//ComputeNoisyRuns :- Runs with warnings in their telemetry summary, in
+// the order of the records.
+func (txt2jsonObj *Text2Json) ComputeNoisyRuns(
+    records []*testRunner.OSU_run_record) []OsuNoisyRun {
+    noisyRuns := make([]OsuNoisyRun, 0)
+    for _, record := range records {
+        if record.Telemetry == nil || len(record.Telemetry.Warnings) == 0 {
+            continue
+        }
+        noisyRuns = append(noisyRuns, OsuNoisyRun{
+            Run: strings.TrimSuffix(filepath.Base(record.StdoutFile),
+                                    ".txt"),
+            Benchmark: record.Benchmark,
+            Host: record.Telemetry.Host,
+            Warnings: record.Telemetry.Warnings})
+    }
+    return noisyRuns
+}
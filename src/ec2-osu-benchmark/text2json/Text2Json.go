@@ -5,10 +5,13 @@ import (
     "ec2-osu-benchmark/config"
     "ec2-osu-benchmark/errors"
     "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/testRunner"
     "encoding/json"
     "fmt"
     "io/ioutil"
     "os"
+    "path/filepath"
+    "sort"
     "strconv"
     "strings"
     "time"
@@ -60,9 +63,29 @@ type OsuLatency []OsuLatencyTuple
 // New test results should be added here to export
 type OSUResults struct {
     Timestamp  time.Time `json:"timestamp"`
+    // Sortable ID of the run
+    RunID string `json:"RunID,omitempty"`
+    // Hardware, kernel, MPI, OSU, configuration and instance of the run
+    Manifest *testRunner.OSU_manifest `json:"Manifest,omitempty"`
+    // OSU version of the benchmarks, from the title of the results
+    OSUVersion string `json:"OSUVersion,omitempty"`
     OsuBW      `json:"OsuBW"`
     OsuBiBW    `json:"OsuBiBW"`
     OsuLatency `json:"OsuLatency"`
+    // Collective results, keyed by the benchmark name
+    OsuCollective map[string]OsuCollective `json:"OsuCollective,omitempty"`
+    // One-sided results, keyed by the benchmark name
+    OsuOneSided map[string]*OsuOneSidedResult `json:"OsuOneSided,omitempty"`
+    OsuMultiBW      *OsuMultiBW `json:"OsuMultiBW,omitempty"`
+    OsuMultiLatency OsuLatency  `json:"OsuMultiLatency,omitempty"`
+    // How each benchmark is run, command line and OSU options
+    Runs []*testRunner.OSU_run_record `json:"Runs,omitempty"`
+    // Raw samples and aggregates across the repetitions
+    Statistics []OsuBenchStats `json:"Statistics,omitempty"`
+    // Host pair matrix of the pair matrix mode
+    HostMatrix *OsuHostMatrix `json:"HostMatrix,omitempty"`
+    // Runs with host noise, from the host telemetry
+    NoisyRuns []OsuNoisyRun `json:"NoisyRuns,omitempty"`
 }
 
 //Text2Json :- Structure + methods to generate matric
@@ -72,6 +95,8 @@ type Text2Json struct {
     filelist    []string
     jsonResults *OSUResults
     configObj   *config.AppConfig
+    registry    *testRunner.OSU_benchmark_registry
+    resPath     string
 }
 
 //GetAllFiles :- Function to collect all the OSU test result files.
@@ -83,29 +108,67 @@ func (txt2jsonObj *Text2Json) GetAllFiles(path string) error {
         logger.Error("Failed to get all the results files in %s", path)
         return err
     }
-    txt2jsonObj.filelist = make([]string, len(fileNames))
-    for idx, f := range fileNames {
-        txt2jsonObj.filelist[idx] = path + f.Name()
+    txt2jsonObj.filelist = make([]string, 0, len(fileNames))
+    for _, f := range fileNames {
+        if !strings.HasSuffix(f.Name(), ".txt") {
+            // Run records and reports are not OSU results.
+            continue
+        }
+        txt2jsonObj.filelist = append(txt2jsonObj.filelist, path+f.Name())
     }
     return errors.OP_SUCCESS
 }
 
 //IsLatencyFile :- Function to check osu result file contain
-// latency data. We use the file name to identify latency results.
+// latency data. We use the benchmark of the file to identify latency
+// results.
 func (txt2jsonObj *Text2Json) IsLatencyFile(fileName string) bool {
-    return strings.Contains(fileName, "osu_latency")
+    return txt2jsonObj.BenchmarkName(fileName) == "osu_latency"
 }
 
 //IsBWFile :- Check if a OSU result file is a bandwidth file.
-// Use file name to identify file contain bandwidth results.
+// Use the benchmark of the file to identify bandwidth results.
 func (txt2jsonObj *Text2Json) IsBWFile(fileName string) bool {
-    return strings.Contains(fileName, "osu_bw")
+    return txt2jsonObj.BenchmarkName(fileName) == "osu_bw"
 }
 
 //IsBiBWFile :- Check if OSU result file set have a bidirectional
 // bandwidth test results.
 func (txt2jsonObj *Text2Json) IsBiBWFile(fileName string) bool {
-    return strings.Contains(fileName, "osu_bibw")
+    return txt2jsonObj.BenchmarkName(fileName) == "osu_bibw"
+}
+
+//BenchmarkName :- Name of the benchmark that produced the result file,
+// from the run record. Result files of older runs without run records are
+// named <benchmark>[.<run parameters>].txt, the directories of the file
+// are not looked at.
+func (txt2jsonObj *Text2Json) BenchmarkName(fileName string) string {
+    if record := txt2jsonObj.GetRunRecord(fileName); record != nil {
+        return record.Benchmark
+    }
+    name := strings.TrimSuffix(filepath.Base(fileName), ".txt")
+    return strings.SplitN(name, ".", 2)[0]
+}
+
+//BenchmarkCategory :- Category of the benchmark that produced the
+// result file, from the run record or the benchmark registry.
+// Empty for unknown benchmarks.
+func (txt2jsonObj *Text2Json) BenchmarkCategory(fileName string) string {
+    if record := txt2jsonObj.GetRunRecord(fileName); record != nil {
+        return record.Category
+    }
+    bench, err := txt2jsonObj.registry.Get(txt2jsonObj.BenchmarkName(fileName))
+    if err != errors.OP_SUCCESS {
+        return ""
+    }
+    return bench.Category
+}
+
+//IsCollectiveFile :- Check if OSU result file is produced by a
+// collective benchmark.
+func (txt2jsonObj *Text2Json) IsCollectiveFile(fileName string) bool {
+    return txt2jsonObj.BenchmarkCategory(fileName) ==
+        testRunner.OSU_CATEGORY_COLLECTIVE
 }
 
 //ReadOSUBWFile :- Generic function to read the OSU BW result set.
@@ -137,7 +200,12 @@ func (txt2jsonObj *Text2Json) ReadOSUBWFile(fileName string) (
             continue
         }
         var bwtuple OsuBWTuple
-        bwtuple.Pktsize, _ = strconv.Atoi(lineArr[0])
+        var perr error
+        bwtuple.Pktsize, perr = strconv.Atoi(lineArr[0])
+        if perr != nil {
+            // Launcher output(e.g. binding report), not a result line
+            continue
+        }
         bwtuple.Bw, _ = strconv.ParseFloat(lineArr[1], 64)
         bwresults = append(bwresults, bwtuple)
     }
@@ -171,7 +239,12 @@ func (txt2jsonObj *Text2Json) ReadOSULatencyFile(fileName string,
             continue
         }
         var latTuple OsuLatencyTuple
-        latTuple.Pktsize, _ = strconv.Atoi(lineArr[0])
+        var perr error
+        latTuple.Pktsize, perr = strconv.Atoi(lineArr[0])
+        if perr != nil {
+            // Launcher output(e.g. binding report), not a result line
+            continue
+        }
         latTuple.Latency, _ = strconv.ParseFloat(lineArr[1], 64)
         latencyTupleSet =
             append(latencyTupleSet, latTuple)
@@ -185,31 +258,147 @@ func (txt2jsonObj *Text2Json) ReadOSULatencyFile(fileName string,
 func (txt2jsonObj *Text2Json) SetupApolloEnv() {
     var err error
     logger := logging.GetLoggerInstance()
-    err = os.MkdirAll(config.DEFAULT_APOLLO_ENV_DIR, os.ModePerm)
+    err = os.MkdirAll(txt2jsonObj.configObj.MetricDir, os.ModePerm)
     if err != nil {
         logger.Error("Failed to create/open apollo dir : %s, matric push may fail"+
-            " err : %s", config.DEFAULT_APOLLO_ENV_DIR, err)
+            " err : %s", txt2jsonObj.configObj.MetricDir, err)
     }
 }
 
 //Init :- Must be called this function as constructor before using
 // any functinalities of matric file generator.
 func (txt2jsonObj *Text2Json) Init(configObj *config.AppConfig, resPath string) {
+    var err error
+    logger := logging.GetLoggerInstance()
     txt2jsonObj.GetAllFiles(resPath)
+    txt2jsonObj.registry, err = testRunner.Get_OSU_benchmark_registry(configObj)
+    if err != errors.OP_SUCCESS {
+        logger.Warning("Failed to build benchmark registry, using only " +
+            "the builtin benchmarks")
+        txt2jsonObj.registry = new(testRunner.OSU_benchmark_registry)
+        txt2jsonObj.registry.Init()
+        txt2jsonObj.registry.RegisterBuiltins()
+    }
     txt2jsonObj.jsonResults = new(OSUResults)
-    txt2jsonObj.jsonFile = resPath + "osu-report.json"
+    txt2jsonObj.jsonResults.OsuCollective = make(map[string]OsuCollective)
+    txt2jsonObj.jsonResults.OsuOneSided = make(map[string]*OsuOneSidedResult)
+    txt2jsonObj.jsonFile = resPath + config.DEFAULT_REPORT_FILE
+    txt2jsonObj.resPath = resPath
     txt2jsonObj.configObj = configObj
     txt2jsonObj.SetupApolloEnv()
 }
 
+//ReadRunRecords :- Read how the benchmarks are run to the json results.
+func (txt2jsonObj *Text2Json) ReadRunRecords() error {
+    records, err := testRunner.Read_run_records(txt2jsonObj.resPath)
+    if err != errors.OP_SUCCESS {
+        return err
+    }
+    txt2jsonObj.jsonResults.Runs = records
+    return errors.OP_SUCCESS
+}
+
+//ReadManifest :- Environment of the run from its manifest, older runs
+// don't have a manifest. The OSU version of the results is used when the
+// binaries don't have it.
+func (txt2jsonObj *Text2Json) ReadManifest() {
+    fileName := txt2jsonObj.resPath + config.MANIFEST_FILE
+    if _, err := os.Stat(fileName); err != nil {
+        return
+    }
+    manifest, err := testRunner.Read_manifest(fileName)
+    if err != errors.OP_SUCCESS {
+        return
+    }
+    if len(manifest.OSU.Version) == 0 {
+        manifest.OSU.Version = txt2jsonObj.jsonResults.OSUVersion
+    }
+    txt2jsonObj.jsonResults.Manifest = manifest
+}
+
+//ReadOSUVersions :- OSU version of every run from the title line of its
+// result. The report has the version of the first result, results of
+// other versions are logged.
+func (txt2jsonObj *Text2Json) ReadOSUVersions() {
+    logger := logging.GetLoggerInstance()
+    setVersion := func(fileName string, version string) {
+        if len(version) == 0 {
+            return
+        }
+        if len(txt2jsonObj.jsonResults.OSUVersion) == 0 {
+            txt2jsonObj.jsonResults.OSUVersion = version
+        } else if txt2jsonObj.jsonResults.OSUVersion != version {
+            logger.Warning("%s is from OSU %s, other results are from %s",
+                fileName, version, txt2jsonObj.jsonResults.OSUVersion)
+        }
+    }
+    for _, record := range txt2jsonObj.jsonResults.Runs {
+        table, err := ReadOSUTable(
+            txt2jsonObj.ResultFilePath(record.StdoutFile))
+        if err != errors.OP_SUCCESS {
+            continue
+        }
+        record.OSUVersion = table.Version()
+        setVersion(record.StdoutFile, record.OSUVersion)
+    }
+    if len(txt2jsonObj.jsonResults.Runs) != 0 {
+        return
+    }
+    // Results of older runs don't have run records.
+    for _, fileName := range txt2jsonObj.filelist {
+        table, err := ReadOSUTable(fileName)
+        if err == errors.OP_SUCCESS {
+            setVersion(fileName, table.Version())
+        }
+    }
+}
+
+//GetRunRecord :- Run record of the run that produced the result
+// file, nil if the run is not recorded.
+func (txt2jsonObj *Text2Json) GetRunRecord(
+    fileName string) *testRunner.OSU_run_record {
+    for _, record := range txt2jsonObj.jsonResults.Runs {
+        if filepath.Base(record.StdoutFile) == filepath.Base(fileName) {
+            return record
+        }
+    }
+    return nil
+}
+
+//ResultFilePath :- Path of a recorded result file in the result
+// directory, the directory may have been moved after the run.
+func (txt2jsonObj *Text2Json) ResultFilePath(fileName string) string {
+    return filepath.Join(txt2jsonObj.resPath, filepath.Base(fileName))
+}
+
+//IsPrimaryRun :- The typed result sections have the results of the
+// first repetition of the first np/ppn configuration, the others are
+// part of the statistics only.
+func (txt2jsonObj *Text2Json) IsPrimaryRun(fileName string) bool {
+    record := txt2jsonObj.GetRunRecord(fileName)
+    return record == nil || record.Primary
+}
+
 //Read2JsonStruct :- Reading the results to json format
 func (txt2jsonObj *Text2Json) Read2JsonStruct() {
     var err error
     logger := logging.GetLoggerInstance()
+    txt2jsonObj.ReadRunRecords()
+    txt2jsonObj.ReadOSUVersions()
+    txt2jsonObj.ReadManifest()
+    txt2jsonObj.jsonResults.Statistics =
+        txt2jsonObj.ComputeStatistics(txt2jsonObj.jsonResults.Runs)
+    txt2jsonObj.jsonResults.HostMatrix =
+        txt2jsonObj.ComputeHostMatrix(txt2jsonObj.jsonResults.Runs)
+    txt2jsonObj.jsonResults.NoisyRuns =
+        txt2jsonObj.ComputeNoisyRuns(txt2jsonObj.jsonResults.Runs)
     var latencyResults OsuLatency
     var bwresults []OsuBWTuple
     var bibwresults []OsuBWTuple
     for _, fileName := range txt2jsonObj.filelist {
+        if !txt2jsonObj.IsPrimaryRun(fileName) {
+            continue
+        }
         if txt2jsonObj.IsLatencyFile(fileName) {
             // Process only latency files
             txt2jsonObj.ReadOSULatencyFile(fileName, &latencyResults)
@@ -231,22 +420,75 @@ func (txt2jsonObj *Text2Json) Read2JsonStruct() {
                 txt2jsonObj.jsonResults.OsuBiBW = bibwresults
             }
         }
+        if txt2jsonObj.IsCollectiveFile(fileName) {
+            collresults, err := txt2jsonObj.ReadOSUCollectiveFile(fileName)
+            if err == errors.OP_SUCCESS {
+                benchName := txt2jsonObj.BenchmarkName(fileName)
+                txt2jsonObj.jsonResults.OsuCollective[benchName] = collresults
+                logger.Info("Processing of %s results is complete", benchName)
+            }
+        }
+        if txt2jsonObj.IsMultiBWFile(fileName) {
+            mbwresults, err := txt2jsonObj.ReadOSUMultiBWFile(fileName)
+            if err == errors.OP_SUCCESS {
+                txt2jsonObj.jsonResults.OsuMultiBW = mbwresults
+            }
+        }
+        if txt2jsonObj.IsMultiLatencyFile(fileName) {
+            var multiLatResults OsuLatency
+            err = txt2jsonObj.ReadOSULatencyFile(fileName, &multiLatResults)
+            if err == errors.OP_SUCCESS {
+                txt2jsonObj.jsonResults.OsuMultiLatency = multiLatResults
+            }
+        }
+        if txt2jsonObj.IsOneSidedFile(fileName) {
+            rmaresults, err := txt2jsonObj.ReadOSUOneSidedFile(fileName)
+            if err == errors.OP_SUCCESS {
+                benchName := txt2jsonObj.BenchmarkName(fileName)
+                txt2jsonObj.jsonResults.OsuOneSided[benchName] = rmaresults
+                logger.Info("Processing of %s results is complete", benchName)
+            }
+        }
     }
 }
 
-//WriteTimestamp :- Write current timestamp to the output file
+//WriteTimestamp :- Write the start time and the ID of the run, from the run
+// info in the result directory. Results of older runs without it use the
+// start of the campaign.
 func (txt2jsonObj *Text2Json) WriteTimestamp() error {
-    var err error
-    fileSet := strings.Split(txt2jsonObj.jsonFile, "/")
-    timestr := fileSet[len(fileSet)-3]
-    txt2jsonObj.jsonResults.Timestamp, err = time.Parse(config.DEFAULT_TIME_LAYOUT,
-        timestr)
-    if err == nil {
+    logger := logging.GetLoggerInstance()
+    info, err := testRunner.Read_run_info(txt2jsonObj.resPath)
+    if err == errors.OP_SUCCESS {
+        txt2jsonObj.jsonResults.Timestamp = info.StartTime
+        txt2jsonObj.jsonResults.RunID = info.RunID
+        return errors.OP_SUCCESS
+    }
+    state, err := testRunner.Read_campaign_state(txt2jsonObj.resPath)
+    if err == errors.OP_SUCCESS {
+        txt2jsonObj.jsonResults.Timestamp = state.StartTime
         return errors.OP_SUCCESS
     }
+    logger.Warning("No start time of the run in %s, using current time",
+                   txt2jsonObj.resPath)
+    txt2jsonObj.jsonResults.Timestamp = time.Now()
     return err
 }
 
+//AppendMatricHeader :- Header of a matric entry.
+// starttime :- time of the entry
+// program   :- name of the program reporting the matric
+func (txt2jsonObj *Text2Json) AppendMatricHeader(starttime time.Time,
+    program string,
+    result *string) {
+    *result = "--------------------------------------------\n" +
+        "StartTime=" + strconv.FormatInt(starttime.Unix(), 10) + "\n" +
+        "Host=" + txt2jsonObj.configObj.HostName + "\n" +
+        "Marketplace=" + txt2jsonObj.configObj.Region + "\n" +
+        "Program=" + program + "\n" +
+        "Time=0\n" +
+        "Metrics="
+}
+
 //AppendBW2MatricOutput :- Function to populate matric data to a string.
 // Parameters
 // starttime :- time of the entry
@@ -257,13 +499,7 @@ func (txt2jsonObj *Text2Json) AppendBW2MatricOutput(starttime time.Time,
     valuename string,
     bwvalues []OsuBWTuple,
     result *string) {
-    *result = "--------------------------------------------\n" +
-        "StartTime=" + strconv.FormatInt(starttime.Unix(), 10) + "\n" +
-        "Host=" + txt2jsonObj.configObj.HostName + "\n" +
-        "Marketplace=" + txt2jsonObj.configObj.Region + "\n" +
-        "Program=ec2-osu-benchmark-bw\n" +
-        "Time=0\n" +
-        "Metrics="
+    txt2jsonObj.AppendMatricHeader(starttime, "ec2-osu-benchmark-bw", result)
     for _, entry := range bwvalues {
         *result = fmt.Sprintf("%s%s|%d|%s=%f,", *result,
             txt2jsonObj.configObj.HostName,
@@ -273,13 +509,56 @@ func (txt2jsonObj *Text2Json) AppendBW2MatricOutput(starttime time.Time,
     *result = *result + "\nEOE\n"
 }
 
+//AppendCollective2MatricOutput :- Populate average latency of all the
+// collective benchmarks to a matric entry. The value name is
+// <benchmark>AvgLatencyInUs, ex: osu_allreduceAvgLatencyInUs
+func (txt2jsonObj *Text2Json) AppendCollective2MatricOutput(
+    starttime time.Time,
+    collresults map[string]OsuCollective,
+    result *string) {
+    txt2jsonObj.AppendMatricHeader(starttime,
+        "ec2-osu-benchmark-collective", result)
+    benchNames := make([]string, 0, len(collresults))
+    for benchName := range collresults {
+        benchNames = append(benchNames, benchName)
+    }
+    sort.Strings(benchNames)
+    for _, benchName := range benchNames {
+        for _, entry := range collresults[benchName] {
+            *result = fmt.Sprintf("%s%s|%d|%sAvgLatencyInUs=%f,", *result,
+                txt2jsonObj.configObj.HostName,
+                entry.Pktsize,
+                benchName, entry.AvgLatency)
+        }
+    }
+    *result = *result + "\nEOE\n"
+}
+
+//AppendMsgRate2MatricOutput :- Populate message rate of the multi-pair
+// results to a matric entry, reported as its own matric.
+func (txt2jsonObj *Text2Json) AppendMsgRate2MatricOutput(starttime time.Time,
+    valuename string,
+    mbwvalues []OsuMultiBWTuple,
+    result *string) {
+    txt2jsonObj.AppendMatricHeader(starttime, "ec2-osu-benchmark-msgrate",
+        result)
+    for _, entry := range mbwvalues {
+        *result = fmt.Sprintf("%s%s|%d|%s=%f,", *result,
+            txt2jsonObj.configObj.HostName,
+            entry.Pktsize,
+            valuename, entry.MsgRate)
+    }
+    *result = *result + "\nEOE\n"
+}
+
 //GetMatricFileName : - Matric file name should have the specific timestamp information
 // File get rolled in every hour based on the timestamp
 func (txt2jsonObj *Text2Json) GetMatricFileName() string {
     t := time.Now()
     day := t.Format("2006-01-02")
     hour := t.Hour()
-    return fmt.Sprintf("%s%s-%d", config.DEFAULT_MATRIC_OUTPUT_FILE_PREFIX,
+    return fmt.Sprintf("%s%s%s-%d", txt2jsonObj.configObj.MetricDir,
+        config.DEFAULT_MATRIC_OUTPUT_FILE_NAME,
         day, hour)
 
 }
@@ -315,6 +594,23 @@ func (txt2jsonObj *Text2Json) Write2MatricFile() error {
         ([]OsuBWTuple)(txt2jsonObj.jsonResults.OsuBW),
         &bwresults)
     txt2jsonObj._Write2MatricFile(bwresults)
+    if len(txt2jsonObj.jsonResults.OsuCollective) != 0 {
+        var collresults string
+        txt2jsonObj.AppendCollective2MatricOutput(
+            txt2jsonObj.jsonResults.Timestamp,
+            txt2jsonObj.jsonResults.OsuCollective,
+            &collresults)
+        txt2jsonObj._Write2MatricFile(collresults)
+    }
+    if txt2jsonObj.jsonResults.OsuMultiBW != nil {
+        var msgrateresults string
+        txt2jsonObj.AppendMsgRate2MatricOutput(
+            txt2jsonObj.jsonResults.Timestamp,
+            "MessageRatePerSec",
+            txt2jsonObj.jsonResults.OsuMultiBW.Values,
+            &msgrateresults)
+        txt2jsonObj._Write2MatricFile(msgrateresults)
+    }
     return errors.OP_SUCCESS
 }
 
@@ -342,6 +638,10 @@ func (txt2jsonObj *Text2Json) ProcessResults2Json() error {
     if err != errors.OP_SUCCESS {
         logger.Error("Failed to write to json file")
     }
+    err = txt2jsonObj.WriteHostMatrixCSV()
+    if err != errors.OP_SUCCESS {
+        logger.Error("Failed to write host matrix csv files")
+    }
     err = txt2jsonObj.Write2MatricFile()
     return err
 }
@@ -0,0 +1,120 @@
+package text2json
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+    "ec2-osu-benchmark/config"
+    "ec2-osu-benchmark/errors"
+    "ec2-osu-benchmark/logging"
+    "ec2-osu-benchmark/testRunner"
+)
+
+func TestMain(m *testing.M) {
+    logDir, err := ioutil.TempDir("", "text2json-test-log")
+    if err != nil {
+        panic(err)
+    }
+    logger := new(logging.Logging)
+    logger.LogInitSingleton(logging.Error, filepath.Join(logDir, "test.log"))
+    code := m.Run()
+    os.RemoveAll(logDir)
+    os.Exit(code)
+}
+
+// Result directory with the result files and run records, the name of
+// every record's stdout file is its key in the files. The directory path
+// has benchmark names in it, they must not be taken for the benchmark of
+// the files.
+func newTestText2Json(t *testing.T, files map[string]string,
+    records []*testRunner.OSU_run_record) *Text2Json {
+    t.Helper()
+    resPath := filepath.Join(t.TempDir(), "osu_bw", "osu_latency") + "/"
+    if err := os.MkdirAll(resPath, 0755); err != nil {
+        t.Fatal(err)
+    }
+    for name, content := range files {
+        err := ioutil.WriteFile(resPath+name, []byte(content), 0644)
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+    for _, record := range records {
+        fileName := resPath + filepath.Base(record.StdoutFile)
+        record.StdoutFile = fileName
+        err := testRunner.Write_run_record(record,
+            fileName+testRunner.RUN_RECORD_FILE_SUFFIX)
+        if err != errors.OP_SUCCESS {
+            t.Fatal(err)
+        }
+    }
+    configObj := new(config.AppConfig)
+    configObj.MetricDir = filepath.Join(t.TempDir(), "metrics") + "/"
+    txt2jsonObj := new(Text2Json)
+    txt2jsonObj.Init(configObj, resPath)
+    txt2jsonObj.ReadRunRecords()
+    return txt2jsonObj
+}
+
+func TestBenchmarkOfFile(t *testing.T) {
+    files := map[string]string{
+        "osu_bibw.np2.txt":       "# Size      Bandwidth (MB/s)\n1  1.50\n",
+        "osu_latency.rep2.txt":   "# Size          Latency (us)\n0  2.10\n",
+        "osu_latency_mt.txt":     "# Size          Latency (us)\n0  3.10\n",
+        "osu_allreduce.np4.txt":  "# Size       Avg Latency(us)\n4  1.98\n"}
+    records := []*testRunner.OSU_run_record{
+        {Benchmark: "osu_bibw", StdoutFile: "osu_bibw.np2.txt"}}
+    txt2jsonObj := newTestText2Json(t, files, records)
+    tests := []struct {
+        file       string
+        bench      string
+        latency    bool
+        bw         bool
+        bibw       bool
+        collective bool
+    }{
+        // Recorded, under a directory named osu_bw/osu_latency
+        {"osu_bibw.np2.txt", "osu_bibw", false, false, true, false},
+        // Not recorded, from the file name
+        {"osu_latency.rep2.txt", "osu_latency", true, false, false, false},
+        {"osu_latency_mt.txt", "osu_latency_mt", false, false, false, false},
+        {"osu_allreduce.np4.txt", "osu_allreduce", false, false, false, true},
+    }
+    for _, test := range tests {
+        fileName := txt2jsonObj.resPath + test.file
+        if bench := txt2jsonObj.BenchmarkName(fileName); bench != test.bench {
+            t.Errorf("benchmark of %s is %s, want %s", test.file, bench,
+                test.bench)
+        }
+        if txt2jsonObj.IsLatencyFile(fileName) != test.latency ||
+            txt2jsonObj.IsBWFile(fileName) != test.bw ||
+            txt2jsonObj.IsBiBWFile(fileName) != test.bibw ||
+            txt2jsonObj.IsCollectiveFile(fileName) != test.collective {
+            t.Errorf("%s is misclassified", test.file)
+        }
+    }
+}
+
+func TestReadResultsToReport(t *testing.T) {
+    files := map[string]string{
+        "osu_latency.txt": "# OSU MPI Latency Test v5.6.2\n" +
+            "# Size          Latency (us)\n0                       2.10\n" +
+            "1                       2.12\n",
+        "osu_bw.txt": "# OSU MPI Bandwidth Test v5.6.2\n" +
+            "# Size      Bandwidth (MB/s)\n1                       0.48\n" +
+            "2                       0.95\n"}
+    records := []*testRunner.OSU_run_record{
+        {Benchmark: "osu_latency", StdoutFile: "osu_latency.txt",
+            Status: testRunner.RUN_STATUS_SUCCESS, Primary: true},
+        {Benchmark: "osu_bw", StdoutFile: "osu_bw.txt",
+            Status: testRunner.RUN_STATUS_SUCCESS, Primary: true}}
+    txt2jsonObj := newTestText2Json(t, files, records)
+    txt2jsonObj.Read2JsonStruct()
+    results := txt2jsonObj.jsonResults
+    if len(results.OsuLatency) != 2 || results.OsuLatency[1].Latency != 2.12 ||
+        len(results.OsuBW) != 2 || results.OsuBW[1].Bw != 0.95 ||
+        len(results.OsuBiBW) != 0 || results.OSUVersion != "v5.6.2" {
+        t.Errorf("unexpected results %+v", results)
+    }
+}